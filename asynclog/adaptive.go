@@ -0,0 +1,164 @@
+package asynclog
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// AdaptiveSampling configures WithAdaptiveSampling.
+type AdaptiveSampling struct {
+	// Start is the queue fill, from 0 to 1, above which sampling begins,
+	// 0.5 if zero.
+	Start float64
+	// MinRate is the smallest fraction of records kept at any level, 0.01
+	// if zero.
+	MinRate float64
+	// Window is how often the rates are recomputed from the queue fill and
+	// the records each source sent, a second if zero.
+	Window time.Duration
+}
+
+// SampleRate is the fraction of a source's records kept by level under
+// WithAdaptiveSampling. Errors are always kept.
+type SampleRate struct {
+	Debug float64
+	Info  float64
+	Warn  float64
+}
+
+// WithAdaptiveSampling samples records as the queue fills up. Above Start
+// the base rate falls linearly with the fill, down to MinRate on a full
+// queue, and debug records are kept at the base rate, info at its square
+// root and warnings at its fourth root; errors are never sampled. Each
+// source gets its own rates, scaled by how much of the last window's
+// records it sent: a source sending twice its fair share is kept at the
+// square of the base rate, one sending half of it at the square root. The
+// current rates are in Stats.SampleRates. Sampled records are dropped
+// before being queued, like filtered ones. It panics if Start or MinRate
+// isn't below 1.
+func WithAdaptiveSampling(cfg AdaptiveSampling) Option {
+	if cfg.Start < 0 || cfg.Start >= 1 || cfg.MinRate < 0 || cfg.MinRate >= 1 || cfg.Window < 0 {
+		panic(fmt.Sprintf("asynclog: WithAdaptiveSampling: invalid %+v", cfg))
+	}
+	if cfg.Start == 0 {
+		cfg.Start = 0.5
+	}
+	if cfg.MinRate == 0 {
+		cfg.MinRate = 0.01
+	}
+	if cfg.Window == 0 {
+		cfg.Window = time.Second
+	}
+
+	return func(s *Service) {
+		s.sampler = &adaptiveSampler{cfg: cfg}
+	}
+}
+
+// adaptiveSampler keeps records at their source's rates, deterministically:
+// every record adds its rate to the source's credit for its level, and is
+// kept when that reaches one.
+type adaptiveSampler struct {
+	cfg AdaptiveSampling
+
+	mu     sync.Mutex
+	next   time.Time
+	counts map[string]int
+	rates  map[string]SampleRate
+	credit map[string]*[LevelError + 1]float64
+}
+
+// keep reports whether to keep a record of level from source, recomputing
+// the rates with the queue fill from pressure once the window is over.
+func (a *adaptiveSampler) keep(now time.Time, source string, level Level, pressure func() float64) bool {
+	if level >= LevelError || level < LevelDebug {
+		return true
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.counts == nil {
+		a.counts = make(map[string]int)
+		a.credit = make(map[string]*[LevelError + 1]float64)
+		a.next = now.Add(a.cfg.Window)
+	}
+	if !now.Before(a.next) {
+		a.recompute(pressure())
+		a.next = now.Add(a.cfg.Window)
+	}
+	a.counts[source]++
+
+	r, ok := a.rates[source]
+	if !ok {
+		return true
+	}
+
+	rate := [...]float64{LevelDebug: r.Debug, LevelInfo: r.Info, LevelWarn: r.Warn}[level]
+	c := a.credit[source]
+	if c == nil {
+		c = new([LevelError + 1]float64)
+		a.credit[source] = c
+	}
+	c[level] += rate
+	if c[level] < 1 {
+		return false
+	}
+	c[level]--
+
+	return true
+}
+
+// recompute sets the rates of the sources seen in the last window for the
+// queue fill p. It is called with mu held.
+func (a *adaptiveSampler) recompute(p float64) {
+	base := 1.0
+	if p > a.cfg.Start {
+		base = max(a.cfg.MinRate, 1-(p-a.cfg.Start)/(1-a.cfg.Start))
+	}
+
+	total := 0
+	for _, n := range a.counts {
+		total += n
+	}
+	fair := float64(total) / float64(max(len(a.counts), 1))
+
+	rates := make(map[string]SampleRate, len(a.counts))
+	for source, n := range a.counts {
+		k := math.Pow(base, float64(n)/fair)
+		rates[source] = SampleRate{
+			Debug: max(a.cfg.MinRate, k),
+			Info:  max(a.cfg.MinRate, math.Sqrt(k)),
+			Warn:  max(a.cfg.MinRate, math.Sqrt(math.Sqrt(k))),
+		}
+	}
+	for source := range a.credit {
+		if _, ok := rates[source]; !ok {
+			delete(a.credit, source)
+		}
+	}
+
+	a.rates = rates
+	clear(a.counts)
+}
+
+// snapshot returns a copy of the current rates.
+func (a *adaptiveSampler) snapshot() map[string]SampleRate {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	rates := make(map[string]SampleRate, len(a.rates))
+	for source, r := range a.rates {
+		rates[source] = r
+	}
+
+	return rates
+}
+
+// pressure is how full the queue is, from 0 to 1 and above when expanded
+// under WithBurstCapacity.
+func (s *Service) pressure() float64 {
+	return float64(s.queue.size()) / float64(s.queueSize)
+}
@@ -0,0 +1,62 @@
+package asynclog_test
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"test-task-log/asynclog"
+	"test-task-log/asynclog/testutil"
+)
+
+func TestAdaptiveSampling(t *testing.T) {
+	clock := testutil.NewClock(time.Time{})
+	sink := testutil.NewSink()
+	s := asynclog.NewService(sink, asynclog.WithClock(clock), asynclog.WithChannelBuffer(10),
+		asynclog.WithAdaptiveSampling(asynclog.AdaptiveSampling{Start: 0.5, Window: time.Second}))
+
+	// Nothing is sampled in the first window.
+	for range 8 {
+		if err := printShort(s, "noisy", asynclog.LevelDebug, "d"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := s.Stats().Sampled; got != 0 {
+		t.Fatalf("got %d sampled in the first window, want 0", got)
+	}
+
+	// At 80% full the base rate is 1-(0.8-0.5)/(1-0.5) = 0.4, and with a
+	// single source that is its debug rate: two in five debug records are
+	// kept. Errors always are.
+	clock.Advance(time.Second)
+	for _, level := range []asynclog.Level{asynclog.LevelDebug, asynclog.LevelError, asynclog.LevelDebug, asynclog.LevelDebug} {
+		if err := printShort(s, "noisy", level, "x"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	st := s.Stats()
+	r := st.SampleRates["noisy"]
+	if math.Abs(r.Debug-0.4) > 1e-9 || math.Abs(r.Info-math.Sqrt(0.4)) > 1e-9 || math.Abs(r.Warn-math.Sqrt(math.Sqrt(0.4))) > 1e-9 {
+		t.Fatalf("got rates %+v, want 0.4 and its square and fourth roots", r)
+	}
+	if st.Sampled != 2 || st.Queued != 10 {
+		t.Fatalf("got %d sampled and %d queued, want 2 and 10", st.Sampled, st.Queued)
+	}
+	if got := drain(t, s, sink); len(got) != 10 {
+		t.Fatalf("got %d lines, want 10", len(got))
+	}
+}
+
+func TestAdaptiveSamplingInvalid(t *testing.T) {
+	for _, cfg := range []asynclog.AdaptiveSampling{{Start: 1}, {MinRate: -0.1}, {Window: -time.Second}} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("WithAdaptiveSampling(%+v) didn't panic", cfg)
+				}
+			}()
+			asynclog.WithAdaptiveSampling(cfg)
+		}()
+	}
+}
@@ -0,0 +1,100 @@
+package asynclog_test
+
+import (
+	"bytes"
+	"context"
+	"math/rand/v2"
+	"sync"
+	"testing"
+	"time"
+
+	"test-task-log/asynclog"
+)
+
+// jitterWriter takes a random few milliseconds per write, so batches written
+// concurrently would complete out of order.
+type jitterWriter struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (w *jitterWriter) Write(p []byte) (int, error) {
+	time.Sleep(time.Duration(rand.IntN(3)) * time.Millisecond)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.buf.Write(p)
+}
+
+func TestAuditChainOrder(t *testing.T) {
+	w := &jitterWriter{}
+	s := asynclog.NewService(w, asynclog.WithAuditChain(), asynclog.WithBatchSize(2))
+	go s.Run(context.Background())
+
+	for range 100 {
+		printAll(t, s, "record")
+	}
+	printAll(t, s, "#audit prev=00 hash=00", "forged\n#sig 00")
+	if err := s.Shutdown(testContext(t)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := asynclog.VerifyAuditChain(bytes.NewReader(w.buf.Bytes())); err != nil {
+		t.Fatal(err)
+	}
+	for _, escaped := range []string{"\n##audit prev=00 hash=00\n", "\n##sig 00\n"} {
+		if !bytes.Contains(w.buf.Bytes(), []byte(escaped)) {
+			t.Errorf("output lacks %q", escaped)
+		}
+	}
+}
+
+func TestAuditChainTenantBlocks(t *testing.T) {
+	key := bytes.Repeat([]byte{1}, 16)
+	w := &jitterWriter{}
+	s := asynclog.NewService(w, asynclog.WithAuditChain(), asynclog.WithTenantKeys(map[string][]byte{"acme": key}))
+	go s.Run(context.Background())
+
+	printAll(t, s, "#plain")
+	if err := s.PrintFrom(context.Background(), "acme", asynclog.LevelInfo, "secret"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Shutdown(testContext(t)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := asynclog.VerifyAuditChain(bytes.NewReader(w.buf.Bytes())); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	if err := asynclog.DecryptTenantBatches(&out, bytes.NewReader(w.buf.Bytes()), map[string][]byte{"acme": key}); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(out.Bytes(), []byte("\nsecret\n")) || !bytes.HasPrefix(out.Bytes(), []byte("##plain\n")) {
+		t.Fatalf("got %q, want the escaped plain record and the decrypted tenant one", out.Bytes())
+	}
+}
+
+func TestAuditChainTampered(t *testing.T) {
+	w := &jitterWriter{}
+	s := asynclog.NewService(w, asynclog.WithAuditChain(), asynclog.WithBatchSize(1))
+	go s.Run(context.Background())
+	printAll(t, s, "a", "b", "c")
+	if err := s.Shutdown(testContext(t)); err != nil {
+		t.Fatal(err)
+	}
+	lines := bytes.SplitAfter(w.buf.Bytes(), []byte("\n"))
+
+	edited := bytes.Replace(w.buf.Bytes(), []byte("b\n"), []byte("B\n"), 1)
+	if err := asynclog.VerifyAuditChain(bytes.NewReader(edited)); err == nil {
+		t.Fatal("edited batch verified")
+	}
+
+	// The second batch is a record line and its trailer.
+	removed := bytes.Join(append(lines[:2:2], lines[4:]...), nil)
+	if err := asynclog.VerifyAuditChain(bytes.NewReader(removed)); err == nil {
+		t.Fatal("chain with a batch removed verified")
+	}
+}
@@ -0,0 +1,56 @@
+package asynclog
+
+import "context"
+
+// Barrier returns once every record accepted before the call has been
+// written, including those still queued or buffered, so callers can sequence
+// "log, then act" steps such as a final checkpoint. Records still being
+// accepted concurrently may or may not be covered.
+func (s *Service) Barrier(ctx context.Context) error {
+	req := make(chan []chan struct{}, 1)
+
+	select {
+	case s.barrierCh <- req:
+	case <-s.done:
+		return ErrClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	for _, w := range <-req {
+		select {
+		case <-w:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	return nil
+}
+
+// barrier runs in Run: it flushes everything accepted so far and returns the
+// writes that have to complete for it to be written.
+func (s *Service) barrier() []chan struct{} {
+	s.addQueued()
+	s.flush("barrier", s.buffers()...)
+	s.flushSinks()
+
+	s.pruneWrites()
+
+	return append([]chan struct{}(nil), s.writes...)
+}
+
+// pruneWrites forgets writes that have completed.
+func (s *Service) pruneWrites() {
+	pending := s.writes[:0]
+	for _, w := range s.writes {
+		select {
+		case <-w:
+		default:
+			pending = append(pending, w)
+		}
+	}
+
+	clear(s.writes[len(pending):])
+	s.writes = pending
+}
@@ -0,0 +1,51 @@
+package asynclog_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"test-task-log/asynclog"
+)
+
+func TestBarrier(t *testing.T) {
+	s, sink, _ := start(t)
+
+	// Nothing flushes on its own: the interval never passes and the batch
+	// isn't full.
+	printAll(t, s, "a", "b")
+	if err := s.Barrier(testContext(t)); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := sink.Lines(), []string{"a", "b"}; !slices.Equal(got, want) {
+		t.Fatalf("got lines %q after Barrier, want %q", got, want)
+	}
+
+	if err := s.Shutdown(testContext(t)); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Barrier(testContext(t)); !errors.Is(err, asynclog.ErrClosed) {
+		t.Fatalf("Barrier after Shutdown: got %v, want ErrClosed", err)
+	}
+}
+
+func TestBarrierWaitsForWrites(t *testing.T) {
+	w := gateWriter{release: make(chan struct{})}
+	s := asynclog.NewService(w)
+	go s.Run(context.Background())
+	t.Cleanup(func() { s.Shutdown(context.Background()) })
+
+	printAll(t, s, "a")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := s.Barrier(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got %v with the write blocked, want context.DeadlineExceeded", err)
+	}
+
+	close(w.release)
+	if err := s.Barrier(testContext(t)); err != nil {
+		t.Fatal(err)
+	}
+}
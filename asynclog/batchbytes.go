@@ -0,0 +1,61 @@
+package asynclog
+
+// WithMaxBatchBytes caps batches at n encoded bytes: a buffer is flushed
+// before a record would take it over n, whichever of the batch size, n
+// bytes or the interval comes first, and flushes taking several buffers at
+// once are split into batches of at most n bytes. A record larger than n
+// goes out in a batch of its own. Sizes count the encoded records, without
+// batch headers, signatures or encryption, and measuring them encodes every
+// record once more. It panics if n is not positive.
+func WithMaxBatchBytes(n int) Option {
+	if n <= 0 {
+		panic("asynclog: non-positive max batch bytes")
+	}
+
+	return func(s *Service) {
+		s.maxBatchBytes = n
+	}
+}
+
+// recordSize returns the encoded size of rec, remembering it in rec.
+func (s *Service) recordSize(rec *record) int {
+	if rec.size == 0 {
+		p, _ := s.encodeRecords([]record{*rec})
+		rec.size = max(len(p), 1)
+	}
+
+	return rec.size
+}
+
+// split cuts recs into batches of at most maxBatchBytes encoded bytes each.
+func (s *Service) split(recs []record) [][]record {
+	if s.maxBatchBytes <= 0 {
+		return [][]record{recs}
+	}
+
+	var parts [][]record
+	start, size := 0, 0
+	for i := range recs {
+		n := s.recordSize(&recs[i])
+		if i > start && size+n > s.maxBatchBytes {
+			parts = append(parts, recs[start:i])
+			start, size = i, 0
+		}
+		size += n
+	}
+
+	return append(parts, recs[start:])
+}
+
+// sendAll sends recs in batches of at most maxBatchBytes, one after the
+// other, returning the first error.
+func (s *Service) sendAll(recs []record) error {
+	var first error
+	for _, part := range s.split(recs) {
+		if err := s.send(s.prepare(part)); err != nil && first == nil {
+			first = err
+		}
+	}
+
+	return first
+}
@@ -0,0 +1,44 @@
+package asynclog_test
+
+import (
+	"slices"
+	"testing"
+
+	"test-task-log/asynclog"
+)
+
+func TestMaxBatchBytes(t *testing.T) {
+	s, sink, _ := start(t, asynclog.WithMaxBatchBytes(6), asynclog.WithBatchSize(100))
+
+	// cc would take the buffer over 6 bytes, so aa and bb go out first.
+	printAll(t, s, "aa", "bb", "cc")
+	if err := sink.WaitWrites(testContext(t), 1); err != nil {
+		t.Fatal(err)
+	}
+	if got := sink.Writes(); len(got) != 1 || string(got[0]) != "aa\nbb\n" {
+		t.Fatalf("got writes %q, want aa and bb in one", got)
+	}
+
+	// A flush taking a record larger than the cap writes it on its own.
+	printAll(t, s, "oversized")
+	if err := s.Flush(testContext(t)); err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, w := range sink.Writes()[1:] {
+		got = append(got, string(w))
+	}
+	slices.Sort(got)
+	if want := []string{"cc\n", "oversized\n"}; !slices.Equal(got, want) {
+		t.Fatalf("got writes %q, want %q", got, want)
+	}
+}
+
+func TestMaxBatchBytesInvalid(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("WithMaxBatchBytes(0) didn't panic")
+		}
+	}()
+	asynclog.WithMaxBatchBytes(0)
+}
@@ -0,0 +1,46 @@
+package asynclog_test
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	"test-task-log/asynclog"
+)
+
+// benchmarkService measures the time and memory every record takes through a
+// service writing to io.Discard, both enqueueing it and writing it out.
+func benchmarkService(b *testing.B, log func(s *asynclog.Service, ctx context.Context, i int) error, opts ...asynclog.Option) {
+	s := asynclog.NewService(io.Discard, append([]asynclog.Option{asynclog.WithBatchSize(100)}, opts...)...)
+	ctx := context.Background()
+	go s.Run(ctx)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := range b.N {
+		if err := log(s, ctx, i); err != nil {
+			b.Fatal(err)
+		}
+	}
+	if err := s.Shutdown(ctx); err != nil {
+		b.Fatal(err)
+	}
+}
+
+func BenchmarkPrint(b *testing.B) {
+	benchmarkService(b, func(s *asynclog.Service, ctx context.Context, _ int) error {
+		return s.Print(ctx, "bench record")
+	})
+}
+
+func BenchmarkLogFields(b *testing.B) {
+	benchmarkService(b, func(s *asynclog.Service, ctx context.Context, i int) error {
+		return s.Log(ctx, asynclog.Entry{Message: "bench record", Fields: []asynclog.Field{asynclog.F("i", i)}})
+	})
+}
+
+func BenchmarkLogJSON(b *testing.B) {
+	benchmarkService(b, func(s *asynclog.Service, ctx context.Context, i int) error {
+		return s.Log(ctx, asynclog.Entry{Message: "bench record", Fields: []asynclog.Field{asynclog.F("i", i)}})
+	}, asynclog.WithEncoder(asynclog.JSONEncoder{}))
+}
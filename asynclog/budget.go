@@ -0,0 +1,148 @@
+package asynclog
+
+import (
+	"context"
+	"errors"
+	"io"
+	"reflect"
+	"time"
+)
+
+// ErrOverBudget is the error of writers that didn't take a batch within the
+// WithDeliveryBudget budget.
+var ErrOverBudget = errors.New("asynclog: delivery budget exceeded")
+
+// WithDeliveryBudget bounds the delivery of each batch to d overall: the main
+// writer and the sinks are written concurrently, each as its own target
+// rather than combined, and once d is up the batch completes with the writers
+// that are done, failing with ErrOverBudget for the others instead of waiting
+// for the slowest one. A write that overruns carries on in the background,
+// cancelled through its context if the writer takes one, and its writer
+// fails batches with ErrSinkBehind until it completes.
+func WithDeliveryBudget(d time.Duration) Option {
+	return func(s *Service) {
+		s.budget = d
+	}
+}
+
+type delivered struct {
+	i   int
+	err error
+}
+
+// deliverWithin is deliver writing the targets concurrently within the
+// budget.
+func (s *Service) deliverWithin(ctx context.Context, ts []target) error {
+	ctx, cancel := context.WithTimeout(ctx, s.budget)
+	defer cancel()
+
+	done := make(chan delivered, len(ts))
+	writes := make([]*budgetedWrite, len(ts))
+	for i, t := range ts {
+		if s.isBehind(t.writer) {
+			done <- delivered{i: i, err: ErrSinkBehind}
+			continue
+		}
+
+		bw := &budgetedWrite{}
+		writes[i] = bw
+		s.stragglers.Add(1)
+		go func() {
+			defer s.stragglers.Done()
+
+			err := s.deliverTarget(ctx, t)
+			s.caughtUp(t.writer, bw)
+			done <- delivered{i: i, err: err}
+		}()
+	}
+
+	errs := make([]error, len(ts))
+	finished := make([]bool, len(ts))
+wait:
+	for range ts {
+		select {
+		case d := <-done:
+			errs[d.i], finished[d.i] = d.err, true
+		case <-ctx.Done():
+			break wait
+		}
+	}
+
+	for i, t := range ts {
+		if !finished[i] {
+			s.debugf("delivery budget of %s up, leaving %T behind", s.budget, t.writer)
+			errs[i] = ErrOverBudget
+			s.leaveBehind(t.writer, writes[i])
+		}
+	}
+
+	var failed []error
+	for i, err := range errs {
+		if err != nil && !ts[i].shadow {
+			failed = append(failed, err)
+		}
+	}
+
+	err := errors.Join(failed...)
+	s.health.set(err)
+
+	return err
+}
+
+// budgetedWrite is the state of a write deliverWithin started, guarded by
+// behindMx.
+type budgetedWrite struct {
+	done   bool // the write completed
+	behind bool // the write overran the budget, marking its writer behind
+}
+
+// isBehind reports whether a write to w that overran the budget is still
+// going. Writes within the budget, even overlapping ones, don't count.
+func (s *Service) isBehind(w io.Writer) bool {
+	if !reflect.TypeOf(w).Comparable() {
+		return false
+	}
+
+	s.behindMx.Lock()
+	defer s.behindMx.Unlock()
+
+	return s.behind[w] > 0
+}
+
+// leaveBehind marks w behind for bw, which overran the budget, unless it has
+// completed meanwhile.
+func (s *Service) leaveBehind(w io.Writer, bw *budgetedWrite) {
+	if !reflect.TypeOf(w).Comparable() {
+		return
+	}
+
+	s.behindMx.Lock()
+	defer s.behindMx.Unlock()
+
+	if bw.done {
+		return
+	}
+	bw.behind = true
+	if s.behind == nil {
+		s.behind = make(map[io.Writer]int)
+	}
+	s.behind[w]++
+}
+
+// caughtUp records that bw completed, clearing its mark of w if bw was left
+// behind.
+func (s *Service) caughtUp(w io.Writer, bw *budgetedWrite) {
+	if !reflect.TypeOf(w).Comparable() {
+		return
+	}
+
+	s.behindMx.Lock()
+	defer s.behindMx.Unlock()
+
+	bw.done = true
+	if bw.behind {
+		if s.behind[w]--; s.behind[w] == 0 {
+			delete(s.behind, w)
+		}
+	}
+}
@@ -0,0 +1,49 @@
+package asynclog_test
+
+import (
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"test-task-log/asynclog"
+)
+
+func TestDeliveryBudget(t *testing.T) {
+	s, sink, _ := start(t, asynclog.WithBatchSize(1), asynclog.WithDeliveryBudget(20*time.Millisecond))
+	slow := gateWriter{release: make(chan struct{})}
+	var release sync.Once
+	t.Cleanup(func() { release.Do(func() { close(slow.release) }) })
+	if err := s.AddSink("slow", slow); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.PrintSync(testContext(t), "a"); !errors.Is(err, asynclog.ErrOverBudget) {
+		t.Fatalf("got %v, want ErrOverBudget from the stuck sink", err)
+	}
+	if err := s.PrintSync(testContext(t), "b"); !errors.Is(err, asynclog.ErrSinkBehind) {
+		t.Fatalf("got %v while the overrun write is going, want ErrSinkBehind", err)
+	}
+	if got := sink.Lines(); !slices.Equal(got, []string{"a", "b"}) {
+		t.Fatalf("got %q, want the fast writer to get every batch", got)
+	}
+
+	// Once the overrun write completes the sink takes batches again.
+	release.Do(func() { close(slow.release) })
+	ctx := testContext(t)
+	for {
+		err := s.PrintSync(ctx, "c")
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, asynclog.ErrSinkBehind) {
+			t.Fatalf("got %v after the sink caught up", err)
+		}
+		select {
+		case <-ctx.Done():
+			t.Fatal("sink still behind after its write completed")
+		case <-time.After(time.Millisecond):
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package asynclog
+
+import "io"
+
+// CanaryStats compares how batch writes fared on the main writer and on the
+// canary writer since the canary was set.
+type CanaryStats struct {
+	OldOK     uint64
+	OldFailed uint64
+	NewOK     uint64
+	NewFailed uint64
+}
+
+// WithCanary is SetCanary at construction.
+func WithCanary(w io.Writer, percent float64) Option {
+	return func(s *Service) {
+		s.canary, s.canaryBase = w, w
+		s.canaryPercent = min(max(percent, 0), 100)
+	}
+}
+
+// SetCanary sends percent of the batches for the main writer to w instead,
+// spread evenly, as a gradual cutover to a new destination. It can be called
+// again with the same writer to change the percentage, keeping its
+// CanaryStats; nil sends everything to the main writer again.
+func (s *Service) SetCanary(w io.Writer, percent float64) {
+	s.writerMx.Lock()
+	defer s.writerMx.Unlock()
+
+	if !sameWriter(w, s.canaryBase) {
+		s.canary, s.canaryBase = nil, w
+		if w != nil {
+			s.canary = s.wrap(w)
+		}
+		s.canaryStats = &shadowCounters{}
+	}
+	s.canaryPercent = min(max(percent, 0), 100)
+}
+
+// CanaryStats returns the write outcomes since the canary writer was set.
+func (s *Service) CanaryStats() CanaryStats {
+	s.writerMx.RLock()
+	c := s.canaryStats
+	s.writerMx.RUnlock()
+
+	return CanaryStats{
+		OldOK:     c.primaryOK.Load(),
+		OldFailed: c.primaryFailed.Load(),
+		NewOK:     c.shadowOK.Load(),
+		NewFailed: c.shadowFailed.Load(),
+	}
+}
+
+// pickCanary reports whether the next batch goes to the canary. It runs in
+// Run with writerMx held.
+func (s *Service) pickCanary() bool {
+	if s.canary == nil {
+		return false
+	}
+
+	s.canaryAcc += s.canaryPercent
+	if s.canaryAcc < 100 {
+		return false
+	}
+	s.canaryAcc -= 100
+
+	return true
+}
@@ -0,0 +1,51 @@
+package asynclog_test
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"test-task-log/asynclog"
+	"test-task-log/asynclog/testutil"
+)
+
+func TestCanary(t *testing.T) {
+	canary := testutil.NewSink()
+	s, main, _ := start(t, asynclog.WithBatchSize(1), asynclog.WithCanary(canary, 50))
+
+	printSync := func(msgs ...string) {
+		t.Helper()
+		for _, msg := range msgs {
+			if err := s.PrintSync(testContext(t), msg); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	// Half the batches, spread evenly.
+	printSync("a", "b", "c", "d")
+	if got, want := main.Lines(), []string{"a", "c"}; !slices.Equal(got, want) {
+		t.Fatalf("main writer got %q, want %q", got, want)
+	}
+	if got, want := canary.Lines(), []string{"b", "d"}; !slices.Equal(got, want) {
+		t.Fatalf("canary got %q, want %q", got, want)
+	}
+
+	// Turning the percentage up keeps the stats so far.
+	s.SetCanary(canary, 100)
+	printSync("e")
+	canary.Fail(errors.New("down"))
+	if err := s.PrintSync(testContext(t), "f"); err == nil {
+		t.Fatal("PrintSync succeeded with the canary failing")
+	}
+	want := asynclog.CanaryStats{OldOK: 2, NewOK: 3, NewFailed: 1}
+	if got := s.CanaryStats(); got != want {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+
+	s.SetCanary(nil, 0)
+	printSync("g")
+	if got, want := main.Lines(), []string{"a", "c", "g"}; !slices.Equal(got, want) {
+		t.Fatalf("main writer got %q after removing the canary, want %q", got, want)
+	}
+}
@@ -0,0 +1,105 @@
+package asynclog_test
+
+import (
+	"context"
+	"io"
+	"net/url"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"test-task-log/asynclog"
+)
+
+// checkErrors returns the failed steps of results as "step target".
+func checkErrors(results []asynclog.CheckResult) []string {
+	var failed []string
+	for _, r := range results {
+		if r.Err != nil {
+			failed = append(failed, r.Step+" "+r.Target)
+		}
+	}
+
+	return failed
+}
+
+func TestCheck(t *testing.T) {
+	dir := t.TempDir()
+	c := &asynclog.Config{
+		Writer: "file://" + filepath.Join(dir, "app.log"),
+		Sinks:  map[string]string{"errors": "file://" + filepath.Join(dir, "errors.log")},
+		Routes: []asynclog.Route{{Sink: "errors", Level: "error"}},
+	}
+
+	results := c.Check(testContext(t))
+	if failed := checkErrors(results); len(failed) != 0 {
+		t.Fatalf("got failed steps %q, want none in %+v", failed, results)
+	}
+	verified := 0
+	for _, r := range results {
+		if r.Step == "verify" {
+			verified++
+		}
+	}
+	if verified != 2 {
+		t.Fatalf("got %d files verified, want the writer's and the sink's in %+v", verified, results)
+	}
+}
+
+func TestCheckFailures(t *testing.T) {
+	dir := t.TempDir()
+	for _, tc := range []struct {
+		name string
+		c    *asynclog.Config
+		want string
+	}{
+		{"invalid", &asynclog.Config{Level: "loud"}, "validate config"},
+		{"unopenable", &asynclog.Config{Writer: "nosuchscheme:"}, "open writer"},
+		{"not routed", &asynclog.Config{
+			Writer: "file://" + filepath.Join(dir, "app.log"),
+			Sinks:  map[string]string{"audit": "file://" + filepath.Join(dir, "audit.log")},
+			Routes: []asynclog.Route{{Sink: "audit", Source: "billing"}},
+		}, "verify sink audit"},
+	} {
+		failed := checkErrors(tc.c.Check(testContext(t)))
+		if len(failed) != 1 || failed[0] != tc.want {
+			t.Errorf("%s: got failed steps %q, want %q", tc.name, failed, tc.want)
+		}
+	}
+}
+
+// hungWriter passes probes but never finishes a write until release is
+// closed.
+type hungWriter struct {
+	gateWriter
+}
+
+func (hungWriter) Probe(context.Context) error {
+	return nil
+}
+
+func TestCheckHungWriter(t *testing.T) {
+	w := hungWriter{gateWriter{release: make(chan struct{})}}
+	t.Cleanup(func() { close(w.release) })
+	asynclog.RegisterSink("checkhung", func(*url.URL) (io.Writer, error) {
+		return w, nil
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	results := make(chan []asynclog.CheckResult)
+	go func() {
+		results <- (&asynclog.Config{Writer: "checkhung:"}).Check(ctx)
+	}()
+
+	select {
+	case r := <-results:
+		failed := checkErrors(r)
+		if !slices.Contains(failed, "write pipeline") || !slices.Contains(failed, "shutdown pipeline") {
+			t.Fatalf("got failed steps %q, want the write and the shutdown", failed)
+		}
+	case <-testContext(t).Done():
+		t.Fatal("Check hung on a hung writer")
+	}
+}
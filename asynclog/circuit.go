@@ -0,0 +1,239 @@
+package asynclog
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+// ErrCircuitOpen is returned for writes to a writer whose circuit
+// WithCircuitBreaker opened.
+var ErrCircuitOpen = errors.New("asynclog: circuit open")
+
+// CircuitState is the state of a writer's circuit breaker.
+type CircuitState int
+
+const (
+	// CircuitClosed writers are written to as usual.
+	CircuitClosed CircuitState = iota
+	// CircuitOpen writers failed too many writes in a row and aren't
+	// written to until the cool-down is over.
+	CircuitOpen
+	// CircuitHalfOpen writers are past the cool-down: the next write probes
+	// them first, closing the circuit if they answer and opening it again
+	// otherwise.
+	CircuitHalfOpen
+)
+
+func (st CircuitState) String() string {
+	switch st {
+	case CircuitClosed:
+		return "closed"
+	case CircuitOpen:
+		return "open"
+	default:
+		return "half-open"
+	}
+}
+
+// WithCircuitBreaker opens the circuit of the main writer or a sink once
+// failures writes to it failed in a row: writes to it then fail right away
+// with ErrCircuitOpen for coolDown, after which the next write probes it,
+// as WithHealthProbe does, before going through. While the main writer's
+// circuit is open, Run holds on to what is buffered instead of failing
+// batches one after the other and stops taking records off the queue, so
+// they pile up there as the overflow policy says, spilled under WithSpill.
+// Flush, Barrier and shutdown still write, failing fast. Circuits are in
+// Stats.Circuits, and changes of state are EventCircuit events. It panics
+// if failures or coolDown is not positive.
+func WithCircuitBreaker(failures int, coolDown time.Duration) Option {
+	if failures <= 0 || coolDown <= 0 {
+		panic(fmt.Sprintf("asynclog: WithCircuitBreaker: invalid failures %d or cool-down %s", failures, coolDown))
+	}
+
+	return func(s *Service) {
+		s.breakAfter = failures
+		s.coolDown = coolDown
+	}
+}
+
+// breakerWriter is the circuit breaker of w, the outermost wrapper added by
+// wrap, so an open circuit doesn't even reach the watchdog.
+type breakerWriter struct {
+	w io.Writer
+	s *Service
+
+	mu       sync.Mutex
+	state    CircuitState
+	failures int
+	until    time.Time // end of the cool-down while open
+	probing  bool      // a half-open probe is underway
+	lastErr  error
+}
+
+func (bw *breakerWriter) Write(p []byte) (int, error) {
+	return bw.WriteContext(context.Background(), p)
+}
+
+func (bw *breakerWriter) WriteContext(ctx context.Context, p []byte) (int, error) {
+	bw.mu.Lock()
+	if bw.current() != CircuitClosed {
+		if bw.current() == CircuitOpen || bw.probing {
+			err := bw.lastErr
+			bw.mu.Unlock()
+			return 0, fmt.Errorf("%w: %w", ErrCircuitOpen, err)
+		}
+
+		bw.setState(CircuitHalfOpen)
+		bw.probing = true
+		bw.mu.Unlock()
+
+		err := probe(ctx, bw.w)
+		bw.mu.Lock()
+		bw.probing = false
+		if err != nil {
+			bw.open(err)
+			bw.mu.Unlock()
+			return 0, fmt.Errorf("%w: %w", ErrCircuitOpen, err)
+		}
+		bw.setState(CircuitClosed)
+		bw.failures = 0
+	}
+	bw.mu.Unlock()
+
+	n, err := writeContext(ctx, bw.w, p)
+
+	bw.mu.Lock()
+	defer bw.mu.Unlock()
+
+	if err == nil {
+		bw.failures = 0
+		return n, nil
+	}
+
+	// A write racing the one that opened the circuit doesn't extend the
+	// cool-down.
+	if bw.failures++; bw.failures >= bw.s.breakAfter && bw.state == CircuitClosed {
+		bw.open(err)
+	}
+
+	return n, err
+}
+
+// current returns the state, half-open once the cool-down is over. It is
+// called with mu held.
+func (bw *breakerWriter) current() CircuitState {
+	if bw.state == CircuitOpen && !bw.s.clock.Now().Before(bw.until) {
+		return CircuitHalfOpen
+	}
+
+	return bw.state
+}
+
+// open opens the circuit for the cool-down after err. It is called with mu
+// held.
+func (bw *breakerWriter) open(err error) {
+	bw.lastErr = err
+	bw.until = bw.s.clock.Now().Add(bw.s.coolDown)
+	bw.setState(CircuitOpen)
+	bw.s.debugf("circuit open for %s after %d failed writes: %v", bw.s.coolDown, bw.failures, err)
+}
+
+// setState changes the state, recording an EventCircuit. It is called with
+// mu held.
+func (bw *breakerWriter) setState(st CircuitState) {
+	if bw.state == st {
+		return
+	}
+
+	bw.state = st
+	bw.s.event(EventCircuit, st.String(), nil)
+}
+
+// State returns the state of the circuit.
+func (bw *breakerWriter) State() CircuitState {
+	bw.mu.Lock()
+	defer bw.mu.Unlock()
+
+	return bw.current()
+}
+
+func (bw *breakerWriter) Flush() error {
+	return flushWriter(bw.w)
+}
+
+func (bw *breakerWriter) Sync() error {
+	return syncWriter(bw.w)
+}
+
+func (bw *breakerWriter) SetWriteDeadline(t time.Time) error {
+	if d, ok := bw.w.(writeDeadliner); ok {
+		return d.SetWriteDeadline(t)
+	}
+
+	return nil
+}
+
+func (bw *breakerWriter) Probe(ctx context.Context) error {
+	return probe(ctx, bw.w)
+}
+
+// Close closes w if it is an io.Closer, so StuckClose still reaches it.
+func (bw *breakerWriter) Close() error {
+	if c, ok := bw.w.(io.Closer); ok {
+		return c.Close()
+	}
+
+	return nil
+}
+
+// breakerOf returns the circuit breaker of a writer set up by wrap or
+// sinkWriter, nil if it has none.
+func breakerOf(w io.Writer) *breakerWriter {
+	if qw, ok := w.(*queuedWriter); ok {
+		w = qw.w
+	}
+	bw, _ := w.(*breakerWriter)
+
+	return bw
+}
+
+// holding reports whether Run holds on to buffered records because the
+// main writer's circuit is open.
+func (s *Service) holding() bool {
+	if s.breakAfter <= 0 {
+		return false
+	}
+
+	s.writerMx.RLock()
+	bw := breakerOf(s.writer)
+	s.writerMx.RUnlock()
+
+	return bw != nil && bw.State() == CircuitOpen
+}
+
+// circuits returns the circuit state of the main writer, under "", and of
+// every sink, nil without WithCircuitBreaker.
+func (s *Service) circuits() map[string]CircuitState {
+	if s.breakAfter <= 0 {
+		return nil
+	}
+
+	s.writerMx.RLock()
+	defer s.writerMx.RUnlock()
+
+	states := make(map[string]CircuitState, len(s.sinks)+1)
+	if bw := breakerOf(s.writer); bw != nil {
+		states[""] = bw.State()
+	}
+	for _, sk := range s.sinks {
+		if bw := breakerOf(sk.writer); bw != nil {
+			states[sk.name] = bw.State()
+		}
+	}
+
+	return states
+}
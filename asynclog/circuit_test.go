@@ -0,0 +1,73 @@
+package asynclog_test
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"test-task-log/asynclog"
+)
+
+func waitCircuit(t *testing.T, s *asynclog.Service, want asynclog.CircuitState) {
+	t.Helper()
+
+	ctx := testContext(t)
+	for s.Stats().Circuits[""] != want {
+		select {
+		case <-ctx.Done():
+			t.Fatalf("circuit %s, want %s", s.Stats().Circuits[""], want)
+		case <-time.After(time.Millisecond):
+		}
+	}
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	s, sink, clock := start(t, asynclog.WithBatchSize(1), asynclog.WithCircuitBreaker(2, time.Minute))
+
+	sink.Fail(errors.New("down"))
+	printAll(t, s, "a", "b")
+	waitCircuit(t, s, asynclog.CircuitOpen)
+
+	// Held while the circuit is open, even though the sink is back.
+	sink.Fail(nil)
+	printAll(t, s, "c")
+	clock.Advance(30 * time.Second)
+	if lines := sink.Lines(); len(lines) != 0 {
+		t.Fatalf("got lines %q during the cool-down, want none", lines)
+	}
+
+	clock.Advance(30 * time.Second)
+	if err := sink.WaitLines(testContext(t), 1); err != nil {
+		t.Fatal(err)
+	}
+	waitCircuit(t, s, asynclog.CircuitClosed)
+	if got, want := sink.Lines(), []string{"c"}; !slices.Equal(got, want) {
+		t.Fatalf("got lines %q, want %q", got, want)
+	}
+}
+
+func TestCircuitBreakerFailedProbe(t *testing.T) {
+	s, sink, clock := start(t, asynclog.WithBatchSize(1), asynclog.WithCircuitBreaker(1, time.Minute))
+
+	sink.Fail(errors.New("down"))
+	printAll(t, s, "a")
+	waitCircuit(t, s, asynclog.CircuitOpen)
+
+	// The probe after the cool-down fails too, failing b and opening the
+	// circuit again.
+	printAll(t, s, "b")
+	clock.Advance(time.Minute)
+	ctx := testContext(t)
+	for s.Stats().FailedRecords < 2 {
+		select {
+		case <-ctx.Done():
+			t.Fatalf("%d failed records, want 2", s.Stats().FailedRecords)
+		case <-time.After(time.Millisecond):
+		}
+	}
+	waitCircuit(t, s, asynclog.CircuitOpen)
+	if writes := sink.Writes(); len(writes) != 0 {
+		t.Fatalf("got writes %q through an open circuit, want none", writes)
+	}
+}
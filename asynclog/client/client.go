@@ -0,0 +1,406 @@
+// Package client submits records to a central asynclog daemon over its HTTP
+// ingestion endpoint, Service.HTTPHandler, batching them locally and
+// retrying while the daemon is busy or unreachable.
+package client
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"test-task-log/asynclog"
+)
+
+// retryBase is the wait before the first retry, doubled for every next one.
+const retryBase = 100 * time.Millisecond
+
+// Client batches records and POSTs them to an ingestion endpoint. Records are
+// sent in one request per level and source, as the endpoint takes those from
+// the query. Requests the daemon answers with 429 or 503 are retried after
+// its Retry-After, and network errors and other 5xx answers with exponential
+// backoff; other answers fail the batch at once.
+type Client struct {
+	url        string
+	http       *http.Client
+	header     http.Header
+	every      time.Duration
+	limit      int
+	maxPending int
+	attempts   int
+	maxDelay   time.Duration
+	onError    func(Batch, error)
+
+	mu      sync.Mutex
+	pending []*Batch
+	n       int
+	closed  bool
+	kick    chan struct{}
+	stop    chan struct{}
+	done    chan struct{}
+	flushes []chan error
+
+	// ctx is cancelled to abandon sending when Close gives up.
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// Batch is a request's worth of records: the messages of one level and
+// source.
+type Batch struct {
+	Level    asynclog.Level
+	Source   string
+	Messages []string
+}
+
+// Option configures a Client in New.
+type Option func(*Client)
+
+// WithHTTPClient sends requests with c instead of http.DefaultClient.
+func WithHTTPClient(c *http.Client) Option {
+	return func(cl *Client) {
+		cl.http = c
+	}
+}
+
+// WithHeader adds h to every request, e.g. for authentication.
+func WithHeader(h http.Header) Option {
+	return func(c *Client) {
+		c.header = h.Clone()
+	}
+}
+
+// WithFlushInterval sets how often buffered records are sent, a second by
+// default. It panics if d is not positive.
+func WithFlushInterval(d time.Duration) Option {
+	if d <= 0 {
+		panic("client: non-positive flush interval")
+	}
+
+	return func(c *Client) {
+		c.every = d
+	}
+}
+
+// WithBatchSize sets how many buffered records trigger a send before the
+// interval is up, 100 by default. It panics if n is not positive.
+func WithBatchSize(n int) Option {
+	if n <= 0 {
+		panic("client: non-positive batch size")
+	}
+
+	return func(c *Client) {
+		c.limit = n
+	}
+}
+
+// WithMaxPending sets how many records are buffered, including those being
+// sent, before Print fails with asynclog.ErrQueueFull, 10000 by default. It
+// panics if n is not positive.
+func WithMaxPending(n int) Option {
+	if n <= 0 {
+		panic("client: non-positive max pending")
+	}
+
+	return func(c *Client) {
+		c.maxPending = n
+	}
+}
+
+// WithRetry sets how many times a request is sent before its batch fails, 5
+// by default, and the longest wait between attempts, 30 seconds by default
+// and no limit when zero. It panics if attempts is not positive or maxDelay
+// is negative.
+func WithRetry(attempts int, maxDelay time.Duration) Option {
+	if attempts <= 0 || maxDelay < 0 {
+		panic(fmt.Sprintf("client: WithRetry: invalid attempts %d or delay %s", attempts, maxDelay))
+	}
+
+	return func(c *Client) {
+		c.attempts = attempts
+		c.maxDelay = maxDelay
+	}
+}
+
+// WithErrorHandler calls fn with every batch that failed, and why. It is
+// called from the sending goroutine and delays further sends.
+func WithErrorHandler(fn func(Batch, error)) Option {
+	return func(c *Client) {
+		c.onError = fn
+	}
+}
+
+// New returns a client sending to the ingestion endpoint at url, and starts
+// the goroutine sending its batches. Close sends what is left and stops it.
+func New(url string, opts ...Option) *Client {
+	c := &Client{
+		url:        url,
+		http:       http.DefaultClient,
+		every:      time.Second,
+		limit:      100,
+		maxPending: 10000,
+		attempts:   5,
+		maxDelay:   30 * time.Second,
+		kick:       make(chan struct{}, 1),
+		stop:       make(chan struct{}),
+		done:       make(chan struct{}),
+	}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	c.ctx, c.cancel = context.WithCancel(context.Background())
+	go c.run()
+
+	return c
+}
+
+// Print buffers msg at LevelInfo with no source.
+func (c *Client) Print(msg string) error {
+	return c.PrintFrom("", asynclog.LevelInfo, msg)
+}
+
+// PrintFrom buffers msg at level from source. The endpoint splits messages
+// on newlines, so a message with newlines arrives as several records. It
+// fails with asynclog.ErrQueueFull while WithMaxPending records are pending,
+// and with asynclog.ErrClosed once the client is closed.
+func (c *Client) PrintFrom(source string, level asynclog.Level, msg string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return asynclog.ErrClosed
+	}
+	if c.n >= c.maxPending {
+		return asynclog.ErrQueueFull
+	}
+
+	var b *Batch
+	for _, p := range c.pending {
+		if p.Level == level && p.Source == source {
+			b = p
+			break
+		}
+	}
+	if b == nil {
+		b = &Batch{Level: level, Source: source}
+		c.pending = append(c.pending, b)
+	}
+	b.Messages = append(b.Messages, msg)
+	c.n++
+
+	if c.buffered() >= c.limit {
+		c.signal()
+	}
+
+	return nil
+}
+
+// buffered is the number of records not yet taken for sending. It is called
+// with mu held.
+func (c *Client) buffered() int {
+	n := 0
+	for _, b := range c.pending {
+		n += len(b.Messages)
+	}
+
+	return n
+}
+
+func (c *Client) signal() {
+	select {
+	case c.kick <- struct{}{}:
+	default:
+	}
+}
+
+// Flush sends what is buffered now and returns once it is sent, with the
+// first error, or when ctx ends.
+func (c *Client) Flush(ctx context.Context) error {
+	errc := make(chan error, 1)
+
+	c.mu.Lock()
+	if c.closed {
+		c.mu.Unlock()
+		return asynclog.ErrClosed
+	}
+	c.flushes = append(c.flushes, errc)
+	c.mu.Unlock()
+	c.signal()
+
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Close stops buffering records and returns once what is buffered is sent,
+// or when ctx ends, in which case sending is abandoned.
+func (c *Client) Close(ctx context.Context) error {
+	c.mu.Lock()
+	if !c.closed {
+		c.closed = true
+		close(c.stop)
+	}
+	c.mu.Unlock()
+
+	select {
+	case <-c.done:
+		return nil
+	case <-ctx.Done():
+		c.cancel()
+		return ctx.Err()
+	}
+}
+
+func (c *Client) run() {
+	defer close(c.done)
+	defer c.cancel()
+	ctx := c.ctx
+
+	t := time.NewTicker(c.every)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-t.C:
+		case <-c.kick:
+		case <-c.stop:
+			c.sendPending(ctx)
+			return
+		}
+
+		c.sendPending(ctx)
+	}
+}
+
+// sendPending sends the buffered batches, answering the flushes waiting for
+// them.
+func (c *Client) sendPending(ctx context.Context) {
+	c.mu.Lock()
+	batches, flushes := c.pending, c.flushes
+	c.pending, c.flushes = nil, nil
+	c.mu.Unlock()
+
+	// Batches grow past the batch size while a send is retried, and are
+	// cut back to it so requests stay small.
+	var first error
+	for _, b := range batches {
+		for len(b.Messages) > 0 {
+			part := *b
+			part.Messages = b.Messages[:min(len(b.Messages), c.limit)]
+			b.Messages = b.Messages[len(part.Messages):]
+
+			err := c.send(ctx, &part)
+			if err != nil && c.onError != nil {
+				c.onError(part, err)
+			}
+			if first == nil {
+				first = err
+			}
+
+			c.mu.Lock()
+			c.n -= len(part.Messages)
+			c.mu.Unlock()
+		}
+	}
+
+	for _, errc := range flushes {
+		errc <- first
+	}
+}
+
+// send POSTs b, retrying as Client describes.
+func (c *Client) send(ctx context.Context, b *Batch) error {
+	u, err := url.Parse(c.url)
+	if err != nil {
+		return err
+	}
+	q := u.Query()
+	q.Set("level", b.Level.String())
+	if b.Source != "" {
+		q.Set("source", b.Source)
+	}
+	u.RawQuery = q.Encode()
+	body := []byte(strings.Join(b.Messages, "\n") + "\n")
+
+	for attempt := 1; ; attempt++ {
+		wait, err := c.post(ctx, u.String(), body)
+		if err == nil || wait < 0 || attempt >= c.attempts {
+			return err
+		}
+
+		if wait == 0 {
+			wait = c.backoff(attempt)
+		} else if c.maxDelay > 0 {
+			wait = min(wait, c.maxDelay)
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		}
+	}
+}
+
+// backoff returns the jittered wait before retrying after the given attempt:
+// somewhere between half and all of the exponential delay, as for batch
+// writes in asynclog.
+func (c *Client) backoff(attempt int) time.Duration {
+	d := retryBase << min(attempt-1, 30)
+	if c.maxDelay > 0 && d > c.maxDelay {
+		d = c.maxDelay
+	}
+
+	return d/2 + rand.N(d/2+1)
+}
+
+// post sends one request. On failure it returns how long the daemon asked
+// to wait, zero to back off as usual, or -1 not to retry.
+func (c *Client) post(ctx context.Context, target string, body []byte) (time.Duration, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(body))
+	if err != nil {
+		return -1, err
+	}
+	for k, vs := range c.header {
+		req.Header[k] = vs
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+	resp.Body.Close()
+
+	if resp.StatusCode/100 == 2 {
+		return 0, nil
+	}
+	err = fmt.Errorf("client: %s: %s", resp.Status, bytes.TrimSpace(msg))
+
+	switch {
+	case resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable:
+		secs, perr := strconv.Atoi(resp.Header.Get("Retry-After"))
+		if perr != nil || secs < 0 {
+			return 0, err
+		}
+		return max(time.Duration(secs)*time.Second, time.Millisecond), err
+	case resp.StatusCode >= 500:
+		return 0, err
+	}
+
+	return -1, err
+}
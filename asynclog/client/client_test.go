@@ -0,0 +1,160 @@
+package client_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"test-task-log/asynclog"
+	"test-task-log/asynclog/client"
+	"test-task-log/asynclog/testutil"
+)
+
+func testContext(t *testing.T) context.Context {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	t.Cleanup(cancel)
+
+	return ctx
+}
+
+// levelSourceEncoder writes the level, source and message of every entry.
+type levelSourceEncoder struct{}
+
+func (levelSourceEncoder) Encode(entries []asynclog.Entry) ([]byte, error) {
+	var b []byte
+	for _, e := range entries {
+		b = fmt.Appendf(b, "%s %s %s\n", e.Level, e.Source, e.Message)
+	}
+
+	return b, nil
+}
+
+func TestClientToHandler(t *testing.T) {
+	sink := testutil.NewSink()
+	s := asynclog.NewService(sink, asynclog.WithEncoder(levelSourceEncoder{}))
+	go s.Run(context.Background())
+	srv := httptest.NewServer(s.HTTPHandler())
+	defer srv.Close()
+
+	c := client.New(srv.URL, client.WithFlushInterval(time.Hour))
+	for _, err := range []error{
+		c.Print("a"),
+		c.PrintFrom("api", asynclog.LevelWarn, "b"),
+		c.Print("c"),
+	} {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := c.Flush(testContext(t)); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Close(testContext(t)); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Print("late"); !errors.Is(err, asynclog.ErrClosed) {
+		t.Fatalf("Print after Close: got %v, want ErrClosed", err)
+	}
+
+	if err := s.Shutdown(testContext(t)); err != nil {
+		t.Fatal(err)
+	}
+	got := sink.Lines()
+	slices.Sort(got)
+	if want := []string{"INFO  a", "INFO  c", "WARN api b"}; !slices.Equal(got, want) {
+		t.Fatalf("got lines %q, want %q", got, want)
+	}
+}
+
+func TestClientRetryAfter(t *testing.T) {
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) == 1 {
+			w.Header().Set("Retry-After", "0")
+			w.WriteHeader(http.StatusTooManyRequests)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	c := client.New(srv.URL, client.WithFlushInterval(time.Hour))
+	defer c.Close(context.Background())
+
+	if err := c.Print("a"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Flush(testContext(t)); err != nil {
+		t.Fatal(err)
+	}
+	if n := requests.Load(); n != 2 {
+		t.Fatalf("got %d requests, want a retry after the 429", n)
+	}
+}
+
+func TestClientRejected(t *testing.T) {
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		http.Error(w, "bad level", http.StatusBadRequest)
+	}))
+	defer srv.Close()
+
+	var failed []client.Batch
+	c := client.New(srv.URL, client.WithFlushInterval(time.Hour), client.WithRetry(3, time.Millisecond),
+		client.WithErrorHandler(func(b client.Batch, err error) { failed = append(failed, b) }))
+	defer c.Close(context.Background())
+
+	if err := c.Print("a"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Flush(testContext(t)); err == nil {
+		t.Fatal("Flush succeeded on a 400")
+	}
+	if n := requests.Load(); n != 1 {
+		t.Fatalf("got %d requests, want no retry of a 400", n)
+	}
+	if len(failed) != 1 || !slices.Equal(failed[0].Messages, []string{"a"}) {
+		t.Fatalf("error handler got %+v, want the batch with a", failed)
+	}
+}
+
+func TestClientBackoffGivesUp(t *testing.T) {
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer srv.Close()
+
+	c := client.New(srv.URL, client.WithFlushInterval(time.Hour), client.WithRetry(3, time.Millisecond))
+	defer c.Close(context.Background())
+
+	if err := c.Print("a"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Flush(testContext(t)); err == nil {
+		t.Fatal("Flush succeeded on a 502")
+	}
+	if n := requests.Load(); n != 3 {
+		t.Fatalf("got %d requests, want 3 attempts", n)
+	}
+}
+
+func TestClientMaxPending(t *testing.T) {
+	c := client.New("http://127.0.0.1:0", client.WithFlushInterval(time.Hour), client.WithMaxPending(1), client.WithRetry(1, 0))
+	defer c.Close(context.Background())
+
+	if err := c.Print("a"); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Print("b"); !errors.Is(err, asynclog.ErrQueueFull) {
+		t.Fatalf("got %v, want ErrQueueFull", err)
+	}
+}
@@ -0,0 +1,48 @@
+package asynclog
+
+import "time"
+
+// Clock is the time source of a service: the time records are stamped with
+// and aged by, the ticker Run flushes on, the WithCoalescing timer, rate
+// limits, retry back-off, the WithHealthProbe interval, the wait of
+// OverflowBlockWithTimeout, the WithWriteWatchdog deadline, and the windows of
+// quotas, adaptive sampling, circuit breakers, throughput and write
+// latencies. Write timeouts and the timeouts of probes keep using real time,
+// being deadlines on the writers. It must be safe for concurrent use.
+type Clock interface {
+	Now() time.Time
+	NewTicker(d time.Duration) Ticker
+	After(d time.Duration) <-chan time.Time
+}
+
+// Ticker is a ticker made by a Clock, as time.Ticker.
+type Ticker interface {
+	C() <-chan time.Time
+	Reset(d time.Duration)
+	Stop()
+}
+
+// WithClock makes the service take time from c instead of the system clock,
+// e.g. testutil.Clock to test flushing on the interval without sleeping.
+func WithClock(c Clock) Option {
+	return func(s *Service) {
+		s.clock = c
+	}
+}
+
+// systemClock is the Clock backed by package time.
+type systemClock struct{}
+
+func (systemClock) Now() time.Time { return time.Now() }
+
+func (systemClock) NewTicker(d time.Duration) Ticker { return systemTicker{time.NewTicker(d)} }
+
+func (systemClock) After(d time.Duration) <-chan time.Time { return time.After(d) }
+
+type systemTicker struct{ t *time.Ticker }
+
+func (t systemTicker) C() <-chan time.Time { return t.t.C }
+
+func (t systemTicker) Reset(d time.Duration) { t.t.Reset(d) }
+
+func (t systemTicker) Stop() { t.t.Stop() }
@@ -0,0 +1,130 @@
+package asynclog
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+// restartEvery is how often CommandSink restarts a command that keeps exiting.
+const restartEvery = time.Second
+
+// CommandSink writes batches to the stdin of a long-running command, for
+// shipping logs with a bespoke tool without writing Go. The command is started
+// on the first write and restarted when it exits, at most once per second;
+// its own output goes to stderr. Batches written while it is down fail.
+//
+// OpenSink builds one from URIs such as exec:///usr/bin/shipper?arg=-q, with
+// one arg parameter per argument.
+type CommandSink struct {
+	name string
+	args []string
+
+	mu      sync.Mutex
+	proc    *process
+	started time.Time
+	closed  bool
+}
+
+// process is one run of the command.
+type process struct {
+	stdin  io.WriteCloser
+	exited chan struct{}
+	err    error // set once exited is closed
+}
+
+func NewCommandSink(name string, args ...string) *CommandSink {
+	return &CommandSink{name: name, args: args}
+}
+
+// Write writes p to the command's stdin, restarting the command first if it
+// has exited.
+func (c *CommandSink) Write(p []byte) (int, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.closed {
+		return 0, ErrClosed
+	}
+
+	if err := c.ensure(); err != nil {
+		return 0, err
+	}
+
+	return c.proc.stdin.Write(p)
+}
+
+// ensure starts the command unless it is running.
+func (c *CommandSink) ensure() error {
+	if c.proc != nil {
+		select {
+		case <-c.proc.exited:
+			c.proc.stdin.Close()
+			c.proc = nil
+		default:
+			return nil
+		}
+	}
+
+	if wait := restartEvery - time.Since(c.started); wait > 0 {
+		return fmt.Errorf("asynclog: command %s exited, restarting in %s", c.name, wait.Round(time.Millisecond))
+	}
+	c.started = time.Now()
+
+	cmd := exec.Command(c.name, c.args...)
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	proc := &process{stdin: stdin, exited: make(chan struct{})}
+	go func() {
+		proc.err = cmd.Wait()
+		close(proc.exited)
+	}()
+	c.proc = proc
+
+	return nil
+}
+
+// Close closes the command's stdin and waits for it to exit, returning its
+// error if it failed.
+func (c *CommandSink) Close() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.closed = true
+	if c.proc == nil {
+		return nil
+	}
+
+	err := c.proc.stdin.Close()
+	<-c.proc.exited
+	err = errors.Join(err, c.proc.err)
+	c.proc = nil
+
+	return err
+}
+
+func openCommandSink(u *url.URL) (io.Writer, error) {
+	if err := checkParams(u, "arg"); err != nil {
+		return nil, err
+	}
+
+	if u.Path == "" {
+		return nil, fmt.Errorf("missing command")
+	}
+
+	return NewCommandSink(u.Path, u.Query()["arg"]...), nil
+}
@@ -0,0 +1,71 @@
+package asynclog_test
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"test-task-log/asynclog"
+)
+
+func TestCommandSink(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "out")
+
+	// The command takes one line per run, so every batch after the first
+	// waits for a restart.
+	c := asynclog.NewCommandSink("sh", "-c", `read l; echo "$l" >> "$0"`, out)
+	if _, err := c.Write([]byte("a\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := testContext(t)
+	restarting := false
+	for {
+		_, err := c.Write([]byte("b\n"))
+		if err != nil && strings.Contains(err.Error(), "restarting") {
+			restarting = true
+		}
+		if err == nil && restarting {
+			break
+		}
+
+		select {
+		case <-ctx.Done():
+			t.Fatalf("command never restarted, last error %v", err)
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+	if err := c.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Fields(string(b)); len(lines) != 2 || lines[0] != "a" || lines[1] != "b" {
+		t.Fatalf("got %q from the command's runs, want a then b", b)
+	}
+	if _, err := c.Write([]byte("c\n")); !errors.Is(err, asynclog.ErrClosed) {
+		t.Fatalf("Write after Close: got %v, want ErrClosed", err)
+	}
+}
+
+func TestCommandSinkErrors(t *testing.T) {
+	if _, err := asynclog.NewCommandSink("/nonexistent/command").Write([]byte("a\n")); err == nil {
+		t.Fatal("wrote to a missing command")
+	}
+
+	c := asynclog.NewCommandSink("sh", "-c", "cat >/dev/null; exit 3")
+	if _, err := c.Write([]byte("a\n")); err != nil {
+		t.Fatal(err)
+	}
+	var exitErr *exec.ExitError
+	if err := c.Close(); !errors.As(err, &exitErr) || exitErr.ExitCode() != 3 {
+		t.Fatalf("Close: got %v, want the exit status 3", err)
+	}
+}
@@ -0,0 +1,53 @@
+package asynclog
+
+import (
+	"fmt"
+	"time"
+)
+
+// WithCoalescing holds back a flush for reaching the batch size until d has
+// passed since the first record went into the empty buffer, so bursts of
+// tiny writes on fast sinks coalesce into fewer, larger batches. Records
+// keep being buffered meanwhile. Flushes for any other reason, including
+// WithMaxBatchBytes, aren't held back. It panics if d is not positive.
+func WithCoalescing(d time.Duration) Option {
+	if d <= 0 {
+		panic(fmt.Sprintf("asynclog: WithCoalescing: non-positive window %s", d))
+	}
+
+	return func(s *Service) {
+		s.coalesce = d
+	}
+}
+
+// coalescing reports whether the flush of b for reaching the batch size is
+// held back, arming the timer flushing it once the window is over.
+func (s *Service) coalescing(b *batch) bool {
+	if s.coalesce <= 0 {
+		return false
+	}
+
+	wait := s.coalesce - s.clock.Now().Sub(b.first)
+	if wait <= 0 {
+		return false
+	}
+
+	if s.coalesceC == nil {
+		s.coalesceC = s.clock.After(wait)
+	}
+
+	return true
+}
+
+// coalesced flushes the buffers that reached the batch size once the window
+// is over, arming the timer again for those still within theirs. It runs in
+// Run.
+func (s *Service) coalesced() {
+	s.coalesceC = nil
+
+	for _, b := range s.buffers() {
+		if len(b.records) >= s.writeLimit && !s.coalescing(b) {
+			s.flush("count", b)
+		}
+	}
+}
@@ -0,0 +1,40 @@
+package asynclog_test
+
+import (
+	"testing"
+	"time"
+
+	"test-task-log/asynclog"
+)
+
+func TestCoalescing(t *testing.T) {
+	s, sink, clock := start(t, asynclog.WithBatchSize(2), asynclog.WithCoalescing(time.Second))
+
+	// The batch size is reached within the window, so the flush waits for it
+	// to end and takes everything buffered by then.
+	printAll(t, s, "a", "b", "c")
+	if err := clock.WaitTimers(testContext(t), 1); err != nil {
+		t.Fatal(err)
+	}
+	waitBuffered(t, s)
+	if got := sink.Writes(); len(got) != 0 {
+		t.Fatalf("got writes %q within the window, want none", got)
+	}
+
+	clock.Advance(time.Second)
+	if err := sink.WaitWrites(testContext(t), 1); err != nil {
+		t.Fatal(err)
+	}
+	if got := sink.Writes(); len(got) != 1 || string(got[0]) != "a\nb\nc\n" {
+		t.Fatalf("got writes %q, want one of the whole burst", got)
+	}
+}
+
+func TestCoalescingInvalid(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("WithCoalescing(0) didn't panic")
+		}
+	}()
+	asynclog.WithCoalescing(0)
+}
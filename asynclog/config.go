@@ -0,0 +1,352 @@
+package asynclog
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Config describes a service in a JSON file:
+//
+//	{
+//		"writer": "stdout:",
+//		"flush_interval": "5s",
+//		"batch_size": 10,
+//		"max_batch_bytes": 1048576,
+//		"level": "INFO",
+//		"encoder": "json",
+//		"filter": "level >= INFO",
+//		"sinks": {"errors": "file:///var/log/errors.log"},
+//		"sink_batching": {"errors": {"flush_interval": "200ms"}},
+//		"routes": [
+//			{"sink": "errors", "level": "WARN"},
+//			{"sink": "errors", "source": "payments", "msg_regex": "declined"},
+//			{"sink": "errors", "fields": {"tenant": "acme"}},
+//			{"sink": "errors", "expr": "fields.status =~ \"^5\""}
+//		]
+//	}
+//
+// Writer and sinks are URIs opened with OpenSink. Routes send matching
+// records to a named sink; a sink with several routes gets records matching
+// any of them, and sinks without routes get everything. Level is the minimum
+// level of the whole service. Sinks listed in sink_batching are batched on
+// their own, see Service.SetSinkBatching.
+type Config struct {
+	Writer        string              `json:"writer"`
+	FlushInterval Duration            `json:"flush_interval"`
+	BatchSize     int                 `json:"batch_size"`
+	MaxBatchBytes int                 `json:"max_batch_bytes"`
+	Level         string              `json:"level"`
+	Encoder       string              `json:"encoder"`
+	Filter        string              `json:"filter"`
+	Sinks         map[string]string   `json:"sinks"`
+	Routes        []Route             `json:"routes"`
+	SinkBatching  map[string]Batching `json:"sink_batching"`
+}
+
+// Batching is the flush interval and batch size of a sink batched on its own.
+// Zero uses the service's.
+type Batching struct {
+	FlushInterval Duration `json:"flush_interval"`
+	BatchSize     int      `json:"batch_size"`
+}
+
+// Route matches records for a sink. All set matchers must match: Level is the
+// minimum level, Source and Msg must be equal, the regex variants must match,
+// and every record field in Fields must have the given value. Expr is a full
+// expression as accepted by CompileExpr, ANDed with the rest.
+type Route struct {
+	Sink        string            `json:"sink"`
+	Level       string            `json:"level,omitempty"`
+	Source      string            `json:"source,omitempty"`
+	SourceRegex string            `json:"source_regex,omitempty"`
+	Msg         string            `json:"msg,omitempty"`
+	MsgRegex    string            `json:"msg_regex,omitempty"`
+	Fields      map[string]string `json:"fields,omitempty"`
+	Expr        string            `json:"expr,omitempty"`
+}
+
+// Duration is a time.Duration written as a string such as "5s" in JSON.
+type Duration time.Duration
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	*d = Duration(v)
+
+	return nil
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// LoadConfig reads and validates a config file.
+func LoadConfig(path string) (*Config, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return ParseConfig(f)
+}
+
+// ParseConfig reads and validates a config from r.
+func ParseConfig(r io.Reader) (*Config, error) {
+	var c Config
+
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&c); err != nil {
+		return nil, fmt.Errorf("asynclog: config: %w", err)
+	}
+
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &c, nil
+}
+
+// Validate checks the config without opening any sink.
+func (c *Config) Validate() error {
+	if c.FlushInterval < 0 {
+		return fmt.Errorf("asynclog: config: negative flush_interval")
+	}
+
+	if c.BatchSize < 0 {
+		return fmt.Errorf("asynclog: config: negative batch_size")
+	}
+
+	if c.MaxBatchBytes < 0 {
+		return fmt.Errorf("asynclog: config: negative max_batch_bytes")
+	}
+
+	if c.Level != "" {
+		if _, err := ParseLevel(c.Level); err != nil {
+			return fmt.Errorf("asynclog: config: %w", err)
+		}
+	}
+
+	if c.Encoder != "" {
+		if _, err := ParseEncoder(c.Encoder); err != nil {
+			return fmt.Errorf("asynclog: config: %w", err)
+		}
+	}
+
+	if c.Filter != "" {
+		if _, err := CompileExpr(c.Filter); err != nil {
+			return fmt.Errorf("asynclog: config: filter: %w", err)
+		}
+	}
+
+	for name, b := range c.SinkBatching {
+		if _, ok := c.Sinks[name]; !ok {
+			return fmt.Errorf("asynclog: config: sink_batching: unknown sink %q", name)
+		}
+		if b.FlushInterval < 0 || b.BatchSize < 0 {
+			return fmt.Errorf("asynclog: config: sink_batching: negative batching for %q", name)
+		}
+	}
+
+	_, err := c.compileRoutes()
+
+	return err
+}
+
+// compileRoutes turns the routes into one expression per sink.
+func (c *Config) compileRoutes() (map[string]*Expr, error) {
+	bySink := make(map[string][]string)
+	for i, r := range c.Routes {
+		if _, ok := c.Sinks[r.Sink]; !ok {
+			return nil, fmt.Errorf("asynclog: config: route %d: unknown sink %q", i, r.Sink)
+		}
+
+		src, err := r.expr()
+		if err != nil {
+			return nil, fmt.Errorf("asynclog: config: route %d: %w", i, err)
+		}
+
+		bySink[r.Sink] = append(bySink[r.Sink], "("+src+")")
+	}
+
+	routes := make(map[string]*Expr, len(bySink))
+	for sink, srcs := range bySink {
+		e, err := CompileExpr(strings.Join(srcs, " || "))
+		if err != nil {
+			return nil, fmt.Errorf("asynclog: config: routes for %q: %w", sink, err)
+		}
+
+		routes[sink] = e
+	}
+
+	return routes, nil
+}
+
+func (r Route) expr() (string, error) {
+	var conds []string
+	if r.Level != "" {
+		l, err := ParseLevel(r.Level)
+		if err != nil {
+			return "", err
+		}
+		conds = append(conds, "level >= "+l.String())
+	}
+
+	for _, m := range []struct{ field, equals, pattern string }{
+		{"source", r.Source, r.SourceRegex},
+		{"msg", r.Msg, r.MsgRegex},
+	} {
+		if m.equals != "" {
+			conds = append(conds, m.field+" == "+strconv.Quote(m.equals))
+		}
+		if m.pattern != "" {
+			if _, err := regexp.Compile(m.pattern); err != nil {
+				return "", err
+			}
+			conds = append(conds, m.field+" =~ "+strconv.Quote(m.pattern))
+		}
+	}
+
+	keys := make([]string, 0, len(r.Fields))
+	for k := range r.Fields {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	for _, k := range keys {
+		conds = append(conds, "fields."+k+" == "+strconv.Quote(r.Fields[k]))
+	}
+
+	if r.Expr != "" {
+		conds = append(conds, "("+r.Expr+")")
+	}
+
+	if len(conds) == 0 {
+		return "true", nil
+	}
+
+	return strings.Join(conds, " && "), nil
+}
+
+// NewService validates the config, opens its writer and sinks and returns a
+// service set up with its options, sinks and routes. opts are applied after
+// the config's own options. If anything fails, the writers opened so far are
+// closed again; otherwise they are closed by the Reload replacing them.
+func (c *Config) NewService(opts ...Option) (*Service, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+
+	var copts []Option
+	if c.FlushInterval > 0 {
+		copts = append(copts, WithFlushInterval(time.Duration(c.FlushInterval)))
+	}
+	if c.BatchSize > 0 {
+		copts = append(copts, WithBatchSize(c.BatchSize))
+	}
+	if c.MaxBatchBytes > 0 {
+		copts = append(copts, WithMaxBatchBytes(c.MaxBatchBytes))
+	}
+	if c.Level != "" {
+		level, err := ParseLevel(c.Level)
+		if err != nil {
+			return nil, err
+		}
+		copts = append(copts, WithMinLevel(level))
+	}
+	if c.Encoder != "" {
+		enc, err := ParseEncoder(c.Encoder)
+		if err != nil {
+			return nil, err
+		}
+		copts = append(copts, WithEncoder(enc))
+	}
+	if c.Filter != "" {
+		filter, err := CompileExpr(c.Filter)
+		if err != nil {
+			return nil, err
+		}
+		copts = append(copts, WithFilter(filter))
+	}
+
+	uri := c.Writer
+	if uri == "" {
+		uri = "stdout:"
+	}
+	w, err := OpenSink(uri)
+	if err != nil {
+		return nil, err
+	}
+
+	opened := []io.Writer{w}
+	sinks := make(map[string]io.Writer, len(c.Sinks))
+	for name, uri := range c.Sinks {
+		sw, err := OpenSink(uri)
+		if err != nil {
+			closeOpened(opened)
+			return nil, err
+		}
+		opened = append(opened, sw)
+		sinks[name] = sw
+	}
+
+	s := NewService(w, append(copts, opts...)...)
+	fail := func(err error) (*Service, error) {
+		for _, sk := range s.sinks {
+			closeQueued(sk.writer)
+		}
+		closeOpened(opened)
+		return nil, err
+	}
+
+	for name, sw := range sinks {
+		if err := s.AddSink(name, sw); err != nil {
+			return fail(err)
+		}
+
+		if b, ok := c.SinkBatching[name]; ok {
+			if err := s.SetSinkBatching(name, time.Duration(b.FlushInterval), b.BatchSize); err != nil {
+				return fail(err)
+			}
+		}
+	}
+
+	if err := s.ApplyRoutes(c); err != nil {
+		return fail(err)
+	}
+	s.opened = opened
+
+	return s, nil
+}
+
+// ApplyRoutes replaces the routes of the service's sinks with those of c,
+// leaving sinks c has no routes for unrouted. The routes are all compiled
+// before any is applied, so an invalid config changes nothing.
+func (s *Service) ApplyRoutes(c *Config) error {
+	routes, err := c.compileRoutes()
+	if err != nil {
+		return err
+	}
+
+	for _, name := range s.Sinks() {
+		if err := s.RouteSink(name, routes[name]); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,106 @@
+package asynclog_test
+
+import (
+	"errors"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"test-task-log/asynclog"
+)
+
+type closeCounter struct {
+	io.Writer
+	closed *atomic.Int32
+}
+
+func (c closeCounter) Close() error {
+	c.closed.Add(1)
+	return nil
+}
+
+func TestConfigNewServiceBadFilter(t *testing.T) {
+	c := &asynclog.Config{Filter: "level >>> ("}
+	if _, err := c.NewService(); err == nil {
+		t.Fatal("invalid filter accepted")
+	}
+}
+
+func TestConfigNewServiceClosesOnFailure(t *testing.T) {
+	var closed atomic.Int32
+	asynclog.RegisterSink("closetest", func(*url.URL) (io.Writer, error) {
+		return closeCounter{Writer: io.Discard, closed: &closed}, nil
+	})
+	asynclog.RegisterSink("failtest", func(*url.URL) (io.Writer, error) {
+		return nil, errors.New("unreachable")
+	})
+
+	c := &asynclog.Config{
+		Writer: "closetest:",
+		Sinks:  map[string]string{"broken": "failtest:"},
+	}
+	if _, err := c.NewService(); err == nil {
+		t.Fatal("failing sink accepted")
+	}
+	if n := closed.Load(); n != 1 {
+		t.Fatalf("writer closed %d times, want once", n)
+	}
+
+	c.Sinks = map[string]string{"ok": "closetest:"}
+	s, err := c.NewService()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := s.Sinks(); len(got) != 1 || got[0] != "ok" {
+		t.Fatalf("got sinks %q, want ok", got)
+	}
+}
+
+func TestParseConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cfg.json")
+	os.WriteFile(path, []byte(`{
+		"writer": "stdout:",
+		"flush_interval": "2s",
+		"batch_size": 50,
+		"level": "warn",
+		"sinks": {"errors": "stderr:"},
+		"routes": [{"sink": "errors", "level": "error"}],
+		"sink_batching": {"errors": {"flush_interval": "100ms"}}
+	}`), 0o644)
+
+	c, err := asynclog.LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if time.Duration(c.FlushInterval) != 2*time.Second || c.BatchSize != 50 || c.Level != "warn" ||
+		c.Sinks["errors"] != "stderr:" || len(c.Routes) != 1 || time.Duration(c.SinkBatching["errors"].FlushInterval) != 100*time.Millisecond {
+		t.Fatalf("got %+v, want the file's settings", c)
+	}
+}
+
+func TestParseConfigErrors(t *testing.T) {
+	for _, src := range []string{
+		`{"writer": "stdout:", "typo": 1}`,
+		`{"flush_interval": "soon"}`,
+		`{"flush_interval": "-1s"}`,
+		`{"batch_size": -1}`,
+		`{"level": "loud"}`,
+		`{"encoder": "xml"}`,
+		`{"routes": [{"sink": "nowhere"}]}`,
+		`{"sinks": {"a": "stdout:"}, "routes": [{"sink": "a", "msg_regex": "("}]}`,
+		`{"sink_batching": {"nowhere": {}}}`,
+		`{`,
+	} {
+		if _, err := asynclog.ParseConfig(strings.NewReader(src)); err == nil {
+			t.Errorf("ParseConfig(%s) succeeded", src)
+		}
+	}
+	if _, err := asynclog.LoadConfig(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("loaded a missing config")
+	}
+}
@@ -0,0 +1,81 @@
+package asynclog
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+// TailContainer is Tail for container log files, as written by Docker's
+// json-file driver ({"log":"...","stream":"stdout","time":"..."}) or by CRI
+// runtimes such as containerd ("2006-01-02T15:04:05.999999999Z stdout F ...").
+// The format is detected per line. Every record gets a stream field and its
+// timestamp from the runtime, lines split by the runtime are joined back, and
+// stderr lines are logged at LevelError.
+func (s *Service) TailContainer(ctx context.Context, path string, fromStart bool) error {
+	partial := map[string]string{}
+
+	return s.tail(ctx, path, fromStart, func(line string) (record, bool) {
+		stream, ts, msg, full, ok := parseContainerLine(line)
+		if !ok {
+			return record{level: LevelInfo, source: path, msg: line}, true
+		}
+
+		if !full {
+			partial[stream] += msg
+			return record{}, false
+		}
+
+		msg = partial[stream] + msg
+		delete(partial, stream)
+
+		level := LevelInfo
+		if stream == "stderr" {
+			level = LevelError
+		}
+
+		return record{
+			level:  level,
+			source: path,
+			time:   ts,
+			msg:    msg,
+			fields: []Field{{Key: "stream", Value: stream}},
+		}, true
+	})
+}
+
+// parseContainerLine splits a json-file or CRI log line. full is false for
+// the leading parts of a line the runtime split up.
+func parseContainerLine(line string) (stream string, ts time.Time, msg string, full, ok bool) {
+	if strings.HasPrefix(line, "{") {
+		var l struct {
+			Log    string    `json:"log"`
+			Stream string    `json:"stream"`
+			Time   time.Time `json:"time"`
+		}
+		if err := json.Unmarshal([]byte(line), &l); err != nil || l.Stream == "" {
+			return "", time.Time{}, "", false, false
+		}
+
+		msg, full = strings.CutSuffix(l.Log, "\n")
+
+		return l.Stream, l.Time, msg, full, true
+	}
+
+	parts := strings.SplitN(line, " ", 4)
+	if len(parts) < 3 || (parts[2] != "F" && parts[2] != "P") {
+		return "", time.Time{}, "", false, false
+	}
+
+	ts, err := time.Parse(time.RFC3339Nano, parts[0])
+	if err != nil {
+		return "", time.Time{}, "", false, false
+	}
+
+	if len(parts) == 4 {
+		msg = parts[3]
+	}
+
+	return parts[1], ts, msg, parts[2] == "F", true
+}
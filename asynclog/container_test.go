@@ -0,0 +1,68 @@
+package asynclog_test
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"test-task-log/asynclog"
+)
+
+func TestTailContainer(t *testing.T) {
+	s, sink, _ := start(t, asynclog.WithBatchSize(5), asynclog.WithEncoder(asynclog.LogfmtEncoder{}))
+	path := filepath.Join(t.TempDir(), "ctr.log")
+	appendFile(t, path, strings.Join([]string{
+		`{"log":"hello\n","stream":"stdout","time":"2024-05-01T10:00:00Z"}`,
+		`{"log":"par","stream":"stderr","time":"2024-05-01T10:00:01Z"}`,
+		`2024-05-01T10:00:02Z stdout F from cri`,
+		`{"log":"tial\n","stream":"stderr","time":"2024-05-01T10:00:03Z"}`,
+		`2024-05-01T10:00:04Z stdout P split `,
+		`2024-05-01T10:00:05Z stdout F up`,
+		`{not json`,
+	}, "\n")+"\n")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- s.TailContainer(ctx, path, true) }()
+
+	if err := sink.WaitLines(testContext(t), 5); err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Fatalf("got %v, want context.Canceled", err)
+	}
+
+	// Lines split by the runtime are joined back, and lines in neither
+	// format are passed through as they are.
+	want := []string{
+		`time=2024-05-01T10:00:00Z level=INFO msg=hello stream=stdout`,
+		`time=2024-05-01T10:00:02Z level=INFO msg="from cri" stream=stdout`,
+		`time=2024-05-01T10:00:03Z level=ERROR msg=partial stream=stderr`,
+		`time=2024-05-01T10:00:05Z level=INFO msg="split up" stream=stdout`,
+		`time=2024-01-01T00:00:00Z level=INFO msg="{not json"`,
+	}
+	got := sink.Lines()
+	for i := range got {
+		got[i] = strings.Replace(got[i], " source="+path, "", 1)
+	}
+	if !slices.Equal(got, want) {
+		t.Fatalf("got lines\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestTailContainerClosed(t *testing.T) {
+	s, _, _ := start(t)
+	path := filepath.Join(t.TempDir(), "ctr.log")
+	if err := s.Shutdown(testContext(t)); err != nil {
+		t.Fatal(err)
+	}
+
+	appendFile(t, path, `{"log":"late\n","stream":"stdout","time":"2024-05-01T10:00:00Z"}`+"\n")
+	if err := s.TailContainer(testContext(t), path, true); !errors.Is(err, asynclog.ErrClosed) {
+		t.Fatalf("got %v, want ErrClosed", err)
+	}
+}
@@ -0,0 +1,142 @@
+package asynclog
+
+import (
+	"context"
+	"encoding/hex"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// CorrelationFormat is a way of carrying a correlation ID in request headers,
+// and the field records carrying it get.
+type CorrelationFormat interface {
+	// Extract returns the ID carried by h, if any.
+	Extract(h http.Header) (id string, ok bool)
+	// Inject sets id in h.
+	Inject(h http.Header, id string)
+	// Field is the name of the record field the ID goes in.
+	Field() string
+}
+
+// TraceParent is the W3C Trace Context traceparent header, such as
+// 00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01. The trace ID is
+// the correlation ID, in the trace_id field.
+type TraceParent struct{}
+
+func (TraceParent) Extract(h http.Header) (string, bool) {
+	parts := strings.Split(h.Get("traceparent"), "-")
+	if len(parts) < 4 || len(parts[0]) != 2 || parts[0] == "ff" || len(parts[1]) != 32 || len(parts[2]) != 16 {
+		return "", false
+	}
+
+	id := strings.ToLower(parts[1])
+	if _, err := hex.DecodeString(id); err != nil || id == strings.Repeat("0", 32) {
+		return "", false
+	}
+
+	return id, true
+}
+
+// Inject sets a traceparent with id as the trace ID, keeping the parent ID
+// and flags of the one already in h if it has the same trace ID.
+func (tp TraceParent) Inject(h http.Header, id string) {
+	if cur, ok := tp.Extract(h); ok && cur == id {
+		return
+	}
+
+	h.Set("traceparent", "00-"+id+"-"+strings.Repeat("0", 15)+"1-01")
+}
+
+func (TraceParent) Field() string { return "trace_id" }
+
+// RequestID is the X-Request-ID header, in the request_id field.
+var RequestID = HeaderCorrelation{Header: "X-Request-ID", FieldName: "request_id"}
+
+// HeaderCorrelation carries the ID as is in a header of the organization's
+// choosing, such as X-Correlation-ID.
+type HeaderCorrelation struct {
+	Header    string
+	FieldName string
+}
+
+func (hc HeaderCorrelation) Extract(h http.Header) (string, bool) {
+	id := strings.TrimSpace(h.Get(hc.Header))
+
+	return id, id != ""
+}
+
+func (hc HeaderCorrelation) Inject(h http.Header, id string) {
+	h.Set(hc.Header, id)
+}
+
+func (hc HeaderCorrelation) Field() string { return hc.FieldName }
+
+// WithCorrelation makes records carry the correlation ID of their context,
+// set with ContextWithCorrelationID, in a field named by the first of
+// formats. HTTPHandler takes the ID from the first of formats found in the
+// request headers, puts it in the field of that format and echoes it in the
+// response headers.
+func WithCorrelation(formats ...CorrelationFormat) Option {
+	return func(s *Service) {
+		s.correlation = formats
+	}
+}
+
+type correlationKey struct{}
+
+// correlated is a correlation ID in a context, with the field it goes in if
+// a format other than the service's first one says so.
+type correlated struct {
+	field string
+	id    string
+}
+
+// ContextWithCorrelationID returns a copy of ctx carrying id, for records
+// enqueued with it under WithCorrelation.
+func ContextWithCorrelationID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, correlationKey{}, correlated{id: id})
+}
+
+// CorrelationID returns the correlation ID carried by ctx, if any.
+func CorrelationID(ctx context.Context) string {
+	c, _ := ctx.Value(correlationKey{}).(correlated)
+
+	return c.id
+}
+
+// extractCorrelation returns ctx carrying the ID of the first format found
+// in h, and sets it in the response headers out.
+func (s *Service) extractCorrelation(ctx context.Context, h, out http.Header) context.Context {
+	for _, f := range s.correlation {
+		if id, ok := f.Extract(h); ok {
+			f.Inject(out, id)
+			return context.WithValue(ctx, correlationKey{}, correlated{field: f.Field(), id: id})
+		}
+	}
+
+	return ctx
+}
+
+// correlate adds the correlation ID of ctx to rec, unless rec already has the
+// field.
+func (s *Service) correlate(ctx context.Context, rec *record) {
+	if len(s.correlation) == 0 {
+		return
+	}
+
+	c, ok := ctx.Value(correlationKey{}).(correlated)
+	if !ok || c.id == "" {
+		return
+	}
+
+	field := c.field
+	if field == "" {
+		field = s.correlation[0].Field()
+	}
+	if slices.ContainsFunc(rec.fields, func(f Field) bool { return f.Key == field }) {
+		return
+	}
+
+	rec.fields = append(slices.Clip(rec.fields), Field{Key: field, Value: c.id})
+}
@@ -0,0 +1,73 @@
+package asynclog_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"test-task-log/asynclog"
+)
+
+func TestTraceParentExtract(t *testing.T) {
+	const id = "4bf92f3577b34da6a3ce929d0e0e4736"
+	for header, want := range map[string]string{
+		"00-" + id + "-00f067aa0ba902b7-01":                      id,
+		"00-" + strings.ToUpper(id) + "-00f067aa0ba902b7-01":     id,
+		"ff-" + id + "-00f067aa0ba902b7-01":                      "",
+		"00-" + strings.Repeat("0", 32) + "-00f067aa0ba902b7-01": "",
+		"00-" + id[:30] + "zz-00f067aa0ba902b7-01":               "",
+		"00-" + id + "-00f067aa-01":                              "",
+		"":                                                       "",
+	} {
+		got, ok := asynclog.TraceParent{}.Extract(http.Header{"Traceparent": {header}})
+		if got != want || ok != (want != "") {
+			t.Errorf("Extract(%q) = %q, %v, want %q", header, got, ok, want)
+		}
+	}
+}
+
+func TestCorrelation(t *testing.T) {
+	const trace = "4bf92f3577b34da6a3ce929d0e0e4736"
+	s, sink, _ := start(t, asynclog.WithEncoder(asynclog.LogfmtEncoder{}), asynclog.WithCorrelation(asynclog.TraceParent{}, asynclog.RequestID))
+
+	for _, tc := range []struct {
+		header http.Header
+		echo   string
+	}{
+		{http.Header{"Traceparent": {"00-" + trace + "-00f067aa0ba902b7-01"}, "X-Request-Id": {"req-1"}}, "Traceparent"},
+		{http.Header{"X-Request-Id": {"req-2"}}, "X-Request-Id"},
+		{http.Header{"Traceparent": {"00-bogus"}}, ""},
+	} {
+		r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader("ingested\n"))
+		r.Header = tc.header
+		rec := httptest.NewRecorder()
+		s.HTTPHandler().ServeHTTP(rec, r)
+		if rec.Code != http.StatusNoContent {
+			t.Fatalf("got status %d, want 204", rec.Code)
+		}
+		if tc.echo != "" && rec.Header().Get(tc.echo) == "" {
+			t.Fatalf("response headers %v don't echo %s", rec.Header(), tc.echo)
+		}
+		if tc.echo == "" && len(rec.Header()) != 0 {
+			t.Fatalf("got response headers %v for a request without a valid ID", rec.Header())
+		}
+	}
+	if err := s.Print(asynclog.ContextWithCorrelationID(context.Background(), "ctx-1"), "printed"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Flush(testContext(t)); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := strings.Join(sink.Lines(), "\n")
+	for _, want := range []string{"trace_id=" + trace, "request_id=req-2", "trace_id=ctx-1"} {
+		if !strings.Contains(lines, want) {
+			t.Errorf("no %s in:\n%s", want, lines)
+		}
+	}
+	if strings.Contains(lines, "req-1") || strings.Count(lines, "_id=") != 3 {
+		t.Fatalf("got a correlation ID besides the first format found in:\n%s", lines)
+	}
+}
@@ -0,0 +1,79 @@
+package asynclog
+
+import (
+	"context"
+	"io"
+	"time"
+)
+
+// writeDeadliner is implemented by writers such as net.Conn that can bound
+// how long a write blocks.
+type writeDeadliner interface {
+	SetWriteDeadline(t time.Time) error
+}
+
+// WithWriteTimeout bounds every flush to d: writers implementing
+// SetWriteDeadline, such as net.Conn, get the flush deadline set before the
+// write and cleared after it, so one slow write can't hold the flusher
+// indefinitely.
+func WithWriteTimeout(d time.Duration) Option {
+	return func(s *Service) {
+		s.writeTimeout = d
+	}
+}
+
+// flushContext returns the context bounding one flush, cancelled by
+// abortWrites.
+func (s *Service) flushContext() (context.Context, context.CancelFunc) {
+	s.writeCtxMx.Lock()
+	parent := s.writeCtx
+	s.writeCtxMx.Unlock()
+
+	if s.writeTimeout <= 0 {
+		return context.WithCancel(parent)
+	}
+
+	return context.WithTimeout(parent, s.writeTimeout)
+}
+
+// abortWrites cancels the context of the writes in progress. Later writes
+// get a fresh one.
+func (s *Service) abortWrites() {
+	s.writeCtxMx.Lock()
+	defer s.writeCtxMx.Unlock()
+
+	s.cancelWrites()
+	s.writeCtx, s.cancelWrites = context.WithCancel(context.Background())
+}
+
+// writeTarget writes t within the deadline of ctx.
+func writeTarget(ctx context.Context, t target) (int, error) {
+	if d, ok := t.writer.(writeDeadliner); ok {
+		if deadline, ok := ctx.Deadline(); ok {
+			d.SetWriteDeadline(deadline)
+			defer d.SetWriteDeadline(time.Time{})
+		}
+	}
+
+	if err := ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	return writeContext(ctx, t.writer, t.payload)
+}
+
+// ContextWriter is implemented by sinks that want the flush context, with the
+// WithWriteTimeout deadline, cancelled when Shutdown gives up waiting. It is
+// used instead of Write when available.
+type ContextWriter interface {
+	WriteContext(ctx context.Context, p []byte) (int, error)
+}
+
+// writeContext writes p to w, through WriteContext if w implements it.
+func writeContext(ctx context.Context, w io.Writer, p []byte) (int, error) {
+	if cw, ok := w.(ContextWriter); ok {
+		return cw.WriteContext(ctx, p)
+	}
+
+	return w.Write(p)
+}
@@ -0,0 +1,97 @@
+package asynclog_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"testing"
+	"time"
+
+	"test-task-log/asynclog"
+)
+
+func TestWriteTimeout(t *testing.T) {
+	conn, peer := net.Pipe()
+	defer peer.Close()
+	s := asynclog.NewService(conn, asynclog.WithBatchSize(1), asynclog.WithWriteTimeout(20*time.Millisecond))
+	go s.Run(context.Background())
+	t.Cleanup(func() { s.Shutdown(context.Background()) })
+
+	// Nobody reads the other end, so the write blocks until the deadline.
+	if err := s.PrintSync(testContext(t), "a"); !errors.Is(err, os.ErrDeadlineExceeded) {
+		t.Fatalf("got %v, want os.ErrDeadlineExceeded", err)
+	}
+
+	// A write the peer takes in time goes through on the same connection.
+	read := make(chan []byte, 1)
+	go func() {
+		b := make([]byte, 2)
+		io.ReadFull(peer, b)
+		read <- b
+	}()
+	if err := s.PrintSync(testContext(t), "b"); err != nil {
+		t.Fatal(err)
+	}
+	if got := string(<-read); got != "b\n" {
+		t.Fatalf("peer read %q, want %q", got, "b\n")
+	}
+}
+
+// ctxWriter blocks writes until their context is done, signalling started
+// and then reporting the context's error on errs. Plain Write isn't used when
+// WriteContext is there.
+type ctxWriter struct {
+	started chan struct{}
+	errs    chan error
+}
+
+func (w ctxWriter) Write(p []byte) (int, error) {
+	panic("Write called on a ContextWriter")
+}
+
+func (w ctxWriter) WriteContext(ctx context.Context, p []byte) (int, error) {
+	w.started <- struct{}{}
+	<-ctx.Done()
+	w.errs <- ctx.Err()
+	return 0, ctx.Err()
+}
+
+func TestContextWriterTimeout(t *testing.T) {
+	w := ctxWriter{started: make(chan struct{}, 1), errs: make(chan error, 1)}
+	s := asynclog.NewService(w, asynclog.WithBatchSize(1), asynclog.WithWriteTimeout(20*time.Millisecond))
+	go s.Run(context.Background())
+	t.Cleanup(func() { s.Shutdown(context.Background()) })
+
+	if err := s.PrintSync(testContext(t), "a"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got %v, want context.DeadlineExceeded", err)
+	}
+	if err := <-w.errs; !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("writer saw %v, want context.DeadlineExceeded", err)
+	}
+}
+
+func TestContextWriterShutdown(t *testing.T) {
+	w := ctxWriter{started: make(chan struct{}, 1), errs: make(chan error, 1)}
+	s := asynclog.NewService(w, asynclog.WithBatchSize(1))
+	done := make(chan struct{})
+	go func() {
+		s.Run(context.Background())
+		close(done)
+	}()
+
+	printAll(t, s, "a")
+	<-w.started
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := s.Shutdown(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got %v, want context.DeadlineExceeded", err)
+	}
+
+	// Giving up on Shutdown cancels the write holding Run up.
+	if err := <-w.errs; !errors.Is(err, context.Canceled) {
+		t.Fatalf("writer saw %v, want context.Canceled", err)
+	}
+	<-done
+}
@@ -0,0 +1,182 @@
+package asynclog
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// ErrDropped is returned by PrintSync for records discarded before being
+// written: filtered out, dropped by the overflow policy or compaction, or
+// over the maximum age.
+var ErrDropped = errors.New("asynclog: dropped")
+
+// DeliveryStatus is the outcome of a tracked record.
+type DeliveryStatus int
+
+const (
+	// Delivered records were written without error.
+	Delivered DeliveryStatus = iota
+	// Failed records were part of a batch whose write failed.
+	Failed
+	// Dropped records were discarded before being written, e.g. for being
+	// over the maximum age.
+	Dropped
+)
+
+func (st DeliveryStatus) String() string {
+	switch st {
+	case Delivered:
+		return "delivered"
+	case Failed:
+		return "failed"
+	default:
+		return "dropped"
+	}
+}
+
+// DeliveryReport tells what happened to a record enqueued with PrintTracked.
+type DeliveryReport struct {
+	ID     uint64
+	Status DeliveryStatus
+	Err    error
+}
+
+// PrintTracked is Print for records whose delivery the caller wants to
+// follow. It returns the ID the record's DeliveryReport will carry, or what
+// Print returns if the record wasn't accepted. Reports are only sent with
+// WithDeliveryReports.
+func (s *Service) PrintTracked(ctx context.Context, log string) (uint64, error) {
+	rec := record{level: LevelInfo, msg: log, tracked: s.trackSeq.Add(1)}
+	if err := s.enqueue(ctx, rec); err != nil {
+		return 0, err
+	}
+
+	return rec.tracked, nil
+}
+
+// PrintSync enqueues log at LevelInfo and waits until the batch holding it
+// has been written, for records that must not be lost silently, such as
+// audit logs. It returns nil once the write succeeded, the write error,
+// after any WithRetry attempts, if it failed, ErrDropped if the record was
+// discarded first, and otherwise what Print returns; retrying on error
+// gives at-least-once delivery. A record is written along with the others
+// of its batch, so under a long flush interval PrintSync waits that long
+// unless Flush is called. Its report doesn't go to Reports.
+func (s *Service) PrintSync(ctx context.Context, log string) error {
+	return s.enqueueSync(ctx, record{level: LevelInfo, msg: log})
+}
+
+// LogSync is PrintSync for entries.
+func (s *Service) LogSync(ctx context.Context, e Entry) error {
+	return s.enqueueSync(ctx, e.record())
+}
+
+func (s *Service) enqueueSync(ctx context.Context, rec record) error {
+	rec.tracked = s.trackSeq.Add(1)
+	done := make(chan error, 1)
+
+	s.waitMx.Lock()
+	if s.waiting == nil {
+		s.waiting = make(map[uint64]chan error)
+	}
+	s.waiting[rec.tracked] = done
+	s.waitMx.Unlock()
+
+	if err := s.enqueue(ctx, rec); err != nil {
+		s.waitMx.Lock()
+		delete(s.waiting, rec.tracked)
+		s.waitMx.Unlock()
+		return err
+	}
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		// The record is still on its way: leave it marked as abandoned,
+		// so its report is dropped rather than sent to Reports.
+		s.waitMx.Lock()
+		if _, ok := s.waiting[rec.tracked]; ok {
+			s.waiting[rec.tracked] = nil
+		}
+		s.waitMx.Unlock()
+		return fmt.Errorf("%w: %w", ErrTimeout, ctx.Err())
+	}
+}
+
+// wake hands the outcome of record id to the PrintSync waiting for it, if
+// any, and reports whether the record was one of PrintSync's, including those
+// it stopped waiting for.
+func (s *Service) wake(id uint64, status DeliveryStatus, err error) bool {
+	s.waitMx.Lock()
+	done, ok := s.waiting[id]
+	delete(s.waiting, id)
+	s.waitMx.Unlock()
+
+	if !ok || done == nil {
+		return ok
+	}
+
+	switch status {
+	case Delivered:
+		done <- nil
+	case Failed:
+		done <- err
+	default:
+		done <- ErrDropped
+	}
+
+	return true
+}
+
+// Reports returns the delivery report stream, nil without
+// WithDeliveryReports. It has to be drained: writes wait for room in it.
+func (s *Service) Reports() <-chan DeliveryReport {
+	return s.reports
+}
+
+func trackedIDs(recs []record) []uint64 {
+	var ids []uint64
+	for _, rec := range recs {
+		if rec.tracked != 0 {
+			ids = append(ids, rec.tracked)
+		}
+	}
+
+	return ids
+}
+
+// report sends a report for every record in ids, to its PrintSync if it has
+// one.
+func (s *Service) report(ids []uint64, status DeliveryStatus, err error) {
+	for _, id := range ids {
+		if !s.wake(id, status, err) && s.reports != nil {
+			s.reports <- DeliveryReport{ID: id, Status: status, Err: err}
+		}
+	}
+}
+
+// reportDropped reports dropped records from a goroutine, since it is called
+// from Run.
+func (s *Service) reportDropped(recs []record) {
+	ids := trackedIDs(recs)
+	if len(ids) == 0 {
+		return
+	}
+
+	s.bufferWg.Add(1)
+	go func() {
+		s.report(ids, Dropped, nil)
+		s.bufferWg.Done()
+	}()
+}
+
+// reportWrite reports the outcome of writing the records in ids.
+func (s *Service) reportWrite(ids []uint64, err error) {
+	if err != nil {
+		s.report(ids, Failed, err)
+	} else {
+		s.report(ids, Delivered, nil)
+	}
+}
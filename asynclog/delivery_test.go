@@ -0,0 +1,120 @@
+package asynclog_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"test-task-log/asynclog"
+)
+
+// gateWriter blocks writes until release is closed.
+type gateWriter struct {
+	release chan struct{}
+}
+
+func (w gateWriter) Write(p []byte) (int, error) {
+	<-w.release
+	return len(p), nil
+}
+
+func TestPrintSync(t *testing.T) {
+	s, sink, _ := start(t, asynclog.WithBatchSize(1))
+
+	if err := s.PrintSync(testContext(t), "a"); err != nil {
+		t.Fatal(err)
+	}
+	if lines := sink.Lines(); len(lines) != 1 || lines[0] != "a" {
+		t.Fatalf("got lines %q after PrintSync, want a", lines)
+	}
+
+	sink.Fail(errors.New("down"))
+	if err := s.PrintSync(testContext(t), "b"); err == nil {
+		t.Fatal("PrintSync succeeded on a failing writer")
+	}
+}
+
+func TestLogSync(t *testing.T) {
+	s, sink, _ := start(t, asynclog.WithBatchSize(1))
+
+	if err := s.LogSync(testContext(t), asynclog.Entry{Level: asynclog.LevelWarn, Message: "a"}); err != nil {
+		t.Fatal(err)
+	}
+	if lines := sink.Lines(); len(lines) != 1 || lines[0] != "a" {
+		t.Fatalf("got lines %q after LogSync, want a", lines)
+	}
+
+	s.SetLevel(asynclog.LevelError)
+	if err := s.LogSync(testContext(t), asynclog.Entry{Level: asynclog.LevelWarn, Message: "below"}); !errors.Is(err, asynclog.ErrDropped) {
+		t.Fatalf("LogSync of a filtered entry: got %v, want ErrDropped", err)
+	}
+	sink.Fail(errors.New("down"))
+	if err := s.LogSync(testContext(t), asynclog.Entry{Level: asynclog.LevelError, Message: "b"}); err == nil {
+		t.Fatal("LogSync succeeded on a failing writer")
+	}
+}
+
+func TestPrintSyncTimeoutReport(t *testing.T) {
+	w := gateWriter{release: make(chan struct{})}
+	s := asynclog.NewService(w, asynclog.WithBatchSize(1), asynclog.WithDeliveryReports(10))
+	go s.Run(context.Background())
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := s.PrintSync(ctx, "late"); !errors.Is(err, asynclog.ErrTimeout) {
+		t.Fatalf("got %v, want ErrTimeout", err)
+	}
+
+	id, err := s.PrintTracked(context.Background(), "tracked")
+	if err != nil {
+		t.Fatal(err)
+	}
+	close(w.release)
+	if err := s.Shutdown(testContext(t)); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case r := <-s.Reports():
+		if r.ID != id || r.Status != asynclog.Delivered {
+			t.Fatalf("got report %+v, want only the tracked record's", r)
+		}
+	default:
+		t.Fatal("no report for the tracked record")
+	}
+	select {
+	case r := <-s.Reports():
+		t.Fatalf("got report %+v for the timed-out PrintSync", r)
+	default:
+	}
+}
+
+func TestPrintTracked(t *testing.T) {
+	s, sink, _ := start(t, asynclog.WithBatchSize(1), asynclog.WithDeliveryReports(10))
+
+	ok, err := s.PrintTracked(context.Background(), "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r := <-s.Reports(); r.ID != ok || r.Status != asynclog.Delivered || r.Err != nil {
+		t.Fatalf("got report %+v, want %d delivered", r, ok)
+	}
+
+	down := errors.New("down")
+	sink.Fail(down)
+	failed, err := s.PrintTracked(context.Background(), "b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r := <-s.Reports(); r.ID != failed || r.Status != asynclog.Failed || !errors.Is(r.Err, down) {
+		t.Fatalf("got report %+v, want %d failed with the write error", r, failed)
+	}
+
+	if err := s.Shutdown(testContext(t)); err != nil {
+		t.Fatal(err)
+	}
+	if id, err := s.PrintTracked(context.Background(), "late"); id != 0 || !errors.Is(err, asynclog.ErrClosed) {
+		t.Fatalf("PrintTracked after Shutdown: got %d, %v, want 0, ErrClosed", id, err)
+	}
+}
@@ -0,0 +1,22 @@
+package asynclog
+
+import (
+	"io"
+	"log"
+)
+
+// WithDiagnostics traces the service's own decisions to w, such as stderr:
+// what triggered each flush, batch sizes and write outcomes, writer swaps and
+// sink changes. It never goes through the pipeline itself, so it can be used
+// to debug the service without perturbing it.
+func WithDiagnostics(w io.Writer) Option {
+	return func(s *Service) {
+		s.diag = log.New(w, "asynclog: ", log.LstdFlags|log.Lmicroseconds)
+	}
+}
+
+func (s *Service) debugf(format string, args ...any) {
+	if s.diag != nil {
+		s.diag.Printf(format, args...)
+	}
+}
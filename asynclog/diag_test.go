@@ -0,0 +1,42 @@
+package asynclog_test
+
+import (
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+
+	"test-task-log/asynclog"
+	"test-task-log/asynclog/testutil"
+)
+
+func TestDiagnostics(t *testing.T) {
+	diag := testutil.NewSink()
+	s, sink, _ := start(t, asynclog.WithDiagnostics(diag))
+
+	printAll(t, s, "a")
+	if err := s.Barrier(testContext(t)); err != nil {
+		t.Fatal(err)
+	}
+	sink.Fail(errors.New("down"))
+	printAll(t, s, "b")
+	if err := s.Barrier(testContext(t)); err != nil {
+		t.Fatal(err)
+	}
+
+	trace := strings.Join(diag.Lines(), "\n")
+	for _, want := range []string{
+		"flush (barrier): 1 records",
+		"batch of 1 records to 1 targets written",
+		"err=down",
+	} {
+		if !strings.Contains(trace, want) {
+			t.Fatalf("diagnostics lack %q:\n%s", want, trace)
+		}
+	}
+
+	// None of it goes through the pipeline.
+	if got, want := sink.Lines(), []string{"a"}; !slices.Equal(got, want) {
+		t.Fatalf("got lines %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,180 @@
+package asynclog
+
+import (
+	"bufio"
+	"bytes"
+	"compress/flate"
+	"compress/zlib"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash/adler32"
+	"io"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// DictionaryID returns the ID a dictionary is known by in compressed
+// batches: its Adler-32 checksum, as zlib embeds it.
+func DictionaryID(dict []byte) uint32 {
+	return adler32.Checksum(dict)
+}
+
+// TrainDictionary builds a compression dictionary of up to size bytes from
+// sample logs read from r: the tokens that save the most by being in it, the
+// most valuable last, where the compressor finds them cheapest. Samples
+// resembling what is logged make small batches compress far better.
+func TrainDictionary(r io.Reader, size int) ([]byte, error) {
+	counts := make(map[string]int)
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		for _, tok := range strings.FieldsFunc(sc.Text(), func(c rune) bool { return c == ' ' || c == '\t' }) {
+			if len(tok) >= 3 {
+				counts[tok+" "]++
+			}
+		}
+	}
+	if err := sc.Err(); err != nil {
+		return nil, err
+	}
+
+	toks := make([]string, 0, len(counts))
+	for tok, n := range counts {
+		if n > 1 {
+			toks = append(toks, tok)
+		}
+	}
+	score := func(tok string) int { return counts[tok] * len(tok) }
+	slices.SortFunc(toks, func(a, b string) int {
+		if d := score(b) - score(a); d != 0 {
+			return d
+		}
+		return strings.Compare(a, b)
+	})
+
+	var picked []string
+	n := 0
+	for _, tok := range toks {
+		if n+len(tok) > size {
+			continue
+		}
+		picked = append(picked, tok)
+		n += len(tok)
+	}
+	slices.Reverse(picked)
+
+	return []byte(strings.Join(picked, "")), nil
+}
+
+// DictWriter compresses every batch written to it into a zlib stream of its
+// own using a preset dictionary, whose ID is in the stream header, so that
+// even small batches compress well and can be decompressed one by one with
+// DictReader. zstd would do better but isn't in the standard library.
+type DictWriter struct {
+	w   io.Writer
+	mu  sync.Mutex
+	buf bytes.Buffer
+	zw  *zlib.Writer
+}
+
+// NewDictWriter returns a writer compressing batches to w with dict.
+func NewDictWriter(w io.Writer, dict []byte) *DictWriter {
+	dw := &DictWriter{w: w}
+	dw.zw, _ = zlib.NewWriterLevelDict(&dw.buf, flate.BestCompression, dict)
+
+	return dw
+}
+
+// Write compresses p into one stream and writes it to the underlying writer
+// with a single call.
+func (dw *DictWriter) Write(p []byte) (int, error) {
+	dw.mu.Lock()
+	defer dw.mu.Unlock()
+
+	dw.buf.Reset()
+	dw.zw.Reset(&dw.buf)
+	if _, err := dw.zw.Write(p); err != nil {
+		return 0, err
+	}
+	if err := dw.zw.Close(); err != nil {
+		return 0, err
+	}
+
+	if _, err := dw.w.Write(dw.buf.Bytes()); err != nil {
+		return 0, err
+	}
+
+	return len(p), nil
+}
+
+// Close closes the underlying writer if it is a Closer.
+func (dw *DictWriter) Close() error {
+	if c, ok := dw.w.(io.Closer); ok {
+		return c.Close()
+	}
+
+	return nil
+}
+
+// DictReader decompresses the streams written by DictWriter, picking the
+// dictionary of each by its ID, so dictionaries can be rotated.
+type DictReader struct {
+	r     *bufio.Reader
+	dicts map[uint32][]byte
+	zr    io.ReadCloser
+}
+
+// NewDictReader returns a reader of the batches in r, compressed with any of
+// dicts.
+func NewDictReader(r io.Reader, dicts ...[]byte) *DictReader {
+	dr := &DictReader{r: bufio.NewReader(r), dicts: make(map[uint32][]byte, len(dicts))}
+	for _, d := range dicts {
+		dr.dicts[DictionaryID(d)] = d
+	}
+
+	return dr
+}
+
+func (dr *DictReader) Read(p []byte) (int, error) {
+	for {
+		if dr.zr == nil {
+			if err := dr.next(); err != nil {
+				return 0, err
+			}
+		}
+
+		n, err := dr.zr.Read(p)
+		if errors.Is(err, io.EOF) {
+			dr.zr = nil
+			err = nil
+		}
+		if n > 0 || err != nil {
+			return n, err
+		}
+	}
+}
+
+// next starts reading the next stream, returning io.EOF at the end of input.
+func (dr *DictReader) next() error {
+	hdr, err := dr.r.Peek(6)
+	if len(hdr) == 0 && errors.Is(err, io.EOF) {
+		return io.EOF
+	}
+	if err != nil {
+		return io.ErrUnexpectedEOF
+	}
+
+	var dict []byte
+	if hdr[1]&0x20 != 0 {
+		id := binary.BigEndian.Uint32(hdr[2:])
+		var ok bool
+		if dict, ok = dr.dicts[id]; !ok {
+			return fmt.Errorf("asynclog: unknown dictionary %08x", id)
+		}
+	}
+
+	dr.zr, err = zlib.NewReaderDict(dr.r, dict)
+
+	return err
+}
@@ -0,0 +1,72 @@
+package asynclog_test
+
+import (
+	"bytes"
+	"compress/zlib"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"test-task-log/asynclog"
+	"test-task-log/asynclog/testutil"
+)
+
+const dictSamples = `level=info source=api msg="request served" status=200 path=/users
+level=info source=api msg="request served" status=200 path=/orders
+level=warn source=api msg="slow request" status=200 path=/orders
+level=error source=db msg="connection reset" status=500 path=/users
+`
+
+func TestDictRoundTrip(t *testing.T) {
+	dict, err := asynclog.TrainDictionary(strings.NewReader(dictSamples), 64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dict) == 0 || len(dict) > 64 {
+		t.Fatalf("got a dictionary of %d bytes, want up to 64", len(dict))
+	}
+	other := []byte("source=worker msg=")
+
+	var buf bytes.Buffer
+	batch := `level=info source=api msg="request served" status=200 path=/users` + "\n"
+	if n, err := asynclog.NewDictWriter(&buf, dict).Write([]byte(batch)); n != len(batch) || err != nil {
+		t.Fatalf("got %d, %v, want the batch written", n, err)
+	}
+
+	var plain bytes.Buffer
+	zw := zlib.NewWriter(&plain)
+	zw.Write([]byte(batch))
+	zw.Close()
+	if buf.Len() >= plain.Len() {
+		t.Fatalf("got %d bytes with the dictionary, %d without, want fewer", buf.Len(), plain.Len())
+	}
+
+	asynclog.NewDictWriter(&buf, other).Write([]byte("source=worker msg=done\n"))
+	got, err := io.ReadAll(asynclog.NewDictReader(&buf, dict, other))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := batch + "source=worker msg=done\n"; string(got) != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
+
+func TestDictErrors(t *testing.T) {
+	dict := []byte("source=api msg=")
+
+	var buf bytes.Buffer
+	asynclog.NewDictWriter(&buf, dict).Write([]byte("source=api msg=a\n"))
+	if _, err := io.ReadAll(asynclog.NewDictReader(bytes.NewReader(buf.Bytes()))); err == nil || !strings.Contains(err.Error(), "unknown dictionary") {
+		t.Fatalf("got %v, want an unknown dictionary error", err)
+	}
+	if _, err := io.ReadAll(asynclog.NewDictReader(bytes.NewReader(buf.Bytes()[:buf.Len()-3]), dict)); err == nil {
+		t.Fatal("truncated stream read without error")
+	}
+
+	sink := testutil.NewSink()
+	sink.Fail(errors.New("down"))
+	if n, err := asynclog.NewDictWriter(sink, dict).Write([]byte("a\n")); n != 0 || err == nil {
+		t.Fatalf("got %d, %v, want the underlying writer's error", n, err)
+	}
+}
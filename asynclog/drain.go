@@ -0,0 +1,50 @@
+package asynclog
+
+import (
+	"context"
+	"fmt"
+)
+
+// Drain stops accepting records, as if every producer's context were done,
+// and waits for everything accepted so far to be written. If ctx ends first
+// the error says how many records were still pending. The service keeps
+// running and accepts records again after Resume, which makes Drain usable
+// for warm restarts and for isolating tests sharing a service.
+func (s *Service) Drain(ctx context.Context) error {
+	s.drained.Store(true)
+
+	err := s.Barrier(ctx)
+	if err != nil && err != ErrClosed {
+		pending := s.queue.size() + int(s.inflight.Load())
+		return fmt.Errorf("asynclog: drain: %d records pending: %w", pending, err)
+	}
+
+	return err
+}
+
+// Resume accepts records again after Drain.
+func (s *Service) Resume() {
+	s.drained.Store(false)
+}
+
+// Shutdown stops accepting records, failing producers still waiting for room
+// in the queue with ErrClosed, and makes Run write everything still queued
+// or buffered and return, as if its context were done. It returns
+// once Run has returned, or with an error saying how many records were
+// still pending if ctx ends first, in which case the writes in progress are
+// cancelled through the context ContextWriters get, and Run carries on
+// writing the rest in the background.
+func (s *Service) Shutdown(ctx context.Context) error {
+	s.drained.Store(true)
+	s.queue.close()
+	s.stopOnce.Do(func() { close(s.stop) })
+
+	select {
+	case <-s.done:
+		return nil
+	case <-ctx.Done():
+		s.abortWrites()
+		pending := s.queue.size() + int(s.inflight.Load())
+		return fmt.Errorf("asynclog: shutdown: %d records pending: %w", pending, ctx.Err())
+	}
+}
@@ -0,0 +1,54 @@
+package asynclog_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"test-task-log/asynclog"
+)
+
+func TestDrain(t *testing.T) {
+	s, sink, _ := start(t)
+
+	printAll(t, s, "a", "b")
+	if err := s.Drain(testContext(t)); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := sink.Lines(), []string{"a", "b"}; !slices.Equal(got, want) {
+		t.Fatalf("got lines %q after Drain, want %q", got, want)
+	}
+	if err := s.Print(context.Background(), "c"); !errors.Is(err, asynclog.ErrClosed) {
+		t.Fatalf("Print while drained: got %v, want ErrClosed", err)
+	}
+
+	s.Resume()
+	printAll(t, s, "d")
+	if err := s.Drain(testContext(t)); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := sink.Lines(), []string{"a", "b", "d"}; !slices.Equal(got, want) {
+		t.Fatalf("got lines %q after Resume, want %q", got, want)
+	}
+}
+
+func TestDrainTimeout(t *testing.T) {
+	w := gateWriter{release: make(chan struct{})}
+	s := asynclog.NewService(w)
+	go s.Run(context.Background())
+	t.Cleanup(func() {
+		close(w.release)
+		s.Shutdown(context.Background())
+	})
+
+	printAll(t, s, "a", "b")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	err := s.Drain(ctx)
+	if !errors.Is(err, context.DeadlineExceeded) || !strings.Contains(err.Error(), "2 records pending") {
+		t.Fatalf("got %v, want the 2 records blocked in the write pending", err)
+	}
+}
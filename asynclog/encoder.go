@@ -0,0 +1,134 @@
+package asynclog
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Encoder turns a batch of entries into the payload of one write. The
+// entries slice is reused once Encode returns, so an encoder must copy any
+// entries it keeps, and the payload must not share memory with it.
+type Encoder interface {
+	Encode(entries []Entry) ([]byte, error)
+}
+
+// WithEncoder encodes batches with enc instead of TextEncoder. A batch enc
+// fails to encode is not written, and its tracked records are reported as
+// failed.
+func WithEncoder(enc Encoder) Option {
+	return func(s *Service) {
+		s.encoder = enc
+	}
+}
+
+// TextEncoder writes one line per entry: its ID if any, the message and the
+// fields as key=value pairs. It is the default.
+type TextEncoder struct{}
+
+func (TextEncoder) Encode(entries []Entry) ([]byte, error) {
+	var b bytes.Buffer
+	for _, e := range entries {
+		e.record().writeLine(&b)
+		b.WriteByte('\n')
+	}
+
+	return b.Bytes(), nil
+}
+
+// JSONEncoder writes one JSON object per line, with the keys time, level,
+// source and id where set, msg, and the fields. Fields named like one of those
+// keys are dropped.
+type JSONEncoder struct{}
+
+func (JSONEncoder) Encode(entries []Entry) ([]byte, error) {
+	var b bytes.Buffer
+	for _, e := range entries {
+		obj := make(map[string]any, len(e.Fields)+5)
+		for _, f := range e.Fields {
+			obj[f.Key] = f.Value
+		}
+		obj["time"] = e.Time.Format(time.RFC3339Nano)
+		obj["level"] = e.Level.String()
+		obj["msg"] = e.Message
+		if e.Source != "" {
+			obj["source"] = e.Source
+		} else {
+			delete(obj, "source")
+		}
+		if e.ID != "" {
+			obj["id"] = e.ID
+		} else {
+			delete(obj, "id")
+		}
+
+		line, err := json.Marshal(obj)
+		if err != nil {
+			return nil, fmt.Errorf("asynclog: encode %q: %w", e.Message, err)
+		}
+		b.Write(line)
+		b.WriteByte('\n')
+	}
+
+	return b.Bytes(), nil
+}
+
+// LogfmtEncoder writes one logfmt line per entry, such as
+//
+//	time=2024-05-01T10:00:00Z level=INFO source=api msg="user logged in" user=7
+//
+// quoting values with spaces, quotes or equals signs.
+type LogfmtEncoder struct{}
+
+func (LogfmtEncoder) Encode(entries []Entry) ([]byte, error) {
+	var b bytes.Buffer
+	for _, e := range entries {
+		writeLogfmt(&b, "time", e.Time.Format(time.RFC3339Nano))
+		writeLogfmt(&b, "level", e.Level.String())
+		if e.Source != "" {
+			writeLogfmt(&b, "source", e.Source)
+		}
+		if e.ID != "" {
+			writeLogfmt(&b, "id", e.ID)
+		}
+		writeLogfmt(&b, "msg", e.Message)
+		for _, f := range e.Fields {
+			writeLogfmt(&b, f.Key, fmt.Sprint(f.Value))
+		}
+		b.Truncate(b.Len() - 1)
+		b.WriteByte('\n')
+	}
+
+	return b.Bytes(), nil
+}
+
+// writeLogfmt writes key=value and a trailing space.
+func writeLogfmt(b *bytes.Buffer, key, value string) {
+	b.WriteString(key)
+	b.WriteByte('=')
+	if value == "" || strings.ContainsAny(value, " =\"\t\r\n") {
+		value = strconv.Quote(value)
+	}
+	b.WriteString(value)
+	b.WriteByte(' ')
+}
+
+// ParseEncoder returns the built-in encoder named "text", "json", "logfmt"
+// or "syslog", the latter with FacilityUser.
+func ParseEncoder(name string) (Encoder, error) {
+	switch name {
+	case "text":
+		return TextEncoder{}, nil
+	case "json":
+		return JSONEncoder{}, nil
+	case "logfmt":
+		return LogfmtEncoder{}, nil
+	case "syslog":
+		return SyslogEncoder{Facility: FacilityUser}, nil
+	}
+
+	return nil, fmt.Errorf("asynclog: unknown encoder %q", name)
+}
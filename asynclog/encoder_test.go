@@ -0,0 +1,55 @@
+package asynclog_test
+
+import (
+	"testing"
+	"time"
+
+	"test-task-log/asynclog"
+)
+
+func TestEncoders(t *testing.T) {
+	entries := []asynclog.Entry{
+		{
+			Time:    time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC),
+			Level:   asynclog.LevelInfo,
+			Source:  "api",
+			ID:      "01HX",
+			Message: "user logged in",
+			Fields:  []asynclog.Field{{Key: "user", Value: 7}, {Key: "ok", Value: true}},
+		},
+		{Time: time.Date(2024, 5, 1, 10, 0, 1, 0, time.UTC), Level: asynclog.LevelError, Message: "a=b"},
+	}
+
+	for name, want := range map[string]string{
+		"text": "01HX user logged in user=7 ok=true\n" +
+			"a=b\n",
+		"json": `{"id":"01HX","level":"INFO","msg":"user logged in","ok":true,"source":"api","time":"2024-05-01T10:00:00Z","user":7}` + "\n" +
+			`{"level":"ERROR","msg":"a=b","time":"2024-05-01T10:00:01Z"}` + "\n",
+		"logfmt": `time=2024-05-01T10:00:00Z level=INFO source=api id=01HX msg="user logged in" user=7 ok=true` + "\n" +
+			`time=2024-05-01T10:00:01Z level=ERROR msg="a=b"` + "\n",
+	} {
+		enc, err := asynclog.ParseEncoder(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := enc.Encode(entries)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if string(got) != want {
+			t.Errorf("%s: got\n%s\nwant\n%s", name, got, want)
+		}
+	}
+}
+
+func TestEncoderErrors(t *testing.T) {
+	if _, err := asynclog.ParseEncoder("xml"); err == nil {
+		t.Fatal("parsed an unknown encoder name")
+	}
+
+	// A field JSON can't represent fails the batch.
+	_, err := asynclog.JSONEncoder{}.Encode([]asynclog.Entry{{Message: "a", Fields: []asynclog.Field{{Key: "c", Value: make(chan int)}}}})
+	if err == nil {
+		t.Fatal("encoded a channel as JSON")
+	}
+}
@@ -0,0 +1,67 @@
+package asynclog
+
+import (
+	"context"
+	"time"
+)
+
+// Entry is a structured log record. A zero Time is set when the entry is
+// enqueued, and so is ID with WithULID. Entries are batched as they are, so
+// writers and encoders get the fields rather than a preformatted string.
+type Entry struct {
+	Time    time.Time
+	Level   Level
+	Source  string
+	ID      string
+	Message string
+	Fields  []Field
+}
+
+func (e Entry) record() record {
+	return record{level: e.Level, source: e.Source, id: e.ID, time: e.Time, msg: e.Message, fields: e.Fields}
+}
+
+// entry is rec as an Entry.
+func (rec record) entry() Entry {
+	return Entry{Time: rec.time, Level: rec.level, Source: rec.source, ID: rec.id, Message: rec.msg, Fields: rec.fields}
+}
+
+// Log enqueues e, returning what Print returns.
+func (s *Service) Log(ctx context.Context, e Entry) error {
+	return s.enqueue(ctx, e.record())
+}
+
+// LogAll is PrintAll for entries.
+func (s *Service) LogAll(ctx context.Context, es []Entry) error {
+	recs := make([]record, len(es))
+	for i, e := range es {
+		recs[i] = e.record()
+	}
+
+	return s.enqueueAll(ctx, recs)
+}
+
+// Debug enqueues msg with fields at LevelDebug.
+func (s *Service) Debug(ctx context.Context, msg string, fields ...Field) error {
+	return s.Log(ctx, Entry{Level: LevelDebug, Message: msg, Fields: fields})
+}
+
+// Info enqueues msg with fields at LevelInfo.
+func (s *Service) Info(ctx context.Context, msg string, fields ...Field) error {
+	return s.Log(ctx, Entry{Level: LevelInfo, Message: msg, Fields: fields})
+}
+
+// Warn enqueues msg with fields at LevelWarn.
+func (s *Service) Warn(ctx context.Context, msg string, fields ...Field) error {
+	return s.Log(ctx, Entry{Level: LevelWarn, Message: msg, Fields: fields})
+}
+
+// Error enqueues msg with fields at LevelError.
+func (s *Service) Error(ctx context.Context, msg string, fields ...Field) error {
+	return s.Log(ctx, Entry{Level: LevelError, Message: msg, Fields: fields})
+}
+
+// F is shorthand for a Field, as in s.Info(ctx, "login", asynclog.F("user", id)).
+func F(key string, value any) Field {
+	return Field{Key: key, Value: value}
+}
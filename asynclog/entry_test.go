@@ -0,0 +1,87 @@
+package asynclog_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"test-task-log/asynclog"
+)
+
+// captureEncoder keeps copies of the entries it encodes, writing their
+// messages.
+type captureEncoder struct {
+	mu      sync.Mutex
+	entries []asynclog.Entry
+}
+
+func (e *captureEncoder) Encode(entries []asynclog.Entry) ([]byte, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	var b []byte
+	for _, en := range entries {
+		e.entries = append(e.entries, en)
+		b = append(b, en.Message+"\n"...)
+	}
+	return b, nil
+}
+
+func TestEntries(t *testing.T) {
+	enc := &captureEncoder{}
+	s, _, clock := start(t, asynclog.WithEncoder(enc))
+	ctx := context.Background()
+
+	at := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	for _, err := range []error{
+		s.Debug(ctx, "d", asynclog.F("n", 1)),
+		s.Info(ctx, "i"),
+		s.Warn(ctx, "w", asynclog.F("user", "bob")),
+		s.Error(ctx, "e"),
+		s.Log(ctx, asynclog.Entry{Time: at, Level: asynclog.LevelInfo, Source: "api", Message: "l"}),
+	} {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Shutdown(testContext(t)); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []asynclog.Entry{
+		{Time: clock.Now(), Level: asynclog.LevelDebug, Message: "d", Fields: []asynclog.Field{{Key: "n", Value: 1}}},
+		{Time: clock.Now(), Level: asynclog.LevelInfo, Message: "i"},
+		{Time: clock.Now(), Level: asynclog.LevelWarn, Message: "w", Fields: []asynclog.Field{{Key: "user", Value: "bob"}}},
+		{Time: clock.Now(), Level: asynclog.LevelError, Message: "e"},
+		{Time: at, Level: asynclog.LevelInfo, Source: "api", Message: "l"},
+	}
+	if len(enc.entries) != len(want) {
+		t.Fatalf("got %d entries, want %d", len(enc.entries), len(want))
+	}
+	for i, got := range enc.entries {
+		w := want[i]
+		if !got.Time.Equal(w.Time) || got.Level != w.Level || got.Source != w.Source || got.Message != w.Message ||
+			len(got.Fields) != len(w.Fields) || len(w.Fields) > 0 && got.Fields[0] != w.Fields[0] {
+			t.Fatalf("entry %d: got %+v, want %+v", i, got, w)
+		}
+	}
+}
+
+func TestEntriesRejected(t *testing.T) {
+	s, _, _ := start(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := s.Info(ctx, "late"); !errors.Is(err, asynclog.ErrTimeout) {
+		t.Fatalf("got %v with ctx done, want ErrTimeout", err)
+	}
+
+	if err := s.Shutdown(testContext(t)); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.LogAll(context.Background(), []asynclog.Entry{{Message: "a"}}); !errors.Is(err, asynclog.ErrClosed) {
+		t.Fatalf("LogAll after Shutdown: got %v, want ErrClosed", err)
+	}
+}
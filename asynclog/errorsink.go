@@ -0,0 +1,56 @@
+package asynclog
+
+import (
+	"fmt"
+	"io"
+)
+
+// ErrorSinkName is the name of the sink set up by WithErrorSink, for
+// RemoveSink, RouteSink and SetSinkBatching.
+const ErrorSinkName = "error_stream"
+
+// WithErrorSink also sends records at level or above to w as soon as Run
+// takes them off the queue, without waiting for the flush interval or batch
+// size, so errors show up at once while everything, errors included, keeps
+// going out to the main writer in the usual batches. The records Run takes
+// at once go out in one write. w is the sink named ErrorSinkName; it stays
+// in place across Reload unless the config has a sink of that name. It
+// panics if level is not a known level.
+func WithErrorSink(w io.Writer, level Level) Option {
+	if level < LevelDebug || level > LevelError {
+		panic(fmt.Sprintf("asynclog: WithErrorSink: invalid level %s", level))
+	}
+
+	return func(s *Service) {
+		s.errorSink = w
+		s.errorLevel = level
+	}
+}
+
+// addErrorSink sets up the sink of WithErrorSink once the options are
+// applied.
+func (s *Service) addErrorSink() {
+	if s.errorSink == nil {
+		return
+	}
+
+	s.sinks = append(s.sinks, namedSink{
+		name:   ErrorSinkName,
+		writer: s.sinkWriter(s.errorSink),
+		route:  MustCompileExpr("level >= " + s.errorLevel.String()),
+		staged: &sinkBatch{every: s.writeEvery, limit: s.writeLimit, immediate: true},
+	})
+}
+
+// flushImmediate writes what is staged for sinks flushed as soon as Run
+// takes records off the queue. It runs in Run.
+func (s *Service) flushImmediate() {
+	s.writerMx.RLock()
+	defer s.writerMx.RUnlock()
+
+	for _, sk := range s.sinks {
+		if sk.staged != nil && sk.staged.immediate && len(sk.staged.records) > 0 {
+			s.flushSink(sk, false)
+		}
+	}
+}
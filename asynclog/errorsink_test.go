@@ -0,0 +1,66 @@
+package asynclog_test
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"test-task-log/asynclog"
+	"test-task-log/asynclog/testutil"
+)
+
+func TestErrorSink(t *testing.T) {
+	errs := testutil.NewSink()
+	s, sink, clock := start(t, asynclog.WithErrorSink(errs, asynclog.LevelWarn))
+
+	for _, r := range []struct {
+		level asynclog.Level
+		msg   string
+	}{
+		{asynclog.LevelInfo, "a"},
+		{asynclog.LevelWarn, "w"},
+		{asynclog.LevelError, "e"},
+	} {
+		if err := printShort(s, "", r.level, r.msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Errors go out without waiting for the interval.
+	if err := errs.WaitLines(testContext(t), 2); err != nil {
+		t.Fatal(err)
+	}
+	got := errs.Lines()
+	slices.Sort(got)
+	if want := []string{"e", "w"}; !slices.Equal(got, want) {
+		t.Fatalf("error sink got %q, want %q", got, want)
+	}
+	if got := sink.Lines(); len(got) != 0 {
+		t.Fatalf("main writer got %q before the interval", got)
+	}
+
+	clock.Advance(5 * time.Second)
+	if err := sink.WaitLines(testContext(t), 3); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := sink.Lines(), []string{"a", "w", "e"}; !slices.Equal(got, want) {
+		t.Fatalf("main writer got %q, want %q", got, want)
+	}
+
+	// The error sink stays in place across Reload.
+	if err := s.Reload(&asynclog.Config{Writer: "stdout:"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := s.Sinks(); !slices.Equal(got, []string{asynclog.ErrorSinkName}) {
+		t.Fatalf("got sinks %q after Reload, want the error sink", got)
+	}
+}
+
+func TestErrorSinkInvalidLevel(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("WithErrorSink with an unknown level didn't panic")
+		}
+	}()
+	asynclog.WithErrorSink(testutil.NewSink(), asynclog.Level(42))
+}
@@ -0,0 +1,107 @@
+package asynclog
+
+import "time"
+
+// EventKind is the kind of an Event.
+type EventKind int
+
+const (
+	// EventEnqueue is records accepted into the queue.
+	EventEnqueue EventKind = iota
+	// EventTrigger is a signal from the flush trigger channel.
+	EventTrigger
+	// EventFlush is a batch taken off the buffers to be written, Reason
+	// saying what caused it. Under WithMaxBatchBytes it may be written as
+	// several batches.
+	EventFlush
+	// EventWrite is a batch write completing, Err being its result.
+	EventWrite
+	// EventDrop is records dropped before being written, Reason saying why.
+	EventDrop
+	// EventCircuit is a circuit of WithCircuitBreaker changing state, Reason
+	// being the new one.
+	EventCircuit
+)
+
+func (k EventKind) String() string {
+	switch k {
+	case EventEnqueue:
+		return "enqueue"
+	case EventTrigger:
+		return "trigger"
+	case EventFlush:
+		return "flush"
+	case EventWrite:
+		return "write"
+	case EventDrop:
+		return "drop"
+	case EventCircuit:
+		return "circuit"
+	default:
+		return "unknown"
+	}
+}
+
+// Event is something the service did with records, as seen by the hook of
+// WithEventHook. Messages are set for enqueues, flushes and drops, Bytes and
+// Latency for writes.
+type Event struct {
+	Kind     EventKind
+	Reason   string
+	Records  int
+	Messages []string
+	Bytes    int
+	Latency  time.Duration
+	Err      error
+}
+
+// WithEventHook calls fn with every enqueue, trigger, flush, write, drop and
+// circuit change, in the order they happen on the goroutine causing them, to
+// observe how records move through the service, typically in tests (see the
+// testutil package). fn is called synchronously from producers, Run and write
+// goroutines, so it must be safe for concurrent use and must not block.
+func WithEventHook(fn func(Event)) Option {
+	return func(s *Service) {
+		s.events = fn
+	}
+}
+
+// event counts an event about recs and reports it to the event hook, if
+// any.
+func (s *Service) event(kind EventKind, reason string, recs []record) {
+	switch kind {
+	case EventEnqueue:
+		s.stats.received.Add(int64(len(recs)))
+		if s.statsHook != nil {
+			s.statsHook.RecordsReceived(len(recs))
+		}
+	case EventDrop:
+		if s.statsHook != nil {
+			s.statsHook.RecordsDropped(len(recs), reason)
+		}
+	}
+
+	if s.events == nil {
+		return
+	}
+
+	msgs := make([]string, len(recs))
+	for i, rec := range recs {
+		msgs[i] = rec.msg
+	}
+
+	s.events(Event{Kind: kind, Reason: reason, Records: len(recs), Messages: msgs})
+}
+
+// written counts a completed write of n records and reports it to the event
+// hook, if any.
+func (s *Service) written(n, bytes int, latency time.Duration, err error) {
+	s.stats.wrote(n, bytes, latency, err)
+	if s.statsHook != nil {
+		s.statsHook.BatchWritten(n, bytes, latency, err)
+	}
+
+	if s.events != nil {
+		s.events(Event{Kind: EventWrite, Records: n, Bytes: bytes, Latency: latency, Err: err})
+	}
+}
@@ -0,0 +1,62 @@
+package asynclog
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os/exec"
+	"sync"
+)
+
+// Exec runs a command and logs its output until it exits, making the service
+// usable as a supervisor shim in front of a process that writes its logs to
+// stdout and stderr. Both streams are logged as from source, with a stream
+// field, stdout at LevelInfo and stderr at LevelError. The command is killed
+// when ctx is done. Exec returns once both streams are read and the command
+// has exited, with its error, such as an *exec.ExitError.
+func (s *Service) Exec(ctx context.Context, source, name string, args ...string) error {
+	cmd := exec.CommandContext(ctx, name, args...)
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return err
+	}
+
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+
+	var wg sync.WaitGroup
+	errs := make([]error, 2)
+	for i, stream := range []struct {
+		r     io.Reader
+		name  string
+		level Level
+	}{
+		{stdout, "stdout", LevelInfo},
+		{stderr, "stderr", LevelError},
+	} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = s.ingest(ctx, stream.r, record{
+				level:  stream.level,
+				source: source,
+				fields: []Field{{Key: "stream", Value: stream.name}},
+			})
+		}()
+	}
+	wg.Wait()
+
+	// The pipes are only read to the end if ctx wasn't done, and the command
+	// is being killed otherwise, so its error is the one that matters.
+	if err := cmd.Wait(); err != nil || ctx.Err() != nil {
+		return errors.Join(err, ctx.Err())
+	}
+
+	return errors.Join(errs...)
+}
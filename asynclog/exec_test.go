@@ -0,0 +1,47 @@
+package asynclog_test
+
+import (
+	"context"
+	"errors"
+	"os/exec"
+	"slices"
+	"strings"
+	"testing"
+
+	"test-task-log/asynclog"
+)
+
+func TestExec(t *testing.T) {
+	s, sink, _ := start(t, asynclog.WithEncoder(asynclog.LogfmtEncoder{}))
+
+	err := s.Exec(context.Background(), "child", "sh", "-c", "echo out; echo err >&2; exit 3")
+	var exitErr *exec.ExitError
+	if !errors.As(err, &exitErr) || exitErr.ExitCode() != 3 {
+		t.Fatalf("got %v, want the exit status 3", err)
+	}
+	if err := s.Shutdown(testContext(t)); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, l := range sink.Lines() {
+		_, rest, _ := strings.Cut(l, " ")
+		got = append(got, rest)
+	}
+	slices.Sort(got)
+	want := []string{
+		"level=ERROR source=child msg=err stream=stderr",
+		"level=INFO source=child msg=out stream=stdout",
+	}
+	if !slices.Equal(got, want) {
+		t.Fatalf("got lines %q, want %q", got, want)
+	}
+}
+
+func TestExecNotFound(t *testing.T) {
+	s, _, _ := start(t)
+
+	if err := s.Exec(context.Background(), "child", "/nonexistent/command"); err == nil {
+		t.Fatal("Exec of a missing command succeeded")
+	}
+}
@@ -0,0 +1,421 @@
+package asynclog
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Expr is a compiled filter or routing expression evaluated against records,
+// such as
+//
+//	level >= WARN && source == "payments"
+//	!(msg =~ "^healthcheck") || level == ERROR
+//	fields.tenant == "acme" && fields.status =~ "^5"
+//
+// Fields are level, source, msg and fields.<key>, the value of the record
+// field with that key as it is written, or "" if the record has none; keys
+// are made of letters, digits, underscores and dots. Levels compare by
+// severity and can be written as DEBUG, INFO, WARN and ERROR. Operators are ==, !=, <, <=, >, >=,
+// =~ (regular expression match, the pattern must be a string literal), !, &&,
+// || and parentheses. Strings are double-quoted with Go escapes.
+type Expr struct {
+	src  string
+	root exprNode
+}
+
+// CompileExpr parses and type-checks src.
+func CompileExpr(src string) (*Expr, error) {
+	p := &exprParser{src: src}
+	if err := p.lex(); err != nil {
+		return nil, fmt.Errorf("asynclog: expression %q: %w", src, err)
+	}
+
+	root, err := p.parseOr()
+	if err == nil && p.pos < len(p.toks) {
+		err = fmt.Errorf("unexpected %q", p.toks[p.pos].text)
+	}
+	if err == nil && root.kind() != kindBool {
+		err = fmt.Errorf("expression is not a condition")
+	}
+	if err != nil {
+		return nil, fmt.Errorf("asynclog: expression %q: %w", src, err)
+	}
+
+	return &Expr{src: src, root: root}, nil
+}
+
+// MustCompileExpr is CompileExpr panicking on error, for expressions known at
+// compile time.
+func MustCompileExpr(src string) *Expr {
+	e, err := CompileExpr(src)
+	if err != nil {
+		panic(err)
+	}
+
+	return e
+}
+
+func (e *Expr) String() string {
+	return e.src
+}
+
+func (e *Expr) match(rec *record) bool {
+	return e.root.eval(rec).b
+}
+
+type exprKind int
+
+const (
+	kindBool exprKind = iota
+	kindString
+	kindLevel
+)
+
+type exprValue struct {
+	b bool
+	s string
+	l Level
+}
+
+type exprNode interface {
+	kind() exprKind
+	eval(rec *record) exprValue
+}
+
+type fieldNode string
+
+func (f fieldNode) kind() exprKind {
+	if f == "level" {
+		return kindLevel
+	}
+
+	return kindString
+}
+
+func (f fieldNode) eval(rec *record) exprValue {
+	switch f {
+	case "level":
+		return exprValue{l: rec.level}
+	case "source":
+		return exprValue{s: rec.source}
+	default:
+		return exprValue{s: rec.msg}
+	}
+}
+
+// recordFieldNode is the value of a record field, fields.<key>.
+type recordFieldNode string
+
+func (f recordFieldNode) kind() exprKind { return kindString }
+
+func (f recordFieldNode) eval(rec *record) exprValue {
+	for _, fd := range rec.fields {
+		if fd.Key != string(f) {
+			continue
+		}
+		if v, ok := fd.Value.(string); ok {
+			return exprValue{s: v}
+		}
+		return exprValue{s: fmt.Sprint(fd.Value)}
+	}
+
+	return exprValue{}
+}
+
+type constNode struct {
+	k exprKind
+	v exprValue
+}
+
+func (c constNode) kind() exprKind         { return c.k }
+func (c constNode) eval(*record) exprValue { return c.v }
+
+type notNode struct{ x exprNode }
+
+func (n notNode) kind() exprKind             { return kindBool }
+func (n notNode) eval(rec *record) exprValue { return exprValue{b: !n.x.eval(rec).b} }
+
+type logicNode struct {
+	and  bool
+	x, y exprNode
+}
+
+func (n logicNode) kind() exprKind { return kindBool }
+
+func (n logicNode) eval(rec *record) exprValue {
+	x := n.x.eval(rec).b
+	if n.and != x {
+		return exprValue{b: x}
+	}
+
+	return n.y.eval(rec)
+}
+
+type matchNode struct {
+	x  exprNode
+	re *regexp.Regexp
+}
+
+func (n matchNode) kind() exprKind { return kindBool }
+
+func (n matchNode) eval(rec *record) exprValue {
+	return exprValue{b: n.re.MatchString(n.x.eval(rec).s)}
+}
+
+type compareNode struct {
+	op   string
+	x, y exprNode
+}
+
+func (n compareNode) kind() exprKind { return kindBool }
+
+func (n compareNode) eval(rec *record) exprValue {
+	x, y := n.x.eval(rec), n.y.eval(rec)
+
+	var c int
+	switch n.x.kind() {
+	case kindLevel:
+		c = int(x.l) - int(y.l)
+	case kindString:
+		c = strings.Compare(x.s, y.s)
+	default:
+		if x.b != y.b {
+			c = 1
+		}
+	}
+
+	switch n.op {
+	case "==":
+		return exprValue{b: c == 0}
+	case "!=":
+		return exprValue{b: c != 0}
+	case "<":
+		return exprValue{b: c < 0}
+	case "<=":
+		return exprValue{b: c <= 0}
+	case ">":
+		return exprValue{b: c > 0}
+	default:
+		return exprValue{b: c >= 0}
+	}
+}
+
+type exprToken struct {
+	text string
+	str  bool // quoted string literal, text is unquoted
+}
+
+type exprParser struct {
+	src  string
+	toks []exprToken
+	pos  int
+}
+
+func (p *exprParser) lex() error {
+	s := p.src
+	for i := 0; i < len(s); {
+		c := rune(s[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c == '"':
+			j := i + 1
+			for j < len(s) && s[j] != '"' {
+				if s[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			if j >= len(s) {
+				return fmt.Errorf("unterminated string")
+			}
+
+			str, err := strconv.Unquote(s[i : j+1])
+			if err != nil {
+				return fmt.Errorf("bad string %s: %w", s[i:j+1], err)
+			}
+
+			p.toks = append(p.toks, exprToken{text: str, str: true})
+			i = j + 1
+		case c == '_' || unicode.IsLetter(c):
+			j := i
+			for j < len(s) && (s[j] == '_' || s[j] == '.' || unicode.IsLetter(rune(s[j])) || unicode.IsDigit(rune(s[j]))) {
+				j++
+			}
+
+			p.toks = append(p.toks, exprToken{text: s[i:j]})
+			i = j
+		default:
+			op := ""
+			for _, o := range []string{"&&", "||", "==", "!=", "<=", ">=", "=~", "<", ">", "!", "(", ")"} {
+				if strings.HasPrefix(s[i:], o) {
+					op = o
+					break
+				}
+			}
+			if op == "" {
+				return fmt.Errorf("unexpected %q", c)
+			}
+
+			p.toks = append(p.toks, exprToken{text: op})
+			i += len(op)
+		}
+	}
+
+	return nil
+}
+
+func (p *exprParser) peek(op string) bool {
+	return p.pos < len(p.toks) && !p.toks[p.pos].str && p.toks[p.pos].text == op
+}
+
+func (p *exprParser) parseOr() (exprNode, error) {
+	return p.parseLogic("||", p.parseAnd)
+}
+
+func (p *exprParser) parseAnd() (exprNode, error) {
+	return p.parseLogic("&&", p.parseUnary)
+}
+
+func (p *exprParser) parseLogic(op string, next func() (exprNode, error)) (exprNode, error) {
+	x, err := next()
+	for err == nil && p.peek(op) {
+		p.pos++
+
+		var y exprNode
+		if y, err = next(); err != nil {
+			break
+		}
+		if x.kind() != kindBool || y.kind() != kindBool {
+			return nil, fmt.Errorf("%s needs conditions on both sides", op)
+		}
+
+		x = logicNode{and: op == "&&", x: x, y: y}
+	}
+
+	return x, err
+}
+
+func (p *exprParser) parseUnary() (exprNode, error) {
+	if p.peek("!") {
+		p.pos++
+
+		x, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		if x.kind() != kindBool {
+			return nil, fmt.Errorf("! needs a condition")
+		}
+
+		return notNode{x: x}, nil
+	}
+
+	return p.parseCompare()
+}
+
+func (p *exprParser) parseCompare() (exprNode, error) {
+	x, err := p.parsePrimary()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, op := range []string{"==", "!=", "<=", ">=", "<", ">", "=~"} {
+		if !p.peek(op) {
+			continue
+		}
+		p.pos++
+
+		if op == "=~" {
+			if x.kind() != kindString || p.pos >= len(p.toks) || !p.toks[p.pos].str {
+				return nil, fmt.Errorf("=~ needs a string field and a string pattern")
+			}
+
+			re, err := regexp.Compile(p.toks[p.pos].text)
+			if err != nil {
+				return nil, err
+			}
+			p.pos++
+
+			return matchNode{x: x, re: re}, nil
+		}
+
+		y, err := p.parsePrimary()
+		if err != nil {
+			return nil, err
+		}
+
+		// A string compared with a level is read as a level name.
+		if c, ok := y.(constNode); ok && c.k == kindString && x.kind() == kindLevel {
+			if y, err = levelConst(c.v.s); err != nil {
+				return nil, err
+			}
+		}
+		if c, ok := x.(constNode); ok && c.k == kindString && y.kind() == kindLevel {
+			if x, err = levelConst(c.v.s); err != nil {
+				return nil, err
+			}
+		}
+
+		if x.kind() != y.kind() {
+			return nil, fmt.Errorf("%s compares values of different types", op)
+		}
+
+		return compareNode{op: op, x: x, y: y}, nil
+	}
+
+	return x, nil
+}
+
+func levelConst(name string) (exprNode, error) {
+	l, err := ParseLevel(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return constNode{k: kindLevel, v: exprValue{l: l}}, nil
+}
+
+func (p *exprParser) parsePrimary() (exprNode, error) {
+	if p.pos >= len(p.toks) {
+		return nil, fmt.Errorf("unexpected end of expression")
+	}
+
+	tok := p.toks[p.pos]
+	p.pos++
+
+	if tok.str {
+		return constNode{k: kindString, v: exprValue{s: tok.text}}, nil
+	}
+
+	switch tok.text {
+	case "(":
+		x, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if !p.peek(")") {
+			return nil, fmt.Errorf("missing )")
+		}
+		p.pos++
+
+		return x, nil
+	case "level", "source", "msg":
+		return fieldNode(tok.text), nil
+	case "true", "false":
+		return constNode{k: kindBool, v: exprValue{b: tok.text == "true"}}, nil
+	}
+
+	if key, ok := strings.CutPrefix(tok.text, "fields."); ok && key != "" {
+		return recordFieldNode(key), nil
+	}
+
+	if n, err := levelConst(tok.text); err == nil {
+		return n, nil
+	}
+
+	return nil, fmt.Errorf("unknown name %q", tok.text)
+}
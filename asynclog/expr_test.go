@@ -0,0 +1,131 @@
+package asynclog_test
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"test-task-log/asynclog"
+	"test-task-log/asynclog/testutil"
+)
+
+func TestCompileExprErrors(t *testing.T) {
+	for _, src := range []string{
+		"level >>> (",
+		"msg",
+		"level == \"LOUD\"",
+		"source =~ msg",
+		"level =~ \"x\"",
+		"fields. == \"a\"",
+		"fields.a == INFO",
+		"(msg == \"a\"",
+	} {
+		if _, err := asynclog.CompileExpr(src); err == nil {
+			t.Errorf("CompileExpr(%q) succeeded", src)
+		}
+	}
+}
+
+func TestFilterExpr(t *testing.T) {
+	filter, err := asynclog.CompileExpr(`level >= WARN && (source == "payments" || fields.tenant == "acme") && !(msg =~ "^health")`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, sink, _ := start(t, asynclog.WithFilter(filter))
+
+	ctx := context.Background()
+	for _, e := range []asynclog.Entry{
+		{Level: asynclog.LevelError, Source: "payments", Message: "declined"},
+		{Level: asynclog.LevelInfo, Source: "payments", Message: "too low"},
+		{Level: asynclog.LevelWarn, Source: "api", Message: "tenant", Fields: []asynclog.Field{{Key: "tenant", Value: "acme"}}},
+		{Level: asynclog.LevelWarn, Source: "api", Message: "other tenant", Fields: []asynclog.Field{{Key: "tenant", Value: "other"}}},
+		{Level: asynclog.LevelError, Source: "payments", Message: "healthcheck"},
+	} {
+		if err := s.Log(ctx, e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Shutdown(testContext(t)); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := sink.Lines(), []string{"declined", "tenant tenant=acme"}; !slices.Equal(got, want) {
+		t.Fatalf("got lines %q, want %q", got, want)
+	}
+}
+
+func TestConfigRouteFields(t *testing.T) {
+	c := &asynclog.Config{Sinks: map[string]string{"acme": "stdout:"}, Routes: []asynclog.Route{
+		{Sink: "acme", Fields: map[string]string{"tenant": "acme", "status": "500"}},
+	}}
+	s, _, _ := start(t)
+	routed := testutil.NewSink()
+	if err := s.AddSink("acme", routed); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.ApplyRoutes(c); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	for _, status := range []int{200, 500} {
+		e := asynclog.Entry{Message: "req", Fields: []asynclog.Field{{Key: "tenant", Value: "acme"}, {Key: "status", Value: status}}}
+		if err := s.Log(ctx, e); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Shutdown(testContext(t)); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := routed.Lines(), []string{"req tenant=acme status=500"}; !slices.Equal(got, want) {
+		t.Fatalf("got lines %q, want %q", got, want)
+	}
+}
+
+func TestRouteSinkAndSetFilter(t *testing.T) {
+	s, sink, _ := start(t)
+	errs := testutil.NewSink()
+	if err := s.AddSink("errors", errs); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.RouteSink("errors", asynclog.MustCompileExpr("level >= ERROR")); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.RouteSink("missing", nil); err == nil {
+		t.Fatal("routed a sink that doesn't exist")
+	}
+	s.SetFilter(asynclog.MustCompileExpr(`!(source == "noise")`))
+
+	ctx := context.Background()
+	logAll := func() {
+		t.Helper()
+		for _, e := range []asynclog.Entry{
+			{Level: asynclog.LevelInfo, Message: "a"},
+			{Level: asynclog.LevelError, Message: "e"},
+			{Level: asynclog.LevelError, Source: "noise", Message: "n"},
+		} {
+			if err := s.Log(ctx, e); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := s.Flush(testContext(t)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	logAll()
+	if got, want := sink.Lines(), []string{"a", "e"}; !slices.Equal(got, want) {
+		t.Fatalf("main writer got %q, want %q", got, want)
+	}
+	if got, want := errs.Lines(), []string{"e"}; !slices.Equal(got, want) {
+		t.Fatalf("routed sink got %q, want %q", got, want)
+	}
+
+	s.RouteSink("errors", nil)
+	s.SetFilter(nil)
+	logAll()
+	if got, want := errs.Lines(), []string{"e", "a", "e", "n"}; !slices.Equal(got, want) {
+		t.Fatalf("unrouted sink got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,126 @@
+package asynclog
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+// ErrSinkBehind is returned for writes to a queued sink whose queue is full.
+var ErrSinkBehind = errors.New("asynclog: sink behind")
+
+// WithSinkQueues gives every sink added with AddSink a goroutine and a queue
+// of up to depth batches of its own, so a slow or failing sink delays neither
+// the main writer nor the other sinks. A batch is delivered to a queued sink
+// once it is queued; write errors show up on the next batch, and a sink with
+// a full queue fails batches with ErrSinkBehind until it catches up. Barriers
+// don't wait for queued sinks, shutdown does.
+func WithSinkQueues(depth int) Option {
+	return func(s *Service) {
+		s.sinkQueue = max(depth, 1)
+	}
+}
+
+// queuedWriter writes to w from a goroutine of its own.
+type queuedWriter struct {
+	w     io.Writer
+	depth int
+	queue chan []byte
+
+	mu      sync.Mutex
+	idle    *sync.Cond
+	pending int
+	err     error
+	closed  bool
+}
+
+func newQueuedWriter(w io.Writer, depth int) *queuedWriter {
+	qw := &queuedWriter{w: w, depth: depth, queue: make(chan []byte, depth)}
+	qw.idle = sync.NewCond(&qw.mu)
+	go qw.run()
+
+	return qw
+}
+
+func (qw *queuedWriter) run() {
+	for p := range qw.queue {
+		_, err := writeContext(context.Background(), qw.w, p)
+		if err == nil {
+			err = flushWriter(qw.w)
+		}
+
+		qw.mu.Lock()
+		if err != nil {
+			qw.err = err
+		}
+		qw.pending--
+		qw.idle.Broadcast()
+		qw.mu.Unlock()
+	}
+}
+
+// Write queues a copy of p, returning the error of an earlier write if any.
+func (qw *queuedWriter) Write(p []byte) (int, error) {
+	qw.mu.Lock()
+	defer qw.mu.Unlock()
+
+	if qw.closed {
+		return 0, ErrClosed
+	}
+
+	err := qw.err
+	qw.err = nil
+	if err != nil {
+		err = fmt.Errorf("asynclog: earlier write: %w", err)
+	}
+
+	if len(p) == 0 {
+		return 0, err
+	}
+
+	if qw.pending >= qw.depth {
+		return 0, ErrSinkBehind
+	}
+
+	qw.pending++
+	qw.queue <- append([]byte(nil), p...)
+
+	return len(p), err
+}
+
+// Sync waits for the queue to be written, then syncs w.
+func (qw *queuedWriter) Sync() error {
+	qw.mu.Lock()
+	for qw.pending > 0 {
+		qw.idle.Wait()
+	}
+	qw.mu.Unlock()
+
+	return syncWriter(qw.w)
+}
+
+func (qw *queuedWriter) Probe(ctx context.Context) error {
+	return probe(ctx, qw.w)
+}
+
+// Close stops the goroutine once the queue is written. It doesn't close w.
+func (qw *queuedWriter) Close() error {
+	qw.mu.Lock()
+	defer qw.mu.Unlock()
+
+	if !qw.closed {
+		qw.closed = true
+		close(qw.queue)
+	}
+
+	return nil
+}
+
+// closeQueued closes w if it is a queued sink.
+func closeQueued(w io.Writer) {
+	if qw, ok := w.(*queuedWriter); ok {
+		qw.Close()
+	}
+}
@@ -0,0 +1,89 @@
+package asynclog_test
+
+import (
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"test-task-log/asynclog"
+	"test-task-log/asynclog/testutil"
+)
+
+// gatedSink is a testutil.Sink whose writes wait until release is closed.
+type gatedSink struct {
+	*testutil.Sink
+	release chan struct{}
+}
+
+func (w gatedSink) Write(p []byte) (int, error) {
+	<-w.release
+	return w.Sink.Write(p)
+}
+
+func TestSinkQueues(t *testing.T) {
+	s, main, _ := start(t, asynclog.WithBatchSize(1), asynclog.WithSinkQueues(2))
+	slow := gatedSink{Sink: testutil.NewSink(), release: make(chan struct{})}
+	var once sync.Once
+	release := func() { once.Do(func() { close(slow.release) }) }
+	t.Cleanup(release)
+	if err := s.AddSink("slow", slow); err != nil {
+		t.Fatal(err)
+	}
+
+	// The stalled sink holds one batch in its write and one in its queue
+	// without delaying the main writer, then falls behind.
+	for _, msg := range []string{"a", "b"} {
+		if err := s.PrintSync(testContext(t), msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.PrintSync(testContext(t), "c"); !errors.Is(err, asynclog.ErrSinkBehind) {
+		t.Fatalf("got %v with the sink's queue full, want ErrSinkBehind", err)
+	}
+	if got, want := main.Lines(), []string{"a", "b", "c"}; !slices.Equal(got, want) {
+		t.Fatalf("main writer got %q, want %q", got, want)
+	}
+
+	// Shutdown waits for the queued batches.
+	release()
+	if err := s.Shutdown(testContext(t)); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := slow.Lines(), []string{"a", "b"}; !slices.Equal(got, want) {
+		t.Fatalf("queued sink got %q, want %q", got, want)
+	}
+}
+
+func TestSinkQueuesEarlierError(t *testing.T) {
+	s, _, _ := start(t, asynclog.WithBatchSize(1), asynclog.WithSinkQueues(1))
+	failing := testutil.NewSink()
+	down := errors.New("down")
+	failing.Fail(down)
+	if err := s.AddSink("failing", failing); err != nil {
+		t.Fatal(err)
+	}
+
+	// The batch is handed over before the sink fails it; a later one gets
+	// the error, once the sink is done with the first.
+	if err := s.PrintSync(testContext(t), "a"); err != nil {
+		t.Fatal(err)
+	}
+	ctx := testContext(t)
+	for {
+		err := s.PrintSync(ctx, "b")
+		if errors.Is(err, down) {
+			break
+		}
+		if !errors.Is(err, asynclog.ErrSinkBehind) {
+			t.Fatalf("got %v, want the earlier write error", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			t.Fatal("the earlier write error never showed up")
+		case <-time.After(time.Millisecond):
+		}
+	}
+}
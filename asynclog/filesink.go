@@ -0,0 +1,383 @@
+package asynclog
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// rotatedFormat is the timestamp appended to the name of rotated files. It
+// sorts in time order.
+const rotatedFormat = "20060102T150405.000000000"
+
+// AtomicWriteSize is the write size POSIX guarantees to be atomic for pipes,
+// PIPE_BUF on Linux, and a sensible FileSinkOptions.AtomicWrite.
+const AtomicWriteSize = 4096
+
+// FileSinkOptions says when a FileSink rotates and which rotated files it
+// keeps. Zero values disable the corresponding limit.
+type FileSinkOptions struct {
+	// MaxSize rotates before a write that would grow the file beyond this
+	// many bytes.
+	MaxSize int64
+	// MaxAge rotates before a write once the file is this old.
+	MaxAge time.Duration
+	// Compress gzips rotated files in the background.
+	Compress bool
+	// MaxBackups removes the oldest rotated files beyond this many.
+	MaxBackups int
+	// MaxBackupAge removes rotated files older than this.
+	MaxBackupAge time.Duration
+	// AtomicWrite splits batches at line ends into O_APPEND writes of at
+	// most this many bytes, such as AtomicWriteSize, so lines of processes
+	// appending to the same file never interleave and a crash can cut a
+	// batch short but never tear a line. A line longer than this is still
+	// written at once.
+	AtomicWrite int
+}
+
+// FileSink is an append-only file that rotates by size or age. Rotated files
+// are renamed to the path with a timestamp appended, such as
+// app.log.20240102T150405.000000000, and optionally gzipped. A batch is never
+// split across files: rotation only happens between writes, so a batch
+// larger than MaxSize gets a file of its own.
+//
+// OpenSink builds one for file URIs with rotation parameters, such as
+// file:///var/log/app.log?max_size=100MB&max_age=24h&compress=1&max_backups=7,
+// or with atomic=4KB for AtomicWrite.
+type FileSink struct {
+	path string
+	opts FileSinkOptions
+
+	mu      sync.Mutex
+	f       *os.File
+	size    int64
+	opened  time.Time
+	closed  bool
+	pending sync.WaitGroup // compressions in progress
+}
+
+// NewFileSink opens or creates the file at path for appending.
+func NewFileSink(path string, opts FileSinkOptions) (*FileSink, error) {
+	if opts.MaxSize < 0 || opts.MaxAge < 0 || opts.MaxBackups < 0 || opts.MaxBackupAge < 0 || opts.AtomicWrite < 0 {
+		return nil, fmt.Errorf("asynclog: file sink %s: negative limit", path)
+	}
+
+	fs := &FileSink{path: path, opts: opts}
+	if err := fs.open(); err != nil {
+		return nil, err
+	}
+
+	return fs, nil
+}
+
+func (fs *FileSink) open() error {
+	f, err := os.OpenFile(fs.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+
+	fs.f, fs.size, fs.opened = f, fi.Size(), time.Now()
+
+	return nil
+}
+
+// Write appends p to the file, rotating it first if p would take it over
+// MaxSize or it is older than MaxAge. Under AtomicWrite p may be written in
+// several writes, all to the same file.
+func (fs *FileSink) Write(p []byte) (int, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	if fs.closed {
+		return 0, ErrClosed
+	}
+
+	if fs.f == nil {
+		if err := fs.open(); err != nil {
+			return 0, err
+		}
+	}
+
+	if fs.size > 0 && fs.due(len(p)) {
+		if err := fs.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	if fs.opts.AtomicWrite > 0 {
+		return fs.writeAtomic(p)
+	}
+
+	n, err := fs.f.Write(p)
+	fs.size += int64(n)
+
+	return n, err
+}
+
+func (fs *FileSink) writeAtomic(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		n, err := fs.f.Write(atomicChunk(p, fs.opts.AtomicWrite))
+		written += n
+		fs.size += int64(n)
+		if err != nil {
+			return written, err
+		}
+		p = p[n:]
+	}
+
+	return written, nil
+}
+
+// atomicChunk returns the lines at the start of p that fit in size bytes, or
+// the first line if it doesn't fit on its own.
+func atomicChunk(p []byte, size int) []byte {
+	if len(p) <= size {
+		return p
+	}
+
+	if i := bytes.LastIndexByte(p[:size], '\n'); i >= 0 {
+		return p[:i+1]
+	}
+	if i := bytes.IndexByte(p, '\n'); i >= 0 {
+		return p[:i+1]
+	}
+
+	return p
+}
+
+func (fs *FileSink) due(n int) bool {
+	return fs.opts.MaxSize > 0 && fs.size+int64(n) > fs.opts.MaxSize ||
+		fs.opts.MaxAge > 0 && time.Since(fs.opened) >= fs.opts.MaxAge
+}
+
+// Rotate rotates the file now, e.g. on a signal from an external log
+// rotation schedule.
+func (fs *FileSink) Rotate() error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	if fs.closed {
+		return ErrClosed
+	}
+
+	return fs.rotate()
+}
+
+// rotate renames the current file and opens a new one. If closing or
+// renaming the current file fails it is opened again, so writes carry on in
+// it; if no file can be opened, the next write tries again.
+func (fs *FileSink) rotate() error {
+	if err := fs.f.Close(); err != nil {
+		return fs.reopen(err)
+	}
+
+	rotated := fs.path + "." + time.Now().UTC().Format(rotatedFormat)
+	if err := os.Rename(fs.path, rotated); err != nil {
+		return fs.reopen(err)
+	}
+
+	if err := fs.reopen(nil); err != nil {
+		return err
+	}
+
+	fs.pending.Add(1)
+	go func() {
+		defer fs.pending.Done()
+
+		if fs.opts.Compress {
+			compressFile(rotated)
+		}
+		fs.prune()
+	}()
+
+	return nil
+}
+
+// reopen opens the file at path again after err closing or rotating it,
+// leaving f nil if that fails too.
+func (fs *FileSink) reopen(err error) error {
+	fs.f = nil
+	if oerr := fs.open(); oerr != nil {
+		return errors.Join(err, oerr)
+	}
+
+	return err
+}
+
+// compressFile gzips path into path.gz and removes path. On failure path is
+// left as it is.
+func compressFile(path string) error {
+	in, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(path+".gz", os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+
+	zw := gzip.NewWriter(out)
+	_, err = io.Copy(zw, in)
+	if err == nil {
+		err = zw.Close()
+	}
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(path + ".gz")
+		return err
+	}
+
+	return os.Remove(path)
+}
+
+// prune removes the rotated files beyond MaxBackups or older than
+// MaxBackupAge.
+func (fs *FileSink) prune() {
+	if fs.opts.MaxBackups == 0 && fs.opts.MaxBackupAge == 0 {
+		return
+	}
+
+	backups := fs.backups()
+	for i, b := range backups {
+		newer := len(backups) - i - 1
+		if fs.opts.MaxBackups > 0 && newer >= fs.opts.MaxBackups ||
+			fs.opts.MaxBackupAge > 0 && time.Since(b.at) > fs.opts.MaxBackupAge {
+			os.Remove(b.path)
+		}
+	}
+}
+
+type backup struct {
+	path string
+	at   time.Time
+}
+
+// backups returns the rotated files, oldest first.
+func (fs *FileSink) backups() []backup {
+	matches, _ := filepath.Glob(fs.path + ".*")
+
+	var backups []backup
+	for _, m := range matches {
+		stamp := strings.TrimSuffix(strings.TrimPrefix(m, fs.path+"."), ".gz")
+		at, err := time.Parse(rotatedFormat, stamp)
+		if err != nil {
+			continue
+		}
+
+		// A file being compressed shows up twice; count it once.
+		if !strings.HasSuffix(m, ".gz") && slices.Contains(matches, m+".gz") {
+			continue
+		}
+
+		backups = append(backups, backup{path: m, at: at})
+	}
+
+	slices.SortFunc(backups, func(a, b backup) int { return a.at.Compare(b.at) })
+
+	return backups
+}
+
+func (fs *FileSink) Sync() error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	if fs.closed {
+		return ErrClosed
+	}
+	if fs.f == nil {
+		return nil
+	}
+
+	return fs.f.Sync()
+}
+
+// Close closes the file once the rotated files are compressed and pruned.
+func (fs *FileSink) Close() error {
+	fs.mu.Lock()
+	if fs.closed {
+		fs.mu.Unlock()
+		return nil
+	}
+	fs.closed = true
+	var err error
+	if fs.f != nil {
+		err = fs.f.Close()
+	}
+	fs.mu.Unlock()
+
+	fs.pending.Wait()
+
+	return err
+}
+
+// fileSinkOptions reads the rotation and atomic write parameters of a file
+// URI, reporting whether there are any.
+func fileSinkOptions(q url.Values) (FileSinkOptions, bool, error) {
+	var opts FileSinkOptions
+	set := false
+	for _, p := range []struct {
+		name  string
+		parse func(string) error
+	}{
+		{"max_size", func(v string) (err error) { opts.MaxSize, err = parseSize(v); return }},
+		{"max_age", func(v string) (err error) { opts.MaxAge, err = time.ParseDuration(v); return }},
+		{"compress", func(v string) (err error) { opts.Compress, err = strconv.ParseBool(v); return }},
+		{"max_backups", func(v string) (err error) { opts.MaxBackups, err = strconv.Atoi(v); return }},
+		{"max_backup_age", func(v string) (err error) { opts.MaxBackupAge, err = time.ParseDuration(v); return }},
+		{"atomic", func(v string) error { n, err := parseSize(v); opts.AtomicWrite = int(n); return err }},
+	} {
+		if !q.Has(p.name) {
+			continue
+		}
+		set = true
+		if err := p.parse(q.Get(p.name)); err != nil {
+			return opts, false, fmt.Errorf("%s: %w", p.name, err)
+		}
+	}
+
+	return opts, set, nil
+}
+
+// parseSize parses a size in bytes with an optional KB, MB or GB suffix,
+// in powers of 1024.
+func parseSize(s string) (int64, error) {
+	mult := int64(1)
+	for _, u := range []struct {
+		suffix string
+		mult   int64
+	}{{"GB", 1 << 30}, {"MB", 1 << 20}, {"KB", 1 << 10}, {"B", 1}} {
+		if strings.HasSuffix(strings.ToUpper(s), u.suffix) {
+			s, mult = s[:len(s)-len(u.suffix)], u.mult
+			break
+		}
+	}
+
+	n, err := strconv.ParseInt(strings.TrimSpace(s), 10, 64)
+	if err != nil {
+		return 0, err
+	}
+
+	return n * mult, nil
+}
@@ -0,0 +1,108 @@
+package asynclog_test
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+
+	"test-task-log/asynclog"
+)
+
+func TestFileSinkFailedRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	fs, err := asynclog.NewFileSink(path, asynclog.FileSinkOptions{MaxSize: 1 << 20})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fs.Close()
+
+	// Renaming a file that is gone fails; the sink has to carry on with a
+	// fresh one rather than the handle it closed.
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	if err := fs.Rotate(); err == nil {
+		t.Fatal("rotating a removed file succeeded")
+	}
+
+	if _, err := fs.Write([]byte("after\n")); err != nil {
+		t.Fatalf("write after a failed rotation: %v", err)
+	}
+	if b, err := os.ReadFile(path); err != nil || string(b) != "after\n" {
+		t.Fatalf("got %q, %v, want the write in a reopened file", b, err)
+	}
+}
+
+func TestOpenSinkVerifyParam(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	if _, err := asynclog.OpenSink("file://" + path + "?verify=yes"); err == nil {
+		t.Fatal("verify=yes accepted")
+	}
+	if _, err := asynclog.OpenSink("file://" + path + "?verify=false&max_size=1MB"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFileSinkAtomicWrite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+
+	// Two processes' worth of sinks append batches larger than the write
+	// size to the same file; no line may be torn.
+	var wg sync.WaitGroup
+	for _, c := range []string{"a", "b"} {
+		w, err := asynclog.OpenSink("file://" + path + "?atomic=4KB")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer w.(io.Closer).Close()
+
+		batch := []byte(strings.Repeat(strings.Repeat(c, 99)+"\n", 100))
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 20 {
+				if n, err := w.Write(batch); n != len(batch) || err != nil {
+					t.Errorf("got %d, %v, want the whole batch written", n, err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(string(b), "\n"), "\n")
+	if len(lines) != 2*20*100 {
+		t.Fatalf("got %d lines, want %d", len(lines), 2*20*100)
+	}
+	for _, l := range lines {
+		if l != strings.Repeat("a", 99) && l != strings.Repeat("b", 99) {
+			t.Fatalf("got torn line %q", l)
+		}
+	}
+
+	// A line longer than the write size still goes out whole.
+	fs, err := asynclog.NewFileSink(path, asynclog.FileSinkOptions{AtomicWrite: 8})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer fs.Close()
+	if n, err := fs.Write([]byte("short\n0123456789\n")); n != 17 || err != nil {
+		t.Fatalf("got %d, %v, want the whole batch written", n, err)
+	}
+}
+
+func TestFileSinkAtomicWriteInvalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	if _, err := asynclog.NewFileSink(path, asynclog.FileSinkOptions{AtomicWrite: -1}); err == nil {
+		t.Fatal("negative atomic write size accepted")
+	}
+	if _, err := asynclog.OpenSink("file://" + path + "?atomic=lots"); err == nil {
+		t.Fatal("atomic=lots accepted")
+	}
+}
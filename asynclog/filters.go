@@ -0,0 +1,68 @@
+package asynclog
+
+import (
+	"regexp"
+	"strings"
+	"sync/atomic"
+)
+
+// Filter decides whether an entry is kept. Filters run in the producer before
+// the entry is queued, so dropped entries never take buffer space. They must
+// be safe for concurrent use.
+type Filter interface {
+	Keep(e Entry) bool
+}
+
+// FilterFunc adapts a function to Filter.
+type FilterFunc func(e Entry) bool
+
+func (f FilterFunc) Keep(e Entry) bool { return f(e) }
+
+// WithFilters adds filters to the chain applied to every record before it is
+// queued, in order, after the WithFilter expression if any. A record is
+// dropped by the first filter that doesn't keep it.
+func WithFilters(filters ...Filter) Option {
+	return func(s *Service) {
+		s.filters = append(s.filters, filters...)
+	}
+}
+
+// MinLevel keeps entries at level or above.
+func MinLevel(level Level) Filter {
+	return FilterFunc(func(e Entry) bool { return e.Level >= level })
+}
+
+// Matching keeps entries whose message matches re.
+func Matching(re *regexp.Regexp) Filter {
+	return FilterFunc(func(e Entry) bool { return re.MatchString(e.Message) })
+}
+
+// NotMatching drops entries whose message matches re.
+func NotMatching(re *regexp.Regexp) Filter {
+	return FilterFunc(func(e Entry) bool { return !re.MatchString(e.Message) })
+}
+
+// Containing keeps entries whose message contains substr.
+func Containing(substr string) Filter {
+	return FilterFunc(func(e Entry) bool { return strings.Contains(e.Message, substr) })
+}
+
+// NotContaining drops entries whose message contains substr.
+func NotContaining(substr string) Filter {
+	return FilterFunc(func(e Entry) bool { return !strings.Contains(e.Message, substr) })
+}
+
+// Sample keeps one in n entries below level, the first of every n, and every
+// entry at level or above: Sample(100, LevelWarn) keeps 1% of debug and info
+// entries and all warnings and errors. Each level is sampled on its own.
+func Sample(n int, level Level) Filter {
+	var seen [LevelError + 1]atomic.Uint64
+
+	return FilterFunc(func(e Entry) bool {
+		if e.Level >= level || n <= 1 || e.Level < LevelDebug || e.Level > LevelError {
+			return true
+		}
+
+		return (seen[e.Level].Add(1)-1)%uint64(n) == 0
+	})
+}
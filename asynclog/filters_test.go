@@ -0,0 +1,66 @@
+package asynclog_test
+
+import (
+	"regexp"
+	"slices"
+	"testing"
+
+	"test-task-log/asynclog"
+)
+
+func TestFilters(t *testing.T) {
+	s, sink, _ := start(t, asynclog.WithFilters(
+		asynclog.MinLevel(asynclog.LevelInfo),
+		asynclog.Matching(regexp.MustCompile(`^req`)),
+		asynclog.NotContaining("/health"),
+		asynclog.NotMatching(regexp.MustCompile(`\bsecret\b`)),
+		asynclog.Containing(" "),
+	))
+
+	for _, r := range []struct {
+		level asynclog.Level
+		msg   string
+	}{
+		{asynclog.LevelInfo, "req /users"},
+		{asynclog.LevelDebug, "req /debug"},
+		{asynclog.LevelInfo, "started"},
+		{asynclog.LevelInfo, "req /health"},
+		{asynclog.LevelWarn, "req secret token"},
+		{asynclog.LevelInfo, "req"},
+		{asynclog.LevelError, "req /orders"},
+	} {
+		if err := printShort(s, "", r.level, r.msg); err != nil {
+			t.Fatalf("Print(%q): %v", r.msg, err)
+		}
+	}
+	if err := s.Flush(testContext(t)); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := sink.Lines(), []string{"req /users", "req /orders"}; !slices.Equal(got, want) {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
+
+func TestSample(t *testing.T) {
+	f := asynclog.Sample(3, asynclog.LevelWarn)
+
+	var kept []int
+	for i := range 7 {
+		if f.Keep(asynclog.Entry{Level: asynclog.LevelInfo}) {
+			kept = append(kept, i)
+		}
+		if !f.Keep(asynclog.Entry{Level: asynclog.LevelError}) {
+			t.Fatal("error entry sampled out")
+		}
+	}
+	if want := []int{0, 3, 6}; !slices.Equal(kept, want) {
+		t.Fatalf("kept info entries %v, want %v", kept, want)
+	}
+
+	// Levels are sampled apart: the first debug entry is kept regardless of
+	// the info ones.
+	if !f.Keep(asynclog.Entry{Level: asynclog.LevelDebug}) {
+		t.Fatal("first debug entry dropped")
+	}
+}
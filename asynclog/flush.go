@@ -0,0 +1,85 @@
+package asynclog
+
+import "context"
+
+// flushReply is Run's answer to Flush: the writes to wait for, the last of
+// them writing the buffered records, and where the batches of those report
+// their errors.
+type flushReply struct {
+	writes  []chan struct{}
+	err     chan error
+	batches int
+}
+
+// Flush writes whatever is queued or buffered right away instead of at the
+// next interval, e.g. before a checkpoint or in tests, and returns once the
+// writer call completes, with its error, or when ctx ends. Under
+// WithMaxBatchBytes the records may be written in several calls, and the
+// first error is returned. Batches written before it are waited for as
+// well, but their errors aren't returned.
+func (s *Service) Flush(ctx context.Context) error {
+	req := make(chan flushReply, 1)
+
+	select {
+	case s.flushCh <- req:
+	case <-s.done:
+		return ErrClosed
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	reply := <-req
+	for _, w := range reply.writes {
+		select {
+		case <-w:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	var first error
+	for range reply.batches {
+		if err := <-reply.err; err != nil && first == nil {
+			first = err
+		}
+	}
+
+	return first
+}
+
+// flushNow runs in Run: it hands everything accepted so far to a write
+// goroutine reporting its error to Flush.
+func (s *Service) flushNow() flushReply {
+	s.addQueued()
+	s.flushSinks()
+
+	var reply flushReply
+	if recs := s.take(s.buffers()...); len(recs) > 0 {
+		s.debugf("flush (manual): %d records", len(recs))
+		s.event(EventFlush, "manual", recs)
+		parts := s.split(recs)
+		reply.err = make(chan error, len(parts))
+		reply.batches = len(parts)
+
+		for _, part := range parts {
+			if s.encodeCh != nil {
+				s.pipeline(part, reply.err, false)
+				continue
+			}
+
+			o := s.prepare(part)
+			n := int64(o.records)
+
+			s.inflight.Add(n)
+			s.spawn(func() {
+				reply.err <- s.send(o)
+				s.inflight.Add(-n)
+			})
+		}
+	}
+
+	s.pruneWrites()
+	reply.writes = append([]chan struct{}(nil), s.writes...)
+
+	return reply
+}
@@ -0,0 +1,61 @@
+package asynclog_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"test-task-log/asynclog"
+)
+
+func TestFlush(t *testing.T) {
+	s, sink, _ := start(t)
+	printAll(t, s, "a", "b")
+
+	if err := s.Flush(testContext(t)); err != nil {
+		t.Fatal(err)
+	}
+	if got := sink.Lines(); !slices.Equal(got, []string{"a", "b"}) {
+		t.Fatalf("got %q right after Flush, want the buffered records", got)
+	}
+
+	if err := s.Flush(testContext(t)); err != nil {
+		t.Fatalf("Flush with nothing buffered: %v", err)
+	}
+}
+
+func TestFlushErrors(t *testing.T) {
+	s, sink, _ := start(t)
+
+	errDown := errors.New("down")
+	sink.Fail(errDown)
+	printAll(t, s, "a")
+	if err := s.Flush(testContext(t)); !errors.Is(err, errDown) {
+		t.Fatalf("got %v, want the writer's error", err)
+	}
+	sink.Fail(nil)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	w := gateWriter{release: make(chan struct{})}
+	defer close(w.release)
+	if err := s.SetWriter(w); err != nil {
+		t.Fatal(err)
+	}
+	printAll(t, s, "b")
+	if err := s.Flush(ctx); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Flush into a stuck writer: got %v, want the context's error", err)
+	}
+}
+
+func TestFlushClosed(t *testing.T) {
+	s, _, _ := start(t)
+	if err := s.Shutdown(testContext(t)); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Flush(testContext(t)); !errors.Is(err, asynclog.ErrClosed) {
+		t.Fatalf("got %v, want ErrClosed", err)
+	}
+}
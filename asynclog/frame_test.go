@@ -0,0 +1,69 @@
+package asynclog_test
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"test-task-log/asynclog"
+)
+
+func TestBinaryFraming(t *testing.T) {
+	s, sink, clock := start(t, asynclog.WithBinaryFraming())
+
+	t0 := clock.Now()
+	if err := printShort(s, "", asynclog.LevelWarn, "a"); err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(1500 * time.Millisecond)
+	if err := printShort(s, "", asynclog.LevelInfo, "b"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Barrier(testContext(t)); err != nil {
+		t.Fatal(err)
+	}
+
+	fr := asynclog.NewFrameReader(bytes.NewReader(bytes.Join(sink.Writes(), nil)))
+	recs, err := fr.Next()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []asynclog.FrameRecord{
+		{Time: t0, Level: asynclog.LevelWarn, Line: "a"},
+		{Time: t0.Add(1500 * time.Millisecond), Level: asynclog.LevelInfo, Line: "b"},
+	}
+	if len(recs) != len(want) {
+		t.Fatalf("got %d records, want %d", len(recs), len(want))
+	}
+	for i := range want {
+		if !recs[i].Time.Equal(want[i].Time) || recs[i].Level != want[i].Level || recs[i].Line != want[i].Line {
+			t.Fatalf("record %d: got %+v, want %+v", i, recs[i], want[i])
+		}
+	}
+	if _, err := fr.Next(); err != io.EOF {
+		t.Fatalf("got %v after the last frame, want io.EOF", err)
+	}
+}
+
+func TestFrameReaderErrors(t *testing.T) {
+	s, sink, _ := start(t, asynclog.WithBinaryFraming())
+	printAll(t, s, "hello")
+	if err := s.Barrier(testContext(t)); err != nil {
+		t.Fatal(err)
+	}
+	frame := bytes.Join(sink.Writes(), nil)
+
+	for name, tc := range map[string]struct {
+		data []byte
+		want string
+	}{
+		"truncated": {frame[:len(frame)-2], "truncated frame"},
+		"magic":     {append([]byte("XXXX"), frame[4:]...), "bad frame magic"},
+	} {
+		if _, err := asynclog.NewFrameReader(bytes.NewReader(tc.data)).Next(); err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Errorf("%s: got %v, want %q", name, err, tc.want)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package asynclog
+
+import (
+	"fmt"
+	"time"
+)
+
+// WithBatchHeader starts every batch with a line such as
+//
+//	#batch instance=web-1 seq=42 records=10 first=2024-05-01T10:00:00Z last=2024-05-01T10:00:04.5Z
+//
+// so downstream processors can check they got every batch, and all of each.
+// seq counts batches from 1 per service; first and last are the earliest and
+// latest enqueue times in the batch. Like the audit chain, which covers the
+// header, it only goes on the main payload, and not on binary frames, and
+// lines of records starting with # get another # prepended so they can't pass
+// for a header.
+func WithBatchHeader(instance string) Option {
+	return func(s *Service) {
+		s.header = &batchHeader{instance: instance}
+	}
+}
+
+type batchHeader struct {
+	instance string
+	seq      uint64
+}
+
+// line returns the header for recs. It runs wherever targets does, one batch
+// at a time.
+func (h *batchHeader) line(recs []record) []byte {
+	h.seq++
+
+	first, last := recs[0].time, recs[0].time
+	for _, rec := range recs[1:] {
+		if rec.time.Before(first) {
+			first = rec.time
+		}
+		if rec.time.After(last) {
+			last = rec.time
+		}
+	}
+
+	return fmt.Appendf(nil, "#batch instance=%s seq=%d records=%d first=%s last=%s\n",
+		h.instance, h.seq, len(recs), first.UTC().Format(time.RFC3339Nano), last.UTC().Format(time.RFC3339Nano))
+}
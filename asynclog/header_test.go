@@ -0,0 +1,47 @@
+package asynclog_test
+
+import (
+	"slices"
+	"testing"
+	"time"
+
+	"test-task-log/asynclog"
+)
+
+func TestBatchHeader(t *testing.T) {
+	s, sink, clock := start(t, asynclog.WithBatchHeader("web-1"))
+
+	printAll(t, s, "a")
+	clock.Advance(time.Second)
+	printAll(t, s, "b")
+	if err := s.Barrier(testContext(t)); err != nil {
+		t.Fatal(err)
+	}
+	printAll(t, s, "c")
+	if err := s.Barrier(testContext(t)); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		"#batch instance=web-1 seq=1 records=2 first=2024-01-01T00:00:00Z last=2024-01-01T00:00:01Z",
+		"a",
+		"b",
+		"#batch instance=web-1 seq=2 records=1 first=2024-01-01T00:00:01Z last=2024-01-01T00:00:01Z",
+		"c",
+	}
+	if got := sink.Lines(); !slices.Equal(got, want) {
+		t.Fatalf("got lines %q, want %q", got, want)
+	}
+}
+
+func TestBatchHeaderForged(t *testing.T) {
+	s, sink, _ := start(t, asynclog.WithBatchHeader("web-1"))
+
+	printAll(t, s, "#batch instance=web-1 seq=7 records=0")
+	if err := s.Barrier(testContext(t)); err != nil {
+		t.Fatal(err)
+	}
+	if got := sink.Lines(); len(got) != 2 || got[1] != "##batch instance=web-1 seq=7 records=0" {
+		t.Fatalf("got lines %q, want the record escaped", got)
+	}
+}
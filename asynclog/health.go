@@ -0,0 +1,74 @@
+package asynclog
+
+import (
+	"context"
+	"io"
+	"sync"
+	"time"
+)
+
+// Prober is implemented by writers that can check their destination without
+// writing a record, e.g. by pinging a connection. Writers that don't
+// implement it are probed with a zero-byte write.
+type Prober interface {
+	Probe(ctx context.Context) error
+}
+
+// Health is the writer status as last seen by a batch write or a probe.
+// CheckedAt is zero until the writer has been used or probed once.
+type Health struct {
+	Healthy   bool
+	LastError error
+	CheckedAt time.Time
+}
+
+type healthState struct {
+	mu sync.Mutex
+	h  Health
+}
+
+func (hs *healthState) set(err error) {
+	hs.mu.Lock()
+	hs.h = Health{Healthy: err == nil, LastError: err, CheckedAt: time.Now()}
+	hs.mu.Unlock()
+}
+
+func (hs *healthState) get() Health {
+	hs.mu.Lock()
+	defer hs.mu.Unlock()
+
+	return hs.h
+}
+
+func probe(ctx context.Context, w io.Writer) error {
+	if p, ok := w.(Prober); ok {
+		return p.Probe(ctx)
+	}
+
+	_, err := w.Write(nil)
+
+	return err
+}
+
+// probeLoop probes the writer and sinks every s.probeEvery until ctx is done,
+// so Health stays current while there is nothing to write.
+func (s *Service) probeLoop(ctx context.Context) {
+	t := s.clock.NewTicker(s.probeEvery)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C():
+			pctx, cancel := context.WithTimeout(ctx, s.probeEvery)
+			s.health.set(probe(pctx, s.currentWriter()))
+			cancel()
+		}
+	}
+}
+
+// Health reports the writer status from the last batch write or probe.
+func (s *Service) Health() Health {
+	return s.health.get()
+}
@@ -0,0 +1,103 @@
+package asynclog_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"test-task-log/asynclog"
+	"test-task-log/asynclog/testutil"
+)
+
+func TestHealthProbeWithSinks(t *testing.T) {
+	var heads, posts atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			heads.Add(1)
+		} else {
+			posts.Add(1)
+		}
+	}))
+	defer srv.Close()
+
+	s := asynclog.NewService(asynclog.NewHTTPSink(srv.URL, nil, nil), asynclog.WithHealthProbe(5*time.Millisecond))
+	if err := s.AddSink("dbg", io.Discard); err != nil {
+		t.Fatal(err)
+	}
+	go s.Run(context.Background())
+	defer s.Shutdown(context.Background())
+
+	ctx := testContext(t)
+	for heads.Load() < 3 {
+		select {
+		case <-ctx.Done():
+			t.Fatalf("got %d HEAD probes, want 3", heads.Load())
+		case <-time.After(time.Millisecond):
+		}
+	}
+
+	if n := posts.Load(); n != 0 {
+		t.Fatalf("probes sent %d POSTs, want none", n)
+	}
+	if h := s.Health(); !h.Healthy {
+		t.Fatalf("unhealthy: %v", h.LastError)
+	}
+}
+
+func TestHealthProbeFailure(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer srv.Close()
+
+	s := asynclog.NewService(asynclog.NewHTTPSink(srv.URL, nil, nil), asynclog.WithHealthProbe(5*time.Millisecond))
+	go s.Run(context.Background())
+	defer s.Shutdown(context.Background())
+
+	ctx := testContext(t)
+	for s.Health().Healthy || s.Health().CheckedAt.IsZero() {
+		select {
+		case <-ctx.Done():
+			t.Fatal("a 503 probe left the writer healthy")
+		case <-time.After(time.Millisecond):
+		}
+	}
+}
+
+// probeCounter counts its probes.
+type probeCounter struct {
+	io.Writer
+	probes atomic.Int32
+}
+
+func (p *probeCounter) Probe(context.Context) error {
+	p.probes.Add(1)
+	return nil
+}
+
+func TestHealthProbeClock(t *testing.T) {
+	w := &probeCounter{Writer: io.Discard}
+	clock := testutil.NewClock(time.Time{})
+	s := asynclog.NewService(w, asynclog.WithClock(clock), asynclog.WithHealthProbe(time.Hour))
+	go s.Run(context.Background())
+	defer s.Shutdown(context.Background())
+
+	// Probes tick on the service's clock, alongside Run's own ticker.
+	if err := clock.WaitTickers(testContext(t), 2); err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(time.Hour)
+
+	ctx := testContext(t)
+	for w.probes.Load() < 1 {
+		select {
+		case <-ctx.Done():
+			t.Fatal("no probe an interval in")
+		case <-time.After(time.Millisecond):
+		}
+	}
+}
@@ -0,0 +1,84 @@
+package asynclog
+
+import (
+	"context"
+	"os"
+	"slices"
+)
+
+// Hook adds fields to an entry before it is queued, from the context it was
+// enqueued with or from the process. Hooks run in the producer after the
+// filters, so dropped entries aren't enriched, and must be safe for
+// concurrent use.
+type Hook interface {
+	Enrich(ctx context.Context, e Entry) []Field
+}
+
+// HookFunc adapts a function to Hook.
+type HookFunc func(ctx context.Context, e Entry) []Field
+
+func (f HookFunc) Enrich(ctx context.Context, e Entry) []Field { return f(ctx, e) }
+
+// WithHooks adds hooks run on every record before it is queued, in order.
+// Fields a record already has, including those added by an earlier hook or
+// by WithCorrelation, are kept over the ones a hook returns.
+func WithHooks(hooks ...Hook) Option {
+	return func(s *Service) {
+		s.hooks = append(s.hooks, hooks...)
+	}
+}
+
+// StaticFields adds fields, such as the service version, to every entry.
+func StaticFields(fields ...Field) Hook {
+	fields = slices.Clone(fields)
+
+	return HookFunc(func(context.Context, Entry) []Field { return fields })
+}
+
+// Hostname adds the host name, as reported by the kernel when the hook is
+// made, in the host field.
+func Hostname() Hook {
+	name, err := os.Hostname()
+	if err != nil {
+		name = "unknown"
+	}
+
+	return StaticFields(F("host", name))
+}
+
+// PID adds the process ID in the pid field.
+func PID() Hook {
+	return StaticFields(F("pid", os.Getpid()))
+}
+
+// ContextValue adds the value ctx carries for key, if there is one, in the
+// field.
+func ContextValue(key any, field string) Hook {
+	return HookFunc(func(ctx context.Context, _ Entry) []Field {
+		if v := ctx.Value(key); v != nil {
+			return []Field{{Key: field, Value: v}}
+		}
+
+		return nil
+	})
+}
+
+// enrich adds the fields of the hooks to rec.
+func (s *Service) enrich(ctx context.Context, rec *record) {
+	if len(s.hooks) == 0 {
+		return
+	}
+
+	// The fields may be shared with the caller's entry, so they are only
+	// appended to once clipped.
+	rec.fields = slices.Clip(rec.fields)
+	e := rec.entry()
+	for _, h := range s.hooks {
+		for _, f := range h.Enrich(ctx, e) {
+			if !slices.ContainsFunc(rec.fields, func(have Field) bool { return have.Key == f.Key }) {
+				rec.fields = append(rec.fields, f)
+			}
+		}
+		e.Fields = rec.fields
+	}
+}
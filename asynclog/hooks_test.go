@@ -0,0 +1,72 @@
+package asynclog_test
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"sync/atomic"
+	"testing"
+
+	"test-task-log/asynclog"
+)
+
+type userKey struct{}
+
+func TestHooks(t *testing.T) {
+	var calls atomic.Int32
+	enc := &captureEncoder{}
+	s, _, _ := start(t, asynclog.WithEncoder(enc),
+		asynclog.WithFilters(asynclog.MinLevel(asynclog.LevelInfo)),
+		asynclog.WithHooks(
+			asynclog.StaticFields(asynclog.F("version", "1.2"), asynclog.F("env", "prod")),
+			asynclog.PID(),
+			asynclog.ContextValue(userKey{}, "user"),
+			asynclog.HookFunc(func(_ context.Context, e asynclog.Entry) []asynclog.Field {
+				calls.Add(1)
+				// Later hooks see the fields added so far.
+				return []asynclog.Field{asynclog.F("fields", len(e.Fields))}
+			}),
+		))
+
+	ctx := context.WithValue(context.Background(), userKey{}, "bob")
+	fields := make([]asynclog.Field, 1, 8)
+	fields[0] = asynclog.F("version", "own")
+	for _, err := range []error{
+		s.Info(ctx, "a", fields...),
+		s.Debug(ctx, "filtered"),
+		s.Info(context.Background(), "b"),
+	} {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Flush(testContext(t)); err != nil {
+		t.Fatal(err)
+	}
+
+	if n := calls.Load(); n != 2 {
+		t.Fatalf("hook called %d times, want only for the 2 kept entries", n)
+	}
+	if full := fields[:cap(fields)]; full[1] != (asynclog.Field{}) {
+		t.Fatalf("caller's fields appended to: %v", full[:2])
+	}
+
+	pid := fmt.Sprint(os.Getpid())
+	want := [][]string{
+		{"version=own", "env=prod", "pid=" + pid, "user=bob", "fields=4"},
+		{"version=1.2", "env=prod", "pid=" + pid, "fields=3"},
+	}
+	if len(enc.entries) != len(want) {
+		t.Fatalf("got %d entries, want %d", len(enc.entries), len(want))
+	}
+	for i, e := range enc.entries {
+		var got []string
+		for _, f := range e.Fields {
+			got = append(got, fmt.Sprintf("%s=%v", f.Key, f.Value))
+		}
+		if !slices.Equal(got, want[i]) {
+			t.Fatalf("entry %s: got fields %q, want %q", e.Message, got, want[i])
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package asynclog
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// ErrQueueFull is returned when records are offered to a full queue.
+var ErrQueueFull = errors.New("asynclog: queue full")
+
+// errTooManyRecords is returned when more records are offered at once than
+// the queue can ever hold.
+var errTooManyRecords = errors.New("asynclog: more records than the queue holds")
+
+// maxIngestBody caps the body of an ingestion request.
+const maxIngestBody = 4 << 20
+
+// HTTPHandler returns a handler ingesting the newline-delimited records
+// POSTed to it, at the level and source given by the level and source query
+// parameters (LevelInfo and none by default). A request is queued as a whole
+// or not at all: one with more records than the queue holds gets 413 Request
+// Entity Too Large. While the queue has no room for it the handler answers
+// 429 Too Many Requests, and 503 Service Unavailable while the service is
+// draining or closed, both with a Retry-After estimate of how long the
+// backlog takes to write, so clients back off instead of having their
+// records dropped. Queued requests get 204 No Content. Under WithCorrelation
+// the records carry the request's correlation ID.
+func (s *Service) HTTPHandler() http.Handler {
+	return http.HandlerFunc(s.serveIngest)
+}
+
+func (s *Service) serveIngest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	tmpl := record{level: LevelInfo, source: r.URL.Query().Get("source")}
+	if l := r.URL.Query().Get("level"); l != "" {
+		level, err := ParseLevel(l)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		tmpl.level = level
+	}
+
+	var recs []record
+	sc := bufio.NewScanner(http.MaxBytesReader(w, r.Body, maxIngestBody))
+	sc.Buffer(nil, maxIngestBody)
+	for sc.Scan() {
+		if line := strings.TrimRight(sc.Text(), "\r"); line != "" {
+			rec := tmpl
+			rec.msg = line
+			recs = append(recs, rec)
+		}
+	}
+	if err := sc.Err(); err != nil {
+		status := http.StatusBadRequest
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) || errors.Is(err, bufio.ErrTooLong) {
+			status = http.StatusRequestEntityTooLarge
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+
+	ctx := s.extractCorrelation(r.Context(), r.Header, w.Header())
+	switch err := s.offer(ctx, recs); err {
+	case nil:
+		w.WriteHeader(http.StatusNoContent)
+	case errTooManyRecords:
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+	case ErrQueueFull:
+		w.Header().Set("Retry-After", strconv.Itoa(s.retryAfter()))
+		http.Error(w, err.Error(), http.StatusTooManyRequests)
+	case ErrClosed:
+		w.Header().Set("Retry-After", strconv.Itoa(s.retryAfter()))
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+	default:
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+	}
+}
+
+// offer is enqueueAll failing with ErrQueueFull instead of waiting for room
+// or dropping records, queueing all of recs or none. Rate limits are still
+// waited for. Room is checked before the records are filtered, so a rejected
+// request counts against no sampler, quota or rate limit; only one losing the
+// room to other producers in the meantime still counts against the first
+// two, its rate limit tokens being handed back.
+func (s *Service) offer(ctx context.Context, recs []record) error {
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if s.drained.Load() {
+		return ErrClosed
+	}
+	if err := s.queue.fits(recs); err != nil {
+		return err
+	}
+
+	accepted := recs[:0]
+	tokens := make(map[*tokenBucket]int)
+	for _, rec := range recs {
+		if !s.accept(&rec) {
+			continue
+		}
+
+		if l := s.limiterFor(rec.level); l != nil {
+			tokens[l]++
+		}
+		accepted = append(accepted, rec)
+	}
+
+	if !waitAll(ctx, s.clock, tokens) {
+		return ctx.Err()
+	}
+
+	for i := range accepted {
+		s.stamp(&accepted[i])
+		s.correlate(ctx, &accepted[i])
+		s.enrich(ctx, &accepted[i])
+	}
+
+	if err := s.queue.offer(accepted); err != nil {
+		refundAll(tokens)
+		return err
+	}
+	s.event(EventEnqueue, "", accepted)
+
+	now := s.clock.Now()
+	bytes := 0
+	for _, rec := range accepted {
+		bytes += len(rec.msg)
+		s.sizes.add(now, len(rec.msg))
+	}
+	s.ingestRate.add(now, len(accepted), bytes)
+
+	return nil
+}
+
+// retryAfter estimates in seconds how long writing the records pending now
+// takes at the recent delivery rate, or one flush interval without one.
+func (s *Service) retryAfter() int {
+	s.writerMx.RLock()
+	d := s.writeEvery.Seconds()
+	s.writerMx.RUnlock()
+
+	pending := float64(s.queue.size() + int(s.inflight.Load()))
+	if rate, _ := s.deliverRate.rate(s.clock.Now()); rate > 0 {
+		d = pending / rate
+	}
+
+	return int(min(max(math.Ceil(d), 1), 60))
+}
@@ -0,0 +1,109 @@
+package asynclog_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"test-task-log/asynclog"
+	"test-task-log/asynclog/testutil"
+)
+
+// post sends body to the ingestion handler of s and returns the response.
+func post(s *asynclog.Service, method, target, body string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	s.HTTPHandler().ServeHTTP(rec, httptest.NewRequest(method, target, strings.NewReader(body)))
+
+	return rec
+}
+
+func TestHTTPIngest(t *testing.T) {
+	sink := testutil.NewSink()
+	s := asynclog.NewService(sink, asynclog.WithChannelBuffer(2))
+
+	if rec := post(s, http.MethodPost, "/?level=warn&source=web", "a\r\n\nb\n"); rec.Code != http.StatusNoContent {
+		t.Fatalf("got status %d, want 204", rec.Code)
+	}
+
+	// c doesn't fit, and isn't queued in part either.
+	rec := post(s, http.MethodPost, "/", "c\nd\n")
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("got status %d into a full queue, want 429", rec.Code)
+	}
+	if got := rec.Header().Get("Retry-After"); got != "5" {
+		t.Fatalf("got Retry-After %q, want one flush interval", got)
+	}
+
+	if got, want := drain(t, s, sink), []string{"a", "b"}; !slices.Equal(got, want) {
+		t.Fatalf("got lines %q, want %q", got, want)
+	}
+
+	rec = post(s, http.MethodPost, "/", "e\n")
+	if rec.Code != http.StatusServiceUnavailable || rec.Header().Get("Retry-After") == "" {
+		t.Fatalf("got status %d, Retry-After %q after Shutdown, want 503 with a Retry-After", rec.Code, rec.Header().Get("Retry-After"))
+	}
+}
+
+func TestHTTPIngestBadRequests(t *testing.T) {
+	s, _, _ := start(t)
+
+	for _, tc := range []struct {
+		method, target string
+		want           int
+	}{
+		{http.MethodGet, "/", http.StatusMethodNotAllowed},
+		{http.MethodPost, "/?level=loud", http.StatusBadRequest},
+	} {
+		if rec := post(s, tc.method, tc.target, "a\n"); rec.Code != tc.want {
+			t.Errorf("%s %s: got status %d, want %d", tc.method, tc.target, rec.Code, tc.want)
+		}
+	}
+
+	for _, body := range []string{strings.Repeat("x", 5<<20), strings.Repeat(strings.Repeat("x", 1023)+"\n", 5<<10)} {
+		if rec := post(s, http.MethodPost, "/", body); rec.Code != http.StatusRequestEntityTooLarge {
+			t.Fatalf("got status %d for an oversized body, want 413", rec.Code)
+		}
+	}
+}
+
+func TestHTTPIngestTooManyRecords(t *testing.T) {
+	s, _, _ := start(t, asynclog.WithChannelBuffer(2), asynclog.WithBurstCapacity(3))
+
+	if rec := post(s, http.MethodPost, "/", "a\nb\nc\nd\n"); rec.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("got status %d for more records than the queue holds, want 413", rec.Code)
+	}
+}
+
+func TestHTTPIngestRejectedKeepsQuota(t *testing.T) {
+	sink := testutil.NewSink()
+	s := asynclog.NewService(sink, asynclog.WithChannelBuffer(1), asynclog.WithBatchSize(1), asynclog.WithSourceQuota(2, 0, time.Hour))
+
+	if rec := post(s, http.MethodPost, "/", "a\n"); rec.Code != http.StatusNoContent {
+		t.Fatalf("got status %d, want 204", rec.Code)
+	}
+	for range 3 {
+		if rec := post(s, http.MethodPost, "/", "b\n"); rec.Code != http.StatusTooManyRequests {
+			t.Fatalf("got status %d into a full queue, want 429", rec.Code)
+		}
+	}
+
+	// The rejected requests took nothing from the quota, so b still fits in
+	// it once the queue has room.
+	go s.Run(context.Background())
+	if err := sink.WaitLines(testContext(t), 1); err != nil {
+		t.Fatal(err)
+	}
+	if rec := post(s, http.MethodPost, "/", "b\n"); rec.Code != http.StatusNoContent {
+		t.Fatalf("got status %d, want 204", rec.Code)
+	}
+	if err := s.Shutdown(testContext(t)); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := sink.Lines(), []string{"a", "b"}; !slices.Equal(got, want) {
+		t.Fatalf("got lines %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,129 @@
+package asynclog
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// httpSinkTimeout bounds a POST when the flush context has no deadline.
+const httpSinkTimeout = 30 * time.Second
+
+// HTTPSink POSTs every batch to a URL, for bulk endpoints such as Loki's push
+// API or an Elasticsearch ingest pipeline fronted by a proxy. Header is sent
+// with every request, e.g. for Authorization; Content-Type defaults to
+// text/plain. A response other than 2xx fails the batch.
+//
+// OpenSink builds one from http and https URIs, POSTing to the URI itself
+// with the user info, if any, as basic auth.
+type HTTPSink struct {
+	url    string
+	header http.Header
+	client *http.Client
+}
+
+// NewHTTPSink returns a sink POSTing to url with header, using
+// http.DefaultClient if client is nil.
+func NewHTTPSink(url string, header http.Header, client *http.Client) *HTTPSink {
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	return &HTTPSink{url: url, header: header.Clone(), client: client}
+}
+
+func (hs *HTTPSink) Write(p []byte) (int, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), httpSinkTimeout)
+	defer cancel()
+
+	return hs.WriteContext(ctx, p)
+}
+
+// WriteContext POSTs p within ctx.
+func (hs *HTTPSink) WriteContext(ctx context.Context, p []byte) (int, error) {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, httpSinkTimeout)
+		defer cancel()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hs.url, bytes.NewReader(p))
+	if err != nil {
+		return 0, err
+	}
+	for k, vs := range hs.header {
+		req.Header[k] = vs
+	}
+	if req.Header.Get("Content-Type") == "" {
+		req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	}
+
+	resp, err := hs.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 != 2 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return 0, fmt.Errorf("asynclog: %s: %s: %s", hs.url, resp.Status, bytes.TrimSpace(body))
+	}
+	io.Copy(io.Discard, resp.Body)
+
+	return len(p), nil
+}
+
+// Probe sends a HEAD request to the URL within ctx, so health and circuit
+// breaker probes don't POST empty batches. Bulk endpoints often answer HEAD
+// with 404 or 405, so any response counts as healthy except a 5xx, 401 or
+// 403, which would fail the next batch too.
+func (hs *HTTPSink) Probe(ctx context.Context) error {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, httpSinkTimeout)
+		defer cancel()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, hs.url, nil)
+	if err != nil {
+		return err
+	}
+	for k, vs := range hs.header {
+		req.Header[k] = vs
+	}
+
+	resp, err := hs.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode/100 == 5 || resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden {
+		return fmt.Errorf("asynclog: %s: %s", hs.url, resp.Status)
+	}
+
+	return nil
+}
+
+func openHTTPSink(u *url.URL) (io.Writer, error) {
+	if u.Host == "" {
+		return nil, fmt.Errorf("missing host")
+	}
+
+	header := make(http.Header)
+	if u.User != nil {
+		req := http.Request{Header: header}
+		pass, _ := u.User.Password()
+		req.SetBasicAuth(u.User.Username(), pass)
+
+		stripped := *u
+		stripped.User = nil
+		u = &stripped
+	}
+
+	return NewHTTPSink(u.String(), header, nil), nil
+}
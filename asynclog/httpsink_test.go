@@ -0,0 +1,58 @@
+package asynclog_test
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"test-task-log/asynclog"
+)
+
+func TestHTTPSink(t *testing.T) {
+	var got *http.Request
+	var body string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		got, body = r, string(b)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	w, err := asynclog.OpenSink(strings.Replace(srv.URL, "http://", "http://user:secret@", 1) + "/push")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n, err := w.Write([]byte("a\nb\n")); n != 4 || err != nil {
+		t.Fatalf("got %d, %v, want the whole batch written", n, err)
+	}
+
+	if got.Method != http.MethodPost || got.URL.Path != "/push" || body != "a\nb\n" {
+		t.Fatalf("got %s %s with %q, want the batch POSTed to /push", got.Method, got.URL.Path, body)
+	}
+	if user, pass, ok := got.BasicAuth(); !ok || user != "user" || pass != "secret" {
+		t.Fatalf("got basic auth %q, %q, want the URI's user info", user, pass)
+	}
+	if ct := got.Header.Get("Content-Type"); !strings.HasPrefix(ct, "text/plain") {
+		t.Fatalf("got Content-Type %q, want text/plain", ct)
+	}
+}
+
+func TestHTTPSinkErrorStatus(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "index closed", http.StatusInternalServerError)
+	}))
+	defer srv.Close()
+
+	w := asynclog.NewHTTPSink(srv.URL, http.Header{"Content-Type": {"application/x-ndjson"}}, nil)
+	n, err := w.Write([]byte("a\n"))
+	if n != 0 || err == nil || !strings.Contains(err.Error(), "500") || !strings.Contains(err.Error(), "index closed") {
+		t.Fatalf("got %d, %v, want the status and body of the failed POST", n, err)
+	}
+
+	srv.Close()
+	if _, err := w.Write([]byte("a\n")); err == nil {
+		t.Fatal("write to a closed server succeeded")
+	}
+}
@@ -0,0 +1,63 @@
+package asynclog_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"test-task-log/asynclog"
+)
+
+// stallWriter blocks writes until release is closed, tracking how many run
+// at once.
+type stallWriter struct {
+	release chan struct{}
+
+	mu      sync.Mutex
+	active  int
+	peak    int
+	written int
+}
+
+func (w *stallWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	w.active++
+	w.peak = max(w.peak, w.active)
+	w.mu.Unlock()
+
+	<-w.release
+
+	w.mu.Lock()
+	w.active--
+	w.written++
+	w.mu.Unlock()
+	return len(p), nil
+}
+
+func TestMaxInflightBatches(t *testing.T) {
+	w := &stallWriter{release: make(chan struct{})}
+	s := asynclog.NewService(w, asynclog.WithBatchSize(1), asynclog.WithChannelBuffer(1), asynclog.WithWorkers(0), asynclog.WithMaxInflightBatches(2))
+	go s.Run(context.Background())
+
+	// Two batches are written and a third waits in Run for a slot, so once
+	// a fourth is queued the fifth finds no room.
+	for _, msg := range []string{"a", "b", "c", "d"} {
+		if err := s.Print(testContext(t), msg); err != nil {
+			t.Fatalf("Print(%q): %v", msg, err)
+		}
+	}
+	if err := printShort(s, "", asynclog.LevelInfo, "e"); !errors.Is(err, asynclog.ErrTimeout) {
+		t.Fatalf("got %v with the writer stalled, want ErrTimeout", err)
+	}
+
+	close(w.release)
+	if err := s.Shutdown(testContext(t)); err != nil {
+		t.Fatal(err)
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.peak != 2 || w.written != 4 {
+		t.Fatalf("got %d writes at once and %d written, want 2 and 4", w.peak, w.written)
+	}
+}
@@ -0,0 +1,57 @@
+package asynclog
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"io"
+	"strings"
+)
+
+// ingestBatch caps how many lines Ingest queues with a single operation.
+const ingestBatch = 256
+
+// Ingest reads newline-delimited records from r (a pipe, a connection, a
+// command's stdout) and enqueues them at LevelInfo until r is exhausted, ctx
+// is done or the service closes, returning ErrClosed. Lines already read are
+// queued together. Reading stops while the queue is full, so a slow writer
+// slows the reader down instead of piling up records. Empty lines are
+// skipped.
+func (s *Service) Ingest(ctx context.Context, r io.Reader) error {
+	return s.ingest(ctx, r, record{level: LevelInfo})
+}
+
+// ingest is Ingest giving every record the level, source and fields of tmpl.
+func (s *Service) ingest(ctx context.Context, r io.Reader, tmpl record) error {
+	br := bufio.NewReader(r)
+
+	var pending []record
+	for {
+		line, err := br.ReadString('\n')
+		if line = strings.TrimRight(line, "\r\n"); line != "" {
+			rec := tmpl
+			rec.msg = line
+			pending = append(pending, rec)
+		}
+
+		// Queue what has been read once nothing more is readable without
+		// blocking, so lines don't wait on a quiet reader.
+		if len(pending) > 0 && (err != nil || br.Buffered() == 0 || len(pending) >= ingestBatch) {
+			if err := s.enqueueAll(ctx, pending); errors.Is(err, ErrClosed) {
+				return err
+			}
+			pending = nil
+		}
+
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package asynclog_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"slices"
+	"strings"
+	"testing"
+	"testing/iotest"
+
+	"test-task-log/asynclog"
+)
+
+func TestIngest(t *testing.T) {
+	s, sink, _ := start(t)
+
+	if err := s.Ingest(context.Background(), strings.NewReader("a\n\nb\r\nc")); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Shutdown(testContext(t)); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := sink.Lines(), []string{"a", "b", "c"}; !slices.Equal(got, want) {
+		t.Fatalf("got lines %q, want %q", got, want)
+	}
+
+	if err := s.Ingest(context.Background(), strings.NewReader("late\n")); !errors.Is(err, asynclog.ErrClosed) {
+		t.Fatalf("Ingest after Shutdown: got %v, want ErrClosed", err)
+	}
+}
+
+func TestIngestReadError(t *testing.T) {
+	s, sink, _ := start(t)
+
+	broken := errors.New("connection reset")
+	r := io.MultiReader(strings.NewReader("a\n"), iotest.ErrReader(broken))
+	if err := s.Ingest(context.Background(), r); !errors.Is(err, broken) {
+		t.Fatalf("got %v, want the read error", err)
+	}
+	if err := s.Shutdown(testContext(t)); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := sink.Lines(), []string{"a"}; !slices.Equal(got, want) {
+		t.Fatalf("got lines %q, want the line read before the error", got)
+	}
+}
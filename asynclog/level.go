@@ -0,0 +1,43 @@
+package asynclog
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Level is the severity of a log record.
+type Level int8
+
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+func (l Level) String() string {
+	switch l {
+	case LevelDebug:
+		return "DEBUG"
+	case LevelInfo:
+		return "INFO"
+	case LevelWarn:
+		return "WARN"
+	case LevelError:
+		return "ERROR"
+	default:
+		return fmt.Sprintf("LEVEL(%d)", int8(l))
+	}
+}
+
+// ParseLevel returns the level named by s, as written by Level.String. Case is
+// ignored.
+func ParseLevel(s string) (Level, error) {
+	for l := LevelDebug; l <= LevelError; l++ {
+		if strings.EqualFold(s, l.String()) {
+			return l, nil
+		}
+	}
+
+	return 0, fmt.Errorf("asynclog: unknown level %q", s)
+}
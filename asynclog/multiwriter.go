@@ -0,0 +1,130 @@
+package asynclog
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// MultiWriter duplicates writes to several writers. Unlike io.MultiWriter it
+// keeps writing to the remaining writers when one fails and reports the
+// failures per writer in a MultiError.
+type MultiWriter struct {
+	writers []io.Writer
+}
+
+func NewMultiWriter(writers ...io.Writer) *MultiWriter {
+	return &MultiWriter{writers: writers}
+}
+
+// Write writes p to every writer. It returns len(p) if at least one writer
+// took all of p, and a MultiError listing the writers that didn't.
+func (m *MultiWriter) Write(p []byte) (int, error) {
+	return m.WriteContext(context.Background(), p)
+}
+
+// WriteContext is Write passing ctx on to writers implementing ContextWriter.
+func (m *MultiWriter) WriteContext(ctx context.Context, p []byte) (int, error) {
+	var errs MultiError
+	for i, w := range m.writers {
+		n, err := writeContext(ctx, w, p)
+		if err == nil && n < len(p) {
+			err = io.ErrShortWrite
+		}
+
+		if err != nil {
+			errs = append(errs, &WriteError{Index: i, Writer: w, Err: err})
+		}
+	}
+
+	if len(errs) == 0 {
+		return len(p), nil
+	}
+
+	if len(errs) == len(m.writers) {
+		return 0, errs
+	}
+
+	return len(p), errs
+}
+
+// Flush flushes every writer implementing Flush() error.
+func (m *MultiWriter) Flush() error {
+	return m.each(flushWriter)
+}
+
+// Sync syncs every writer implementing Sync() error.
+func (m *MultiWriter) Sync() error {
+	return m.each(syncWriter)
+}
+
+// Probe probes every writer, through Probe if it implements Prober and with a
+// zero-byte write otherwise.
+func (m *MultiWriter) Probe(ctx context.Context) error {
+	return m.each(func(w io.Writer) error { return probe(ctx, w) })
+}
+
+func (m *MultiWriter) each(fn func(io.Writer) error) error {
+	var errs MultiError
+	for i, w := range m.writers {
+		if err := fn(w); err != nil {
+			errs = append(errs, &WriteError{Index: i, Writer: w, Err: err})
+		}
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+
+	return errs
+}
+
+// SetWriteDeadline sets the deadline on every writer implementing
+// SetWriteDeadline, such as net.Conn.
+func (m *MultiWriter) SetWriteDeadline(t time.Time) error {
+	return m.each(func(w io.Writer) error {
+		if d, ok := w.(writeDeadliner); ok {
+			return d.SetWriteDeadline(t)
+		}
+
+		return nil
+	})
+}
+
+// WriteError is the failure of one writer of a MultiWriter.
+type WriteError struct {
+	Index  int
+	Writer io.Writer
+	Err    error
+}
+
+func (e *WriteError) Error() string {
+	return fmt.Sprintf("writer %d: %v", e.Index, e.Err)
+}
+
+func (e *WriteError) Unwrap() error {
+	return e.Err
+}
+
+// MultiError holds the failures of a MultiWriter write.
+type MultiError []*WriteError
+
+func (e MultiError) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+
+	return strings.Join(msgs, "; ")
+}
+
+func (e MultiError) Unwrap() []error {
+	errs := make([]error, len(e))
+	for i, err := range e {
+		errs[i] = err
+	}
+
+	return errs
+}
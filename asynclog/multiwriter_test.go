@@ -0,0 +1,43 @@
+package asynclog_test
+
+import (
+	"errors"
+	"io"
+	"testing"
+
+	"test-task-log/asynclog"
+	"test-task-log/asynclog/testutil"
+)
+
+// shortWriter takes one byte less than it is given.
+type shortWriter struct{}
+
+func (shortWriter) Write(p []byte) (int, error) { return max(len(p)-1, 0), nil }
+
+func TestMultiWriterIsolation(t *testing.T) {
+	down := errors.New("down")
+	a, failing, b := testutil.NewSink(), testutil.NewSink(), testutil.NewSink()
+	failing.Fail(down)
+	m := asynclog.NewMultiWriter(a, failing, shortWriter{}, b)
+
+	n, err := m.Write([]byte("x\n"))
+	if n != 2 {
+		t.Fatalf("got %d bytes written, want 2 as two writers took it all", n)
+	}
+	var merr asynclog.MultiError
+	if !errors.As(err, &merr) || len(merr) != 2 || merr[0].Index != 1 || merr[1].Index != 2 {
+		t.Fatalf("got %v, want failures of writers 1 and 2", err)
+	}
+	if !errors.Is(err, down) || !errors.Is(err, io.ErrShortWrite) {
+		t.Fatalf("got %v, want it to wrap the writer errors", err)
+	}
+	if len(a.Writes()) != 1 || len(b.Writes()) != 1 {
+		t.Fatal("a failing writer kept the others from being written to")
+	}
+
+	a.Fail(down)
+	b.Fail(down)
+	if n, err := asynclog.NewMultiWriter(a, b).Write([]byte("y\n")); n != 0 || err == nil {
+		t.Fatalf("got %d, %v with every writer failing, want 0 and an error", n, err)
+	}
+}
@@ -0,0 +1,114 @@
+package asynclog
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+// Logger is a child of a Service for one subsystem: it tags its records with
+// its name as the source and drops those below its own minimum level, while
+// sharing the service's queue, batches and writers. Loggers are cheap and
+// safe for concurrent use.
+type Logger struct {
+	s      *Service
+	name   string
+	parent *Logger
+	level  atomic.Int32 // -1 inherits the parent's, or allows every level
+}
+
+// Named returns a logger for the subsystem name, allowing every level until
+// SetLevel is called.
+func (s *Service) Named(name string) *Logger {
+	l := &Logger{s: s, name: name}
+	l.level.Store(-1)
+
+	return l
+}
+
+// Named returns a child logger named after l's name and name joined with a
+// dot, such as "db.pool", inheriting l's level until SetLevel is called on
+// it.
+func (l *Logger) Named(name string) *Logger {
+	child := l.s.Named(l.name + "." + name)
+	child.parent = l
+
+	return child
+}
+
+// Name returns the source the logger tags its records with.
+func (l *Logger) Name() string {
+	return l.name
+}
+
+// SetLevel drops records below level from now on.
+func (l *Logger) SetLevel(level Level) {
+	l.level.Store(int32(level))
+}
+
+// Level returns the logger's minimum level: its own, or the nearest
+// ancestor's, or LevelDebug if none was set.
+func (l *Logger) Level() Level {
+	for ; l != nil; l = l.parent {
+		if level := l.level.Load(); level >= 0 {
+			return Level(level)
+		}
+	}
+
+	return LevelDebug
+}
+
+// Enabled reports whether records at level get through the logger and the
+// service's own level.
+func (l *Logger) Enabled(level Level) bool {
+	return level >= l.Level() && level >= l.s.Level()
+}
+
+// Print enqueues log at LevelInfo, returning the error of Service.Print.
+// Records below the logger's level are filtered out and return nil.
+func (l *Logger) Print(ctx context.Context, log string) error {
+	if !l.Enabled(LevelInfo) {
+		return nil
+	}
+
+	return l.s.enqueue(ctx, record{level: LevelInfo, source: l.name, msg: log})
+}
+
+// PrintLevel is Print at level.
+func (l *Logger) PrintLevel(ctx context.Context, level Level, log string) error {
+	if !l.Enabled(level) {
+		return nil
+	}
+
+	return l.s.PrintFrom(ctx, l.name, level, log)
+}
+
+// Log enqueues e with the logger's name as its source, returning what Print
+// returns.
+func (l *Logger) Log(ctx context.Context, e Entry) error {
+	if !l.Enabled(e.Level) {
+		return nil
+	}
+	e.Source = l.name
+
+	return l.s.Log(ctx, e)
+}
+
+// Debug enqueues msg with fields at LevelDebug.
+func (l *Logger) Debug(ctx context.Context, msg string, fields ...Field) error {
+	return l.Log(ctx, Entry{Level: LevelDebug, Message: msg, Fields: fields})
+}
+
+// Info enqueues msg with fields at LevelInfo.
+func (l *Logger) Info(ctx context.Context, msg string, fields ...Field) error {
+	return l.Log(ctx, Entry{Level: LevelInfo, Message: msg, Fields: fields})
+}
+
+// Warn enqueues msg with fields at LevelWarn.
+func (l *Logger) Warn(ctx context.Context, msg string, fields ...Field) error {
+	return l.Log(ctx, Entry{Level: LevelWarn, Message: msg, Fields: fields})
+}
+
+// Error enqueues msg with fields at LevelError.
+func (l *Logger) Error(ctx context.Context, msg string, fields ...Field) error {
+	return l.Log(ctx, Entry{Level: LevelError, Message: msg, Fields: fields})
+}
@@ -0,0 +1,74 @@
+package asynclog_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+
+	"test-task-log/asynclog"
+)
+
+func TestNamed(t *testing.T) {
+	enc := &captureEncoder{}
+	s, _, _ := start(t, asynclog.WithEncoder(enc))
+	ctx := context.Background()
+
+	db := s.Named("db")
+	pool := db.Named("pool")
+	if pool.Name() != "db.pool" {
+		t.Fatalf("got name %q, want db.pool", pool.Name())
+	}
+
+	db.SetLevel(asynclog.LevelWarn)
+	for _, err := range []error{
+		db.Info(ctx, "db info"),
+		pool.Print(ctx, "pool inherited info"),
+		pool.Warn(ctx, "pool warn"),
+	} {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	pool.SetLevel(asynclog.LevelDebug)
+	s.SetLevel(asynclog.LevelInfo)
+	for _, err := range []error{
+		pool.Debug(ctx, "pool debug below the service"),
+		pool.PrintLevel(ctx, asynclog.LevelInfo, "pool info"),
+		db.Error(ctx, "db error"),
+	} {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Flush(testContext(t)); err != nil {
+		t.Fatal(err)
+	}
+
+	var got []string
+	for _, e := range enc.entries {
+		got = append(got, fmt.Sprintf("%s %s %s", e.Source, e.Level, e.Message))
+	}
+	want := []string{"db.pool WARN pool warn", "db.pool INFO pool info", "db ERROR db error"}
+	if !slices.Equal(got, want) {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
+
+func TestNamedClosed(t *testing.T) {
+	s, _, _ := start(t)
+	l := s.Named("api")
+	if err := s.Shutdown(testContext(t)); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := l.Info(context.Background(), "late"); !errors.Is(err, asynclog.ErrClosed) {
+		t.Fatalf("got %v, want ErrClosed", err)
+	}
+	l.SetLevel(asynclog.LevelError)
+	if err := l.Info(context.Background(), "filtered"); err != nil {
+		t.Fatalf("got %v for a filtered record, want nil", err)
+	}
+}
@@ -0,0 +1,59 @@
+package asynclog
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// WithFailureNotice prints a notice to stderr once after consecutive batch
+// writes have failed in a row, and at most once per every while they keep
+// failing, so a pipeline that is completely broken is never silent. Another
+// notice follows once writes succeed again.
+func WithFailureNotice(after int, every time.Duration) Option {
+	return func(s *Service) {
+		s.notice = &failureNotice{w: os.Stderr, after: max(after, 1), every: every}
+	}
+}
+
+// failureNotice counts consecutive failed batches for WithFailureNotice.
+type failureNotice struct {
+	w     io.Writer
+	after int
+	every time.Duration
+
+	mu       sync.Mutex
+	failed   int
+	noticed  bool
+	lastSent time.Time
+}
+
+// observe counts the outcome of one batch write, finished at now.
+func (fn *failureNotice) observe(err error, now time.Time) {
+	if fn == nil {
+		return
+	}
+
+	fn.mu.Lock()
+	defer fn.mu.Unlock()
+
+	if err == nil {
+		if fn.noticed {
+			fmt.Fprintf(fn.w, "asynclog: writes recovered after %d failed batches\n", fn.failed)
+		}
+		fn.failed = 0
+		fn.noticed = false
+		return
+	}
+
+	fn.failed++
+	if fn.failed < fn.after || fn.noticed && now.Sub(fn.lastSent) < fn.every {
+		return
+	}
+
+	fmt.Fprintf(fn.w, "asynclog: %d batch writes failed in a row, last error: %v\n", fn.failed, err)
+	fn.noticed = true
+	fn.lastSent = now
+}
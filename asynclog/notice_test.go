@@ -0,0 +1,70 @@
+package asynclog_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"test-task-log/asynclog"
+)
+
+// captureStderr points os.Stderr at a file for the rest of the test and
+// returns a function reading what was written to it.
+func captureStderr(t *testing.T) func() string {
+	t.Helper()
+
+	f, err := os.Create(filepath.Join(t.TempDir(), "stderr"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	stderr := os.Stderr
+	os.Stderr = f
+	t.Cleanup(func() {
+		os.Stderr = stderr
+		f.Close()
+	})
+
+	return func() string {
+		b, err := os.ReadFile(f.Name())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(b)
+	}
+}
+
+func TestFailureNotice(t *testing.T) {
+	stderr := captureStderr(t)
+	s, sink, clock := start(t, asynclog.WithBatchSize(1), asynclog.WithFailureNotice(2, time.Minute))
+
+	sink.Fail(errors.New("down"))
+	for _, msg := range []string{"a", "b", "c"} {
+		if err := s.PrintSync(testContext(t), msg); err == nil {
+			t.Fatal("PrintSync succeeded on a failing writer")
+		}
+	}
+	// The notice comes on the second failure and isn't repeated within the
+	// minute.
+	if got := stderr(); strings.Count(got, "failed in a row") != 1 || !strings.Contains(got, "2 batch writes failed in a row, last error: down") {
+		t.Fatalf("got stderr %q, want one notice after 2 failures", got)
+	}
+
+	clock.Advance(time.Minute)
+	if err := s.PrintSync(testContext(t), "d"); err == nil {
+		t.Fatal("PrintSync succeeded on a failing writer")
+	}
+	if got := stderr(); !strings.Contains(got, "4 batch writes failed in a row") {
+		t.Fatalf("got stderr %q, want a second notice once the minute passed", got)
+	}
+
+	sink.Fail(nil)
+	if err := s.PrintSync(testContext(t), "e"); err != nil {
+		t.Fatal(err)
+	}
+	if got := stderr(); !strings.Contains(got, "writes recovered after 4 failed batches") {
+		t.Fatalf("got stderr %q, want a recovery notice", got)
+	}
+}
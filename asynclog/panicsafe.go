@@ -0,0 +1,156 @@
+package asynclog
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"runtime/debug"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	// ErrWriterPanic is returned for writes whose writer panicked.
+	ErrWriterPanic = errors.New("asynclog: writer panicked")
+	// ErrSinkDisabled is returned for writes to a writer disabled by
+	// PanicDisable.
+	ErrSinkDisabled = errors.New("asynclog: sink disabled")
+)
+
+// PanicPolicy is what the service does with a writer that panicked.
+type PanicPolicy int
+
+const (
+	// PanicDrop fails the batch at once, without WithRetry attempts.
+	PanicDrop PanicPolicy = iota
+	// PanicRetry fails the write like any other error, so it is retried as
+	// WithRetry says.
+	PanicRetry
+	// PanicDisable fails the batch and every later write to the writer with
+	// ErrSinkDisabled, until it is replaced by SetWriter, Reload or
+	// adding the sink again.
+	PanicDisable
+)
+
+// WriterPanic is a panic recovered from a writer.
+type WriterPanic struct {
+	Writer io.Writer
+	Value  any
+	Stack  []byte
+	// Disabled is set if the writer was disabled by PanicDisable.
+	Disabled bool
+}
+
+// WithPanicPolicy sets what is done with writers that panic, PanicDrop by
+// default, and calls fn, if not nil, with every panic recovered. Panics in
+// Write, Flush, Sync and Probe are always recovered and turned into errors
+// wrapping ErrWriterPanic, so a misbehaving writer fails its own batches
+// rather than the write goroutine, and are counted by WriterPanics. fn is
+// called from the write goroutine and must not block.
+func WithPanicPolicy(p PanicPolicy, fn func(WriterPanic)) Option {
+	return func(s *Service) {
+		s.panicPolicy = p
+		s.onPanic = fn
+	}
+}
+
+// WriterPanics returns the number of panics recovered from writers.
+func (s *Service) WriterPanics() int64 {
+	return s.writerPanics.Load()
+}
+
+// safeWriter recovers panics of w as the panic policy says. It is the
+// innermost wrapper, so panics in writes the watchdog runs in a goroutine of
+// its own are recovered too.
+type safeWriter struct {
+	w        io.Writer
+	s        *Service
+	disabled atomic.Bool
+}
+
+func (sw *safeWriter) Write(p []byte) (int, error) {
+	return sw.WriteContext(context.Background(), p)
+}
+
+func (sw *safeWriter) WriteContext(ctx context.Context, p []byte) (n int, err error) {
+	if sw.disabled.Load() {
+		return 0, ErrSinkDisabled
+	}
+	defer sw.recover(&err)
+
+	return writeContext(ctx, sw.w, p)
+}
+
+func (sw *safeWriter) Flush() (err error) {
+	defer sw.recover(&err)
+
+	return flushWriter(sw.w)
+}
+
+func (sw *safeWriter) Sync() (err error) {
+	defer sw.recover(&err)
+
+	return syncWriter(sw.w)
+}
+
+func (sw *safeWriter) SetWriteDeadline(t time.Time) error {
+	if d, ok := sw.w.(writeDeadliner); ok {
+		return d.SetWriteDeadline(t)
+	}
+
+	return nil
+}
+
+func (sw *safeWriter) Probe(ctx context.Context) (err error) {
+	if sw.disabled.Load() {
+		return ErrSinkDisabled
+	}
+	defer sw.recover(&err)
+
+	if p, ok := sw.w.(Prober); ok {
+		return p.Probe(ctx)
+	}
+	_, err = sw.w.Write(nil)
+
+	return err
+}
+
+// Close closes w if it is an io.Closer, so StuckClose still reaches it.
+func (sw *safeWriter) Close() error {
+	if c, ok := sw.w.(io.Closer); ok {
+		return c.Close()
+	}
+
+	return nil
+}
+
+// recover turns a panic into *err, disabling the writer under PanicDisable.
+func (sw *safeWriter) recover(err *error) {
+	v := recover()
+	if v == nil {
+		return
+	}
+
+	s := sw.s
+	s.writerPanics.Add(1)
+	wp := WriterPanic{Writer: sw.w, Value: v, Stack: debug.Stack()}
+	if s.panicPolicy == PanicDisable {
+		sw.disabled.Store(true)
+		wp.Disabled = true
+		*err = fmt.Errorf("%w: %w: %v", ErrSinkDisabled, ErrWriterPanic, v)
+	} else {
+		*err = fmt.Errorf("%w: %v", ErrWriterPanic, v)
+	}
+	s.debugf("writer panicked: %v", v)
+
+	if s.onPanic != nil {
+		s.onPanic(wp)
+	}
+}
+
+// retryPanic reports whether a write that failed with err is retried under
+// the panic policy.
+func (s *Service) retryPanic(err error) bool {
+	return s.panicPolicy == PanicRetry || !errors.Is(err, ErrWriterPanic) && !errors.Is(err, ErrSinkDisabled)
+}
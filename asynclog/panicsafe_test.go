@@ -0,0 +1,96 @@
+package asynclog_test
+
+import (
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+
+	"test-task-log/asynclog"
+	"test-task-log/asynclog/testutil"
+)
+
+// panickyWriter panics in its first panics writes and passes the rest on.
+type panickyWriter struct {
+	*testutil.Sink
+
+	mu     sync.Mutex
+	panics int
+	calls  int
+}
+
+func (w *panickyWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	w.calls++
+	panicking := w.calls <= w.panics
+	w.mu.Unlock()
+
+	if panicking {
+		panic("boom")
+	}
+
+	return w.Sink.Write(p)
+}
+
+func (w *panickyWriter) Calls() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.calls
+}
+
+func TestPanicPolicy(t *testing.T) {
+	for name, tc := range map[string]struct {
+		policy    asynclog.PanicPolicy
+		errA      []error // errors wanted from the first, panicking batch
+		errB      error   // error wanted from the next batch
+		calls     int
+		wantLines []string
+	}{
+		"drop":    {asynclog.PanicDrop, []error{asynclog.ErrWriterPanic}, nil, 2, []string{"b"}},
+		"retry":   {asynclog.PanicRetry, nil, nil, 3, []string{"a", "b"}},
+		"disable": {asynclog.PanicDisable, []error{asynclog.ErrWriterPanic, asynclog.ErrSinkDisabled}, asynclog.ErrSinkDisabled, 1, nil},
+	} {
+		t.Run(name, func(t *testing.T) {
+			var recovered []asynclog.WriterPanic
+			s, _, _ := start(t, asynclog.WithBatchSize(1), asynclog.WithRetry(3, 0, 0),
+				asynclog.WithPanicPolicy(tc.policy, func(wp asynclog.WriterPanic) { recovered = append(recovered, wp) }))
+			w := &panickyWriter{Sink: testutil.NewSink(), panics: 1}
+			if err := s.SetWriter(w); err != nil {
+				t.Fatal(err)
+			}
+
+			err := s.PrintSync(testContext(t), "a")
+			for _, want := range tc.errA {
+				if !errors.Is(err, want) {
+					t.Fatalf("first batch: got %v, want %v", err, want)
+				}
+			}
+			if len(tc.errA) == 0 && err != nil {
+				t.Fatalf("first batch: %v", err)
+			}
+			if err := s.PrintSync(testContext(t), "b"); !errors.Is(err, tc.errB) {
+				t.Fatalf("next batch: got %v, want %v", err, tc.errB)
+			}
+
+			if got := w.Calls(); got != tc.calls {
+				t.Fatalf("writer called %d times, want %d", got, tc.calls)
+			}
+			if got := w.Lines(); !slices.Equal(got, tc.wantLines) {
+				t.Fatalf("got lines %q, want %q", got, tc.wantLines)
+			}
+			if s.WriterPanics() != 1 || len(recovered) != 1 || recovered[0].Value != "boom" ||
+				recovered[0].Disabled != (tc.policy == asynclog.PanicDisable) || len(recovered[0].Stack) == 0 {
+				t.Fatalf("got %d panics, recovered %+v, want the one panic", s.WriterPanics(), recovered)
+			}
+
+			// A disabled writer stays so until it is replaced.
+			if err := s.SetWriter(testutil.NewSink()); err != nil {
+				t.Fatal(err)
+			}
+			if err := s.PrintSync(testContext(t), "c"); err != nil {
+				t.Fatalf("write to a replaced writer: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,74 @@
+package asynclog
+
+// WithEncodeAhead moves encoding off Run into a two-stage pipeline: one
+// goroutine encodes the next batch while another writes the previous one, so
+// encoding overlaps with I/O on slow writers and batches are written in
+// order, one at a time. Once a batch is waiting in each stage, flushes wait
+// for the writer, slowing Run down rather than piling batches up.
+func WithEncodeAhead() Option {
+	return func(s *Service) {
+		s.encodeAhead = true
+	}
+}
+
+// pipelined is a batch on its way through the pipeline.
+type pipelined struct {
+	recs []record
+	o    outgoing
+	done chan struct{}
+	err  chan<- error
+	// replayed is set for records replayed from WithSpill, which are left
+	// in their segment rather than spilled anew if they fail again.
+	replayed bool
+}
+
+// startPipeline starts the encode and write stages and returns a function
+// stopping them once they are idle.
+func (s *Service) startPipeline() (stop func()) {
+	encodeCh := make(chan pipelined, 1)
+	writeCh := make(chan pipelined, 1)
+	stopped := make(chan struct{})
+
+	go func() {
+		for p := range encodeCh {
+			p.o = s.prepare(p.recs)
+			if p.replayed {
+				p.o.recs = nil
+			}
+			writeCh <- p
+		}
+		close(writeCh)
+	}()
+
+	go func() {
+		for p := range writeCh {
+			err := s.send(p.o)
+			if p.err != nil {
+				p.err <- err
+			}
+			s.inflight.Add(int64(-p.o.records))
+			close(p.done)
+			s.bufferWg.Done()
+		}
+		close(stopped)
+	}()
+
+	s.encodeCh = encodeCh
+
+	return func() {
+		close(encodeCh)
+		<-stopped
+	}
+}
+
+// pipeline hands recs to the encode stage, waiting while it is busy. The
+// write error goes to errc unless it is nil.
+func (s *Service) pipeline(recs []record, errc chan<- error, replayed bool) {
+	done := make(chan struct{})
+	s.pruneWrites()
+	s.writes = append(s.writes, done)
+
+	s.inflight.Add(int64(len(recs)))
+	s.bufferWg.Add(1)
+	s.encodeCh <- pipelined{recs: recs, done: done, err: errc, replayed: replayed}
+}
@@ -0,0 +1,50 @@
+package asynclog_test
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+
+	"test-task-log/asynclog"
+)
+
+// failingEncoder fails every batch.
+type failingEncoder struct{ err error }
+
+func (e failingEncoder) Encode([]asynclog.Entry) ([]byte, error) { return nil, e.err }
+
+func TestEncodeAhead(t *testing.T) {
+	s, sink, _ := start(t, asynclog.WithBatchSize(1), asynclog.WithEncodeAhead())
+
+	// Batches of one are written one at a time, in order.
+	var want []string
+	for i := range 20 {
+		want = append(want, fmt.Sprint(i))
+	}
+	printAll(t, s, want...)
+	if err := s.Shutdown(testContext(t)); err != nil {
+		t.Fatal(err)
+	}
+	if got := sink.Lines(); !slices.Equal(got, want) {
+		t.Fatalf("got lines %q, want %q", got, want)
+	}
+}
+
+func TestEncodeAheadErrors(t *testing.T) {
+	s, sink, _ := start(t, asynclog.WithBatchSize(1), asynclog.WithEncodeAhead())
+	down := errors.New("down")
+	sink.Fail(down)
+	if err := s.PrintSync(testContext(t), "a"); !errors.Is(err, down) {
+		t.Fatalf("got %v, want the write error", err)
+	}
+
+	bad := errors.New("bad")
+	s, sink, _ = start(t, asynclog.WithBatchSize(1), asynclog.WithEncodeAhead(), asynclog.WithEncoder(failingEncoder{bad}))
+	if err := s.PrintSync(testContext(t), "a"); !errors.Is(err, bad) {
+		t.Fatalf("got %v, want the encoding error", err)
+	}
+	if got := sink.Writes(); len(got) != 0 {
+		t.Fatalf("got writes %q for a batch failing to encode, want none", got)
+	}
+}
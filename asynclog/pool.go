@@ -0,0 +1,67 @@
+package asynclog
+
+import (
+	"bytes"
+	"sync"
+)
+
+// maxPooledBuffer is the largest buffer put back in bufferPool, so one huge
+// batch doesn't pin its memory for good.
+const maxPooledBuffer = 1 << 20
+
+// bufferPool holds the buffers payloads are built in. Payloads are copied
+// out of them, as writes, retries and spills may hold on to a payload after
+// the flush that built it.
+var bufferPool = sync.Pool{
+	New: func() any { return new(bytes.Buffer) },
+}
+
+func getBuffer() *bytes.Buffer {
+	b := bufferPool.Get().(*bytes.Buffer)
+	b.Reset()
+
+	return b
+}
+
+func putBuffer(b *bytes.Buffer) {
+	if b.Cap() <= maxPooledBuffer {
+		bufferPool.Put(b)
+	}
+}
+
+// entryPool holds the entry slices batches are handed to encoders in.
+var entryPool = sync.Pool{
+	New: func() any { return new([]Entry) },
+}
+
+// getEntries returns recs as entries in a pooled slice, to be handed back
+// with putEntries.
+func getEntries(recs []record) *[]Entry {
+	p := entryPool.Get().(*[]Entry)
+	for _, rec := range recs {
+		*p = append(*p, rec.entry())
+	}
+
+	return p
+}
+
+func putEntries(p *[]Entry) {
+	if cap(*p) > maxPooledBuffer/64 {
+		return
+	}
+
+	clear(*p)
+	*p = (*p)[:0]
+	entryPool.Put(p)
+}
+
+// addQueued buffers everything queued so far, handing the queue's storage
+// back to it once the records are copied into the buffers. It runs in Run.
+func (s *Service) addQueued() {
+	recs := s.queue.pop()
+	for _, rec := range recs {
+		s.add(rec)
+	}
+	s.queue.release(recs)
+	s.flushImmediate()
+}
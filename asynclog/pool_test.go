@@ -0,0 +1,64 @@
+package asynclog_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"slices"
+	"testing"
+
+	"test-task-log/asynclog"
+)
+
+func TestPrintAllocs(t *testing.T) {
+	s := asynclog.NewService(io.Discard, asynclog.WithChannelBuffer(2000))
+	ctx := context.Background()
+
+	allocs := testing.AllocsPerRun(1000, func() {
+		s.Print(ctx, "hello")
+	})
+	if allocs > 0 {
+		t.Fatalf("got %v allocations per Print, want none", allocs)
+	}
+}
+
+func TestPooledReuse(t *testing.T) {
+	// Payload buffers and queue storage are reused from flush to flush;
+	// what was written before must not change under later batches.
+	s, sink, _ := start(t, asynclog.WithBatchSize(3))
+
+	var want []string
+	for round := range 50 {
+		msgs := []string{fmt.Sprint(round, "a"), fmt.Sprint(round, "bb"), fmt.Sprint(round, "ccc")}
+		printAll(t, s, msgs...)
+		if err := s.Flush(testContext(t)); err != nil {
+			t.Fatal(err)
+		}
+		want = append(want, msgs...)
+	}
+
+	if got := sink.Lines(); !slices.Equal(got, want) {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
+
+func TestPooledFailedPayload(t *testing.T) {
+	// The payload of a failed batch outlives the flush that built it.
+	var failed []asynclog.FailedBatch
+	s, sink, _ := start(t, asynclog.WithFailedBatchHandler(func(fb asynclog.FailedBatch) {
+		failed = append(failed, fb)
+	}))
+
+	sink.Fail(io.ErrClosedPipe)
+	printAll(t, s, "lost")
+	s.Flush(testContext(t))
+	sink.Fail(nil)
+	printAll(t, s, "later", "batch")
+	if err := s.Flush(testContext(t)); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(failed) != 1 || string(failed[0].Payload) != "lost\n" {
+		t.Fatalf("got failed batches %+v, want the lost payload intact", failed)
+	}
+}
@@ -0,0 +1,51 @@
+package asynclog_test
+
+import (
+	"slices"
+	"testing"
+
+	"test-task-log/asynclog"
+	"test-task-log/asynclog/testutil"
+)
+
+func TestPriorityQueue(t *testing.T) {
+	for _, tc := range []struct {
+		threshold int
+		want      []string
+	}{
+		{3, []string{"e1", "e2", "w", "a", "b", "c"}},
+		{7, []string{"a", "b", "e1", "w", "c", "e2"}},
+	} {
+		sink := testutil.NewSink()
+		s := asynclog.NewService(sink, asynclog.WithChannelBuffer(10), asynclog.WithPriorityQueue(tc.threshold))
+
+		for _, r := range []struct {
+			level asynclog.Level
+			msg   string
+		}{
+			{asynclog.LevelInfo, "a"},
+			{asynclog.LevelInfo, "b"},
+			{asynclog.LevelError, "e1"},
+			{asynclog.LevelWarn, "w"},
+			{asynclog.LevelInfo, "c"},
+			{asynclog.LevelError, "e2"},
+		} {
+			if err := printShort(s, "", r.level, r.msg); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if got := drain(t, s, sink); !slices.Equal(got, tc.want) {
+			t.Fatalf("threshold %d: got %q, want %q", tc.threshold, got, tc.want)
+		}
+	}
+}
+
+func TestPriorityQueueInvalid(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("WithPriorityQueue(0) didn't panic")
+		}
+	}()
+	asynclog.WithPriorityQueue(0)
+}
@@ -0,0 +1,404 @@
+package asynclog
+
+import (
+	"context"
+	"slices"
+	"sync"
+	"time"
+)
+
+const defaultQueueSize = 1024
+
+// queue hands records from producers over to Run. It holds up to size records
+// and producers block while it is full. With a limit above base the capacity
+// doubles instead, up to limit, and falls back to base once Run has emptied
+// the queue, so short bursts are absorbed without blocking producers.
+//
+// In fair mode every source gets a lane of its own with the full capacity and
+// pop interleaves the lanes round-robin, so a chatty source only blocks itself
+// and can't crowd other sources out of a batch.
+//
+// With compaction a full lane makes room by evicting its oldest record of the
+// lowest level below keep, as long as that is no higher than the incoming
+// record's level, and hands the evicted records to evicted.
+//
+// In priority mode pop hands records out by level, highest first and in
+// order within a level, whenever at least priority records are queued.
+type queue struct {
+	mu     sync.Mutex
+	lanes  map[string]*lane
+	order  []string
+	len    int
+	base   int
+	limit  int
+	fair   bool
+	closed bool
+
+	compact bool
+	keep    Level
+	evicted func([]record)
+
+	overflow OverflowPolicy
+	timeout  time.Duration
+	clock    Clock
+	dropped  func([]record)
+
+	priority int
+
+	ready chan struct{} // signalled when items become available
+	space chan struct{} // closed and replaced every time items are taken
+
+	spare []record // storage for the next new lane, handed back by release
+}
+
+type lane struct {
+	items []record
+	size  int
+}
+
+func newQueue(size, limit int, fair bool) *queue {
+	size = max(size, 1)
+
+	return &queue{
+		lanes: make(map[string]*lane),
+		base:  size,
+		limit: max(limit, size),
+		fair:  fair,
+		ready: make(chan struct{}, 1),
+		space: make(chan struct{}),
+	}
+}
+
+func (q *queue) lane(source string) *lane {
+	if !q.fair {
+		source = ""
+	}
+
+	l, ok := q.lanes[source]
+	if !ok {
+		items := q.spare
+		q.spare = nil
+		if cap(items) < q.base {
+			items = make([]record, 0, q.base)
+		}
+
+		l = &lane{items: items, size: q.base}
+		q.lanes[source] = l
+		q.order = append(q.order, source)
+	}
+
+	return l
+}
+
+// push adds rec to the queue, waiting for space while it is full. It gives up
+// when ctx is done or the queue is closed, returning ctx.Err() or ErrClosed,
+// and returns ErrQueueFull if the overflow policy dropped rec.
+func (q *queue) push(ctx context.Context, rec record) error {
+	// Records that fit are added directly, without the slice pushAll takes.
+	q.mu.Lock()
+	if !q.closed {
+		if l := q.lane(rec.source); len(l.items) < l.size {
+			l.items = append(l.items, rec)
+			q.len++
+			q.mu.Unlock()
+			q.signal()
+
+			return nil
+		}
+	}
+	q.mu.Unlock()
+
+	if q.pushAll(ctx, []record{rec}) == 1 {
+		return nil
+	}
+
+	return q.pushErr(ctx)
+}
+
+// pushErr returns why a push added fewer records than it was given: the
+// queue closed, ctx ended or the overflow policy dropped them.
+func (q *queue) pushErr(ctx context.Context) error {
+	q.mu.Lock()
+	closed := q.closed
+	q.mu.Unlock()
+
+	switch {
+	case closed:
+		return ErrClosed
+	case ctx.Err() != nil:
+		return ctx.Err()
+	}
+
+	return ErrQueueFull
+}
+
+// pushAll adds recs in order, taking the lock once for as many as fit and
+// waiting for space for the rest, or dropping records as the overflow policy
+// says. It returns how many were added, moving them to the front of recs.
+func (q *queue) pushAll(ctx context.Context, recs []record) int {
+	all, added := recs, 0
+	var timeout <-chan time.Time
+	for len(recs) > 0 {
+		q.mu.Lock()
+		if q.closed {
+			q.mu.Unlock()
+			return added
+		}
+
+		pushed, taken := 0, 0
+		var evicted, dropped []record
+	fill:
+		for _, rec := range recs {
+			l := q.lane(rec.source)
+			if len(l.items) >= l.size && l.size < q.limit {
+				l.size = min(l.size*2, q.limit)
+			}
+
+			if len(l.items) >= l.size && q.compact {
+				if i := l.victim(q.keep, rec.level); i >= 0 {
+					evicted = append(evicted, l.items[i])
+					l.items = slices.Delete(l.items, i, i+1)
+					q.len--
+				}
+			}
+
+			if len(l.items) >= l.size {
+				switch q.overflow {
+				case OverflowDropOldest:
+					dropped = append(dropped, l.items[0])
+					l.items = slices.Delete(l.items, 0, 1)
+					q.len--
+				case OverflowDropNewest:
+					dropped = append(dropped, rec)
+					taken++
+					continue
+				default:
+					break fill
+				}
+			}
+
+			l.items = append(l.items, rec)
+			q.len++
+			all[added] = rec
+			added++
+			pushed++
+			taken++
+		}
+		recs = recs[taken:]
+
+		space := q.space
+		q.mu.Unlock()
+
+		if pushed > 0 {
+			q.signal()
+		}
+		if len(evicted) > 0 {
+			q.evicted(evicted)
+		}
+		if len(dropped) > 0 {
+			q.dropped(dropped)
+		}
+
+		if len(recs) == 0 {
+			return added
+		}
+
+		if timeout == nil && q.overflow == OverflowBlockWithTimeout {
+			timeout = q.clock.After(q.timeout)
+		}
+
+		select {
+		case <-space:
+		case <-timeout:
+			q.dropped(recs)
+			return added
+		case <-ctx.Done():
+			return added
+		}
+	}
+
+	return added
+}
+
+// offer adds all of recs if they fit without waiting, evicting or dropping
+// anything. Otherwise nothing is added and it returns what fits does.
+func (q *queue) offer(recs []record) error {
+	q.mu.Lock()
+	if err := q.fitsLocked(recs); err != nil {
+		q.mu.Unlock()
+		return err
+	}
+
+	for _, rec := range recs {
+		l := q.lane(rec.source)
+		for len(l.items) >= l.size {
+			l.size = min(l.size*2, q.limit)
+		}
+
+		l.items = append(l.items, rec)
+		q.len++
+	}
+	q.mu.Unlock()
+
+	if len(recs) > 0 {
+		q.signal()
+	}
+
+	return nil
+}
+
+// fits reports whether recs would fit without waiting, evicting or dropping
+// anything: nil if so, errTooManyRecords if a lane can never hold its share,
+// ErrQueueFull if it can't now, and ErrClosed once the queue is closed.
+func (q *queue) fits(recs []record) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return q.fitsLocked(recs)
+}
+
+func (q *queue) fitsLocked(recs []record) error {
+	if q.closed {
+		return ErrClosed
+	}
+
+	need := make(map[string]int)
+	for _, rec := range recs {
+		source := rec.source
+		if !q.fair {
+			source = ""
+		}
+		need[source]++
+	}
+	for source, n := range need {
+		if n > q.limit {
+			return errTooManyRecords
+		}
+
+		if l, ok := q.lanes[source]; ok && len(l.items)+n > q.limit {
+			return ErrQueueFull
+		}
+	}
+
+	return nil
+}
+
+// victim returns the index of the oldest record of the lowest level below
+// keep and no higher than level, or -1 if there is none.
+func (l *lane) victim(keep, level Level) int {
+	i := -1
+	for j, rec := range l.items {
+		if rec.level < keep && rec.level <= level && (i < 0 || rec.level < l.items[i].level) {
+			i = j
+		}
+	}
+
+	return i
+}
+
+// size returns the number of records queued.
+func (q *queue) size() int {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return q.len
+}
+
+// oldest returns the time of the oldest record queued, zero if none is.
+func (q *queue) oldest() time.Time {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	var t time.Time
+	for _, l := range q.lanes {
+		for _, rec := range l.items {
+			if t.IsZero() || rec.time.Before(t) {
+				t = rec.time
+			}
+		}
+	}
+
+	return t
+}
+
+func (q *queue) signal() {
+	select {
+	case q.ready <- struct{}{}:
+	default:
+	}
+}
+
+// pop takes everything queued so far, taking lanes in turn, and shrinks
+// expanded lanes back to the base capacity.
+func (q *queue) pop() []record {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.len == 0 {
+		return nil
+	}
+
+	var items []record
+	if len(q.order) == 1 {
+		items = q.lanes[q.order[0]].items
+	} else {
+		items = make([]record, 0, q.len)
+		for i := 0; len(items) < q.len; i++ {
+			for _, source := range q.order {
+				if l := q.lanes[source]; i < len(l.items) {
+					items = append(items, l.items[i])
+				}
+			}
+		}
+		if l := q.lanes[q.order[0]]; q.spare == nil && cap(l.items) <= q.limit {
+			clear(l.items)
+			q.spare = l.items[:0]
+		}
+	}
+
+	if q.priority > 0 && q.len >= q.priority {
+		slices.SortStableFunc(items, func(a, b record) int { return int(b.level) - int(a.level) })
+	}
+
+	// Lanes are dropped once emptied so sources that went quiet don't
+	// linger.
+	clear(q.lanes)
+	q.order = q.order[:0]
+	q.len = 0
+
+	if !q.closed {
+		close(q.space)
+		q.space = make(chan struct{})
+	}
+
+	return items
+}
+
+// release hands back what pop returned once the records are copied out, for
+// a new lane to reuse.
+func (q *queue) release(items []record) {
+	if cap(items) < q.base || cap(items) > q.limit {
+		return
+	}
+	clear(items[:cap(items)])
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.spare == nil {
+		q.spare = items[:0]
+	}
+}
+
+// close rejects further pushes and releases producers waiting for space.
+func (q *queue) close() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.closed {
+		return
+	}
+
+	q.closed = true
+	close(q.space)
+}
@@ -0,0 +1,179 @@
+package asynclog_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"test-task-log/asynclog"
+	"test-task-log/asynclog/testutil"
+)
+
+// printShort prints msg at level from source, giving up after a few
+// milliseconds if the queue has no room.
+func printShort(s *asynclog.Service, source string, level asynclog.Level, msg string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	return s.PrintFrom(ctx, source, level, msg)
+}
+
+// drain runs s until everything queued is written to sink, and returns the
+// lines written.
+func drain(t *testing.T, s *asynclog.Service, sink *testutil.Sink) []string {
+	t.Helper()
+
+	go s.Run(context.Background())
+	if err := s.Shutdown(testContext(t)); err != nil {
+		t.Fatal(err)
+	}
+
+	return sink.Lines()
+}
+
+func TestBurstCapacity(t *testing.T) {
+	sink := testutil.NewSink()
+	s := asynclog.NewService(sink, asynclog.WithChannelBuffer(2), asynclog.WithBurstCapacity(4))
+
+	for _, msg := range []string{"a", "b", "c", "d"} {
+		if err := printShort(s, "", asynclog.LevelInfo, msg); err != nil {
+			t.Fatalf("Print(%q) within the burst capacity: %v", msg, err)
+		}
+	}
+	if err := printShort(s, "", asynclog.LevelInfo, "e"); !errors.Is(err, asynclog.ErrTimeout) {
+		t.Fatalf("Print past the burst capacity: got %v, want ErrTimeout", err)
+	}
+
+	if got, want := drain(t, s, sink), []string{"a", "b", "c", "d"}; !slices.Equal(got, want) {
+		t.Fatalf("got lines %q, want %q", got, want)
+	}
+}
+
+func TestFairQueue(t *testing.T) {
+	sink := testutil.NewSink()
+	s := asynclog.NewService(sink, asynclog.WithChannelBuffer(2), asynclog.WithFairQueue())
+
+	for _, msg := range []string{"c1", "c2"} {
+		if err := printShort(s, "chatty", asynclog.LevelInfo, msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := printShort(s, "chatty", asynclog.LevelInfo, "c3"); !errors.Is(err, asynclog.ErrTimeout) {
+		t.Fatalf("Print to a full lane: got %v, want ErrTimeout", err)
+	}
+	// A full lane only blocks its own source.
+	if err := printShort(s, "quiet", asynclog.LevelInfo, "q1"); err != nil {
+		t.Fatalf("Print from another source: %v", err)
+	}
+
+	if got, want := drain(t, s, sink), []string{"c1", "q1", "c2"}; !slices.Equal(got, want) {
+		t.Fatalf("got lines %q, want the lanes interleaved as %q", got, want)
+	}
+}
+
+func TestPrintAll(t *testing.T) {
+	rec := testutil.NewRecorder()
+	sink := testutil.NewSink()
+	s := asynclog.NewService(sink, asynclog.WithChannelBuffer(3), rec.Option())
+
+	if err := s.PrintAll(context.Background(), []string{"a", "b"}); err != nil {
+		t.Fatal(err)
+	}
+	if enqueues := rec.Filter(asynclog.EventEnqueue); len(enqueues) != 1 || enqueues[0].Records != 2 {
+		t.Fatalf("got enqueues %v, want one of 2 records", enqueues)
+	}
+
+	// What fits is queued; the rest waits for room until ctx ends.
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := s.PrintAll(ctx, []string{"c", "d"}); !errors.Is(err, asynclog.ErrTimeout) {
+		t.Fatalf("PrintAll past the queue size: got %v, want ErrTimeout", err)
+	}
+
+	if got, want := drain(t, s, sink), []string{"a", "b", "c"}; !slices.Equal(got, want) {
+		t.Fatalf("got lines %q, want %q", got, want)
+	}
+}
+
+func TestCompaction(t *testing.T) {
+	sink := testutil.NewSink()
+	s := asynclog.NewService(sink, asynclog.WithChannelBuffer(3), asynclog.WithCompaction(asynclog.LevelWarn))
+
+	for _, r := range []struct {
+		level asynclog.Level
+		msg   string
+	}{
+		{asynclog.LevelInfo, "i1"},
+		{asynclog.LevelDebug, "d1"},
+		{asynclog.LevelWarn, "w1"},
+		// Debug goes before info, then the older info.
+		{asynclog.LevelInfo, "i2"},
+		{asynclog.LevelInfo, "i3"},
+	} {
+		if err := printShort(s, "", r.level, r.msg); err != nil {
+			t.Fatalf("Print(%q) into a compacting queue: %v", r.msg, err)
+		}
+	}
+
+	// A record never evicts one of a higher level, so a debug record has
+	// to wait like in a plain queue.
+	if err := printShort(s, "", asynclog.LevelDebug, "d2"); !errors.Is(err, asynclog.ErrTimeout) {
+		t.Fatalf("got %v for a debug record with only info and warn queued, want ErrTimeout", err)
+	}
+
+	if got, want := drain(t, s, sink), []string{"w1", "i2", "i3"}; !slices.Equal(got, want) {
+		t.Fatalf("got lines %q, want %q", got, want)
+	}
+	if got := s.Compacted(); got != 2 {
+		t.Fatalf("got %d compacted, want 2", got)
+	}
+}
+
+func TestOverflowPolicies(t *testing.T) {
+	for name, tc := range map[string]struct {
+		policy asynclog.OverflowPolicy
+		errC   error
+		want   []string
+	}{
+		"drop newest":        {asynclog.OverflowDropNewest, asynclog.ErrQueueFull, []string{"a", "b"}},
+		"drop oldest":        {asynclog.OverflowDropOldest, nil, []string{"b", "c"}},
+		"block with timeout": {asynclog.OverflowBlockWithTimeout, asynclog.ErrQueueFull, []string{"a", "b"}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			sink := testutil.NewSink()
+			s := asynclog.NewService(sink, asynclog.WithChannelBuffer(2), asynclog.WithOverflowPolicy(tc.policy, time.Millisecond))
+
+			printAll(t, s, "a", "b")
+			if err := s.Print(context.Background(), "c"); !errors.Is(err, tc.errC) {
+				t.Fatalf("Print into the full queue: got %v, want %v", err, tc.errC)
+			}
+
+			if got := drain(t, s, sink); !slices.Equal(got, tc.want) {
+				t.Fatalf("got lines %q, want %q", got, tc.want)
+			}
+			if got := s.Dropped(); got != 1 {
+				t.Fatalf("got %d dropped, want 1", got)
+			}
+		})
+	}
+}
+
+func TestOverflowTimeoutClock(t *testing.T) {
+	clock := testutil.NewClock(time.Time{})
+	s := asynclog.NewService(testutil.NewSink(), asynclog.WithClock(clock), asynclog.WithChannelBuffer(1),
+		asynclog.WithOverflowPolicy(asynclog.OverflowBlockWithTimeout, time.Hour))
+
+	// The wait for room runs on the service's clock, not in real time.
+	printAll(t, s, "a")
+	printed := make(chan error, 1)
+	go func() { printed <- s.Print(context.Background(), "b") }()
+	if err := clock.WaitTimers(testContext(t), 1); err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(time.Hour)
+	if err := <-printed; !errors.Is(err, asynclog.ErrQueueFull) {
+		t.Fatalf("got %v, want ErrQueueFull", err)
+	}
+}
@@ -0,0 +1,111 @@
+package asynclog
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// quotas caps how much every source may log per interval. Records over the
+// quota are dropped and reported in one summary record per source and
+// interval.
+type quotas struct {
+	mu      sync.Mutex
+	records int
+	bytes   int
+	per     time.Duration
+	windows map[string]*quotaWindow
+	pending []record
+	dropped int64
+}
+
+type quotaWindow struct {
+	start        time.Time
+	records      int
+	bytes        int
+	dropped      int
+	droppedBytes int
+}
+
+func newQuotas(records, bytes int, per time.Duration) *quotas {
+	return &quotas{
+		records: records,
+		bytes:   bytes,
+		per:     per,
+		windows: make(map[string]*quotaWindow),
+	}
+}
+
+// allow counts a record of size bytes against source's quota at now and
+// reports whether it fits.
+func (q *quotas) allow(now time.Time, source string, size int) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	w, ok := q.windows[source]
+	if ok && now.Sub(w.start) >= q.per {
+		q.close(now, source, w)
+		ok = false
+	}
+	if !ok {
+		w = &quotaWindow{start: now}
+		q.windows[source] = w
+	}
+
+	if (q.records > 0 && w.records+1 > q.records) || (q.bytes > 0 && w.bytes+size > q.bytes) {
+		w.dropped++
+		w.droppedBytes += size
+		q.dropped++
+		return false
+	}
+
+	w.records++
+	w.bytes += size
+
+	return true
+}
+
+// droppedCount returns the number of records dropped over quota, zero on a
+// nil q.
+func (q *quotas) droppedCount() int64 {
+	if q == nil {
+		return 0
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return q.dropped
+}
+
+// summaries closes the windows that are over and returns a summary record for
+// every source that had records dropped in them.
+func (q *quotas) summaries(now time.Time) []record {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for source, w := range q.windows {
+		if now.Sub(w.start) >= q.per {
+			q.close(now, source, w)
+		}
+	}
+
+	recs := q.pending
+	q.pending = nil
+
+	return recs
+}
+
+func (q *quotas) close(now time.Time, source string, w *quotaWindow) {
+	if w.dropped > 0 {
+		q.pending = append(q.pending, record{
+			level:  LevelWarn,
+			source: source,
+			time:   now,
+			msg: fmt.Sprintf("source %q over quota: dropped %d records (%d bytes) in %s",
+				source, w.dropped, w.droppedBytes, q.per),
+		})
+	}
+
+	delete(q.windows, source)
+}
@@ -0,0 +1,97 @@
+package asynclog
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// tokenBucket paces producers to rate records per second, allowing bursts of
+// up to burst records. Callers over the rate wait for their token instead of
+// being rejected.
+type tokenBucket struct {
+	mu     sync.Mutex
+	rate   float64
+	burst  float64
+	tokens float64
+	last   time.Time
+}
+
+// checkRate panics unless perSecond is a usable rate for option.
+func checkRate(option string, perSecond float64) {
+	if !(perSecond > 0) || math.IsInf(perSecond, 1) {
+		panic(fmt.Sprintf("asynclog: %s: rate %v is not positive and finite", option, perSecond))
+	}
+}
+
+func newTokenBucket(rate float64, burst int) *tokenBucket {
+	return &tokenBucket{
+		rate:   rate,
+		burst:  float64(max(burst, 1)),
+		tokens: float64(max(burst, 1)),
+	}
+}
+
+// wait takes n tokens, sleeping on clock until they are available. It returns
+// false if ctx is done first, handing the tokens back.
+func (b *tokenBucket) wait(ctx context.Context, clock Clock, n int) bool {
+	b.mu.Lock()
+	now := clock.Now()
+	if b.last.IsZero() {
+		b.last = now
+	}
+	b.tokens = min(b.burst, b.tokens+now.Sub(b.last).Seconds()*b.rate)
+	b.last = now
+
+	// Take the tokens up front, going into debt if needed, so concurrent
+	// callers queue up behind each other instead of racing for it.
+	b.tokens -= float64(n)
+	delay := time.Duration(-b.tokens / b.rate * float64(time.Second))
+	b.mu.Unlock()
+
+	if delay <= 0 {
+		return true
+	}
+
+	select {
+	case <-clock.After(delay):
+		return true
+	case <-ctx.Done():
+		b.refund(n)
+		return false
+	}
+}
+
+// refund hands back n tokens taken by a caller that gave up on them, so they
+// neither push later callers' waits out nor go to waste.
+func (b *tokenBucket) refund(n int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.tokens = min(b.burst, b.tokens+float64(n))
+}
+
+// waitAll takes tokens[b] tokens from every bucket b. If ctx is done first it
+// returns false, refunding the tokens taken from the buckets waited for
+// already.
+func waitAll(ctx context.Context, clock Clock, tokens map[*tokenBucket]int) bool {
+	taken := make(map[*tokenBucket]int, len(tokens))
+	for b, n := range tokens {
+		if !b.wait(ctx, clock, n) {
+			refundAll(taken)
+			return false
+		}
+		taken[b] = n
+	}
+
+	return true
+}
+
+// refundAll hands back tokens[b] tokens to every bucket b.
+func refundAll(tokens map[*tokenBucket]int) {
+	for b, n := range tokens {
+		b.refund(n)
+	}
+}
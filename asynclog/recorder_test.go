@@ -0,0 +1,81 @@
+package asynclog_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"test-task-log/asynclog"
+	"test-task-log/asynclog/testutil"
+)
+
+// failTB records the failures of a testutil assertion instead of failing
+// the test.
+type failTB struct {
+	testing.TB
+	failures []string
+}
+
+func (f *failTB) Helper() {}
+
+func (f *failTB) Fatalf(format string, args ...any) {
+	f.failures = append(f.failures, fmt.Sprintf(format, args...))
+}
+
+func TestRecorder(t *testing.T) {
+	rec := testutil.NewRecorder()
+	s, sink, clock := start(t, rec.Option())
+
+	printAll(t, s, "a")
+	rec.Advance(5 * time.Second)
+	clock.Advance(5 * time.Second)
+	if err := sink.WaitLines(testContext(t), 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := rec.Wait(testContext(t), asynclog.EventWrite, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	if e := rec.Filter(asynclog.EventEnqueue); len(e) != 1 || e[0].At != 0 {
+		t.Fatalf("got enqueues %v, want one at +0s", e)
+	}
+	if e := rec.Filter(asynclog.EventFlush); len(e) != 1 || e[0].At != 5*time.Second {
+		t.Fatalf("got flushes %v, want one at +5s", e)
+	}
+	rec.AssertOrder(t, asynclog.EventEnqueue, asynclog.EventFlush, asynclog.EventWrite)
+	rec.AssertWritten(t, "a")
+
+	rec.Reset()
+	if e := rec.Events(); len(e) != 0 {
+		t.Fatalf("got %v after Reset, want no events", e)
+	}
+}
+
+func TestRecorderFailures(t *testing.T) {
+	rec := testutil.NewRecorder()
+	s, sink, clock := start(t, rec.Option())
+
+	printAll(t, s, "a")
+	clock.Advance(5 * time.Second)
+	if err := sink.WaitLines(testContext(t), 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := rec.Wait(testContext(t), asynclog.EventWrite, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	ft := &failTB{TB: t}
+	rec.AssertOrder(ft, asynclog.EventWrite, asynclog.EventEnqueue)
+	rec.AssertWritten(ft, "b")
+	if len(ft.failures) != 2 {
+		t.Fatalf("got failures %q, want the wrong order and the wrong messages", ft.failures)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := rec.Wait(ctx, asynclog.EventWrite, 2); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("Wait for a missing event: got %v, want the context's error", err)
+	}
+}
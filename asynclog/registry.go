@@ -0,0 +1,160 @@
+package asynclog
+
+import (
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// SinkFactory builds a sink from its URI.
+type SinkFactory func(u *url.URL) (io.Writer, error)
+
+var (
+	registryMx sync.RWMutex
+	registry   = map[string]SinkFactory{}
+)
+
+// RegisterSink makes OpenSink build sinks for URIs with the given scheme with
+// factory. Registering a scheme twice replaces the factory.
+func RegisterSink(scheme string, factory SinkFactory) {
+	registryMx.Lock()
+	defer registryMx.Unlock()
+
+	registry[scheme] = factory
+}
+
+// RegisteredSinks returns the registered schemes in sorted order.
+func RegisteredSinks() []string {
+	registryMx.RLock()
+	defer registryMx.RUnlock()
+
+	schemes := make([]string, 0, len(registry))
+	for scheme := range registry {
+		schemes = append(schemes, scheme)
+	}
+	sort.Strings(schemes)
+
+	return schemes
+}
+
+// OpenSink builds a sink from a URI such as file:///var/log/app.log,
+// tcp://collector:601?tls=1 or stdout:, using the factory registered for its
+// scheme.
+func OpenSink(uri string) (io.Writer, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, fmt.Errorf("asynclog: sink %q: %w", uri, err)
+	}
+
+	registryMx.RLock()
+	factory, ok := registry[u.Scheme]
+	registryMx.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("asynclog: sink %q: unknown scheme %q", uri, u.Scheme)
+	}
+
+	w, err := factory(u)
+	if err != nil {
+		return nil, fmt.Errorf("asynclog: sink %q: %w", uri, err)
+	}
+
+	return w, nil
+}
+
+// checkParams rejects query parameters other than known ones, so a typo in a
+// sink URI doesn't go unnoticed.
+func checkParams(u *url.URL, known ...string) error {
+	for name := range u.Query() {
+		found := false
+		for _, k := range known {
+			found = found || name == k
+		}
+		if !found {
+			return fmt.Errorf("unknown parameter %q", name)
+		}
+	}
+
+	return nil
+}
+
+func init() {
+	RegisterSink("stdout", func(u *url.URL) (io.Writer, error) {
+		return os.Stdout, checkParams(u)
+	})
+	RegisterSink("stderr", func(u *url.URL) (io.Writer, error) {
+		return os.Stderr, checkParams(u)
+	})
+	RegisterSink("file", openFileSink)
+	RegisterSink("tcp", dialSink)
+	RegisterSink("udp", dialSink)
+	RegisterSink("exec", openCommandSink)
+	RegisterSink("syslog", openSyslogSink)
+	RegisterSink("http", openHTTPSink)
+	RegisterSink("https", openHTTPSink)
+}
+
+func openFileSink(u *url.URL) (io.Writer, error) {
+	if err := checkParams(u, "verify", "max_size", "max_age", "compress", "max_backups", "max_backup_age", "atomic"); err != nil {
+		return nil, err
+	}
+
+	if u.Path == "" {
+		return nil, fmt.Errorf("missing path")
+	}
+
+	var verify bool
+	if v := u.Query().Get("verify"); v != "" {
+		var err error
+		if verify, err = strconv.ParseBool(v); err != nil {
+			return nil, fmt.Errorf("invalid verify %q", v)
+		}
+	}
+
+	if opts, ok, err := fileSinkOptions(u.Query()); err != nil || ok {
+		if err != nil {
+			return nil, err
+		}
+		if verify {
+			return nil, fmt.Errorf("verify is not supported with rotation or atomic writes")
+		}
+
+		return NewFileSink(u.Path, opts)
+	}
+
+	f, err := os.OpenFile(u.Path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, err
+	}
+
+	if verify {
+		return newVerifiedFile(f), nil
+	}
+
+	return f, nil
+}
+
+func dialSink(u *url.URL) (io.Writer, error) {
+	if err := checkParams(u, "tls"); err != nil {
+		return nil, err
+	}
+
+	if u.Host == "" {
+		return nil, fmt.Errorf("missing host")
+	}
+
+	if tlsParam := u.Query().Get("tls"); tlsParam == "1" || tlsParam == "true" {
+		if u.Scheme != "tcp" {
+			return nil, fmt.Errorf("tls is only supported over tcp")
+		}
+
+		return tls.Dial("tcp", u.Host, &tls.Config{ServerName: u.Hostname()})
+	}
+
+	return net.Dial(u.Scheme, u.Host)
+}
@@ -0,0 +1,61 @@
+package asynclog_test
+
+import (
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"test-task-log/asynclog"
+	"test-task-log/asynclog/testutil"
+)
+
+func TestOpenSink(t *testing.T) {
+	sink := testutil.NewSink()
+	var got *url.URL
+	asynclog.RegisterSink("registrytest", func(u *url.URL) (io.Writer, error) {
+		got = u
+		return sink, nil
+	})
+	if !slices.Contains(asynclog.RegisteredSinks(), "registrytest") {
+		t.Fatal("registered scheme not listed")
+	}
+
+	w, err := asynclog.OpenSink("registrytest://host/path?x=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w != sink || got.Host != "host" || got.Query().Get("x") != "1" {
+		t.Fatalf("got %v from %v, want the factory's sink built from the URI", w, got)
+	}
+
+	path := filepath.Join(t.TempDir(), "app.log")
+	f, err := asynclog.OpenSink("file://" + path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Write([]byte("a\n"))
+	f.(io.Closer).Close()
+	if b, err := os.ReadFile(path); err != nil || string(b) != "a\n" {
+		t.Fatalf("got %q, %v, want the file sink's write", b, err)
+	}
+}
+
+func TestOpenSinkErrors(t *testing.T) {
+	for _, uri := range []string{
+		"nosuchscheme://x",
+		"stdout:?typo=1",
+		"file://",
+		"tcp://",
+		"udp://host:1?tls=1",
+		"syslog://host?facility=99",
+		"http://",
+		"%zz",
+	} {
+		if _, err := asynclog.OpenSink(uri); err == nil {
+			t.Errorf("OpenSink(%q) succeeded", uri)
+		}
+	}
+}
@@ -0,0 +1,236 @@
+package asynclog
+
+import (
+	"cmp"
+	"io"
+	"os"
+	"slices"
+	"time"
+)
+
+type reloadRequest struct {
+	c       *Config
+	writer  io.Writer
+	sinks   []namedSink
+	encoder Encoder
+	filter  *Expr
+	opened  []io.Writer
+	err     chan error
+	// tune is set by Reconfigure, which only changes the settings tune
+	// applies.
+	tune bool
+}
+
+// Reload switches a running service over to c: its writer, sinks, routes,
+// sink batching, encoder, filter, level, flush interval, batch size and
+// batch byte limit. c is validated and every sink opened first, so a bad
+// config changes nothing and returns the error. The switch happens in Run
+// between flushes: everything buffered so far goes to the old writer and
+// sinks, as with SetWriter, and Reload returns the error of that final write
+// if any. A zero flush interval, batch size or byte limit, or an empty level,
+// keeps the current one. Once that write is done the old writer and sinks
+// are closed if they were opened from a Config, by Config.NewService or an
+// earlier Reload, except for stdout and stderr; writers handed to the
+// service directly are left to their owner.
+func (s *Service) Reload(c *Config) error {
+	if err := c.Validate(); err != nil {
+		return err
+	}
+
+	routes, err := c.compileRoutes()
+	if err != nil {
+		return err
+	}
+
+	req := reloadRequest{c: c, err: make(chan error, 1)}
+	if c.Encoder != "" {
+		if req.encoder, err = ParseEncoder(c.Encoder); err != nil {
+			return err
+		}
+	}
+	if c.Filter != "" {
+		if req.filter, err = CompileExpr(c.Filter); err != nil {
+			return err
+		}
+	}
+
+	var opened []io.Writer
+	fail := func(err error) error {
+		closeOpened(opened)
+		return err
+	}
+
+	uri := c.Writer
+	if uri == "" {
+		uri = "stdout:"
+	}
+	w, err := OpenSink(uri)
+	if err != nil {
+		return err
+	}
+	opened = append(opened, w)
+	req.writer = s.wrap(w)
+
+	names := make([]string, 0, len(c.Sinks))
+	for name := range c.Sinks {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	for _, name := range names {
+		sw, err := OpenSink(c.Sinks[name])
+		if err != nil {
+			return fail(err)
+		}
+		opened = append(opened, sw)
+
+		sk := namedSink{name: name, writer: s.sinkWriter(sw), route: routes[name]}
+		if b, ok := c.SinkBatching[name]; ok {
+			sk.staged = &sinkBatch{every: time.Duration(b.FlushInterval), limit: b.BatchSize}
+		}
+		req.sinks = append(req.sinks, sk)
+	}
+
+	req.opened = opened
+
+	select {
+	case s.reloadCh <- req:
+		return <-req.err
+	case <-s.done:
+		for _, sk := range req.sinks {
+			closeQueued(sk.writer)
+		}
+		return fail(ErrClosed)
+	}
+}
+
+// Reconfigure changes the flush interval, batch size, batch byte limit,
+// level and filter of a running service to those set in c, leaving its
+// writer, sinks, routes and encoder alone; zero or empty settings keep the
+// current ones. Unlike Reload it opens nothing and writes nothing out early:
+// buffered records stay buffered, and buffers already past the new batch
+// size or byte limit are flushed. The interval takes effect from the next
+// tick.
+func (s *Service) Reconfigure(c *Config) error {
+	if err := c.Validate(); err != nil {
+		return err
+	}
+
+	req := reloadRequest{c: c, err: make(chan error, 1), tune: true}
+	if c.Filter != "" {
+		var err error
+		if req.filter, err = CompileExpr(c.Filter); err != nil {
+			return err
+		}
+	}
+
+	select {
+	case s.reloadCh <- req:
+		return <-req.err
+	case <-s.done:
+		return ErrClosed
+	}
+}
+
+// reload runs in Run, between flushes. Records queued before the reload are
+// still written the old way.
+func (s *Service) reload(req reloadRequest) error {
+	if req.tune {
+		s.writerMx.Lock()
+		s.tune(req.c)
+		s.writerMx.Unlock()
+
+		if req.filter != nil {
+			s.filter.Store(req.filter)
+		}
+		for _, b := range s.buffers() {
+			if len(b.records) > 0 && (len(b.records) >= s.writeLimit || s.maxBatchBytes > 0 && b.bytes >= s.maxBatchBytes) {
+				s.flush("reconfigure", b)
+			}
+		}
+		s.debugf("reconfigured: flush every %s, batch size %d, level %s", s.writeEvery, s.writeLimit, s.Level())
+
+		return nil
+	}
+
+	s.addQueued()
+	recs := s.take(s.buffers()...)
+	s.bufferWg.Wait()
+
+	var err error
+	if len(recs) > 0 {
+		s.event(EventFlush, "reload", recs)
+		err = s.sendAll(recs)
+	}
+	s.flushSinks()
+	s.bufferWg.Wait()
+	if serr := syncWriter(s.currentWriter()); err == nil {
+		err = serr
+	}
+
+	// The encode stage of WithEncodeAhead reads the encoder in targets, so it
+	// is swapped along with the writers.
+	s.writerMx.Lock()
+	old, opened := s.sinks, s.opened
+	s.opened = req.opened
+	s.writer = req.writer
+	s.sinks = req.sinks
+	s.encoder = req.encoder
+	for i, sk := range old {
+		if sk.name == ErrorSinkName && !slices.ContainsFunc(s.sinks, func(n namedSink) bool { return n.name == ErrorSinkName }) {
+			s.sinks = append(s.sinks, sk)
+			old = slices.Delete(old, i, i+1)
+			break
+		}
+	}
+	s.tune(req.c)
+	for _, sk := range s.sinks {
+		if sk.staged != nil {
+			sk.staged.every = cmp.Or(sk.staged.every, s.writeEvery)
+			sk.staged.limit = cmp.Or(sk.staged.limit, s.writeLimit)
+		}
+	}
+	s.writerMx.Unlock()
+
+	// Queued sinks may still be writing the final batch.
+	for _, sk := range old {
+		syncWriter(sk.writer)
+		closeQueued(sk.writer)
+	}
+	closeOpened(opened)
+
+	s.filter.Store(req.filter)
+	s.debugf("reloaded config: %d sinks", len(req.sinks))
+
+	return err
+}
+
+// tune applies the batching settings and level set in c. It is called in Run
+// with writerMx held.
+func (s *Service) tune(c *Config) {
+	if c.FlushInterval > 0 {
+		s.writeEvery = time.Duration(c.FlushInterval)
+	}
+	if c.BatchSize > 0 {
+		s.writeLimit = c.BatchSize
+	}
+	if c.MaxBatchBytes > 0 {
+		s.maxBatchBytes = c.MaxBatchBytes
+	}
+	if level, err := ParseLevel(c.Level); err == nil {
+		s.SetLevel(level)
+	}
+}
+
+// closeOpened closes the writers a failed Reload opened, or those a reload
+// replaced, leaving stdout and stderr alone.
+func closeOpened(ws []io.Writer) {
+	for _, w := range ws {
+		if w == os.Stdout || w == os.Stderr {
+			continue
+		}
+		if c, ok := w.(io.Closer); ok {
+			c.Close()
+		}
+	}
+}
@@ -0,0 +1,183 @@
+package asynclog_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/url"
+	"slices"
+	"sync/atomic"
+	"testing"
+
+	"test-task-log/asynclog"
+	"test-task-log/asynclog/testutil"
+)
+
+func TestReload(t *testing.T) {
+	next := testutil.NewSink()
+	asynclog.RegisterSink("reloadtest", func(*url.URL) (io.Writer, error) {
+		return next, nil
+	})
+
+	s, sink, _ := start(t)
+	printAll(t, s, "before")
+
+	if err := s.Reload(&asynclog.Config{Writer: "reloadtest:", BatchSize: 2}); err != nil {
+		t.Fatal(err)
+	}
+	if got := sink.Lines(); !slices.Equal(got, []string{"before"}) {
+		t.Fatalf("old writer got %q, want the records buffered before the reload", got)
+	}
+
+	printAll(t, s, "a", "b")
+	if err := next.WaitLines(testContext(t), 2); err != nil {
+		t.Fatal(err)
+	}
+	if got := next.Lines(); !slices.Equal(got, []string{"a", "b"}) {
+		t.Fatalf("new writer got %q, want a full batch of the new size", got)
+	}
+	if got := sink.Lines(); len(got) != 1 {
+		t.Fatalf("old writer got %q after the reload", got)
+	}
+}
+
+// closeLines records the lines its sink had when it was closed.
+type closeLines struct {
+	*testutil.Sink
+	lines chan []string
+}
+
+func (c closeLines) Close() error {
+	c.lines <- c.Lines()
+	return nil
+}
+
+func TestReloadClosesReplaced(t *testing.T) {
+	old := map[string]closeLines{}
+	for _, name := range []string{"main", "audit"} {
+		old[name] = closeLines{Sink: testutil.NewSink(), lines: make(chan []string, 1)}
+		asynclog.RegisterSink("reloadold"+name, func(*url.URL) (io.Writer, error) {
+			return old[name], nil
+		})
+	}
+	var closed atomic.Int32
+	asynclog.RegisterSink("reloadnew", func(*url.URL) (io.Writer, error) {
+		return closeCounter{Writer: io.Discard, closed: &closed}, nil
+	})
+
+	c := &asynclog.Config{Writer: "reloadoldmain:", Sinks: map[string]string{"audit": "reloadoldaudit:"}}
+	s, err := c.NewService(asynclog.WithSinkQueues(4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	go s.Run(context.Background())
+	printAll(t, s, "before")
+
+	if err := s.Reload(&asynclog.Config{Writer: "reloadnew:"}); err != nil {
+		t.Fatal(err)
+	}
+	for name, w := range old {
+		select {
+		case got := <-w.lines:
+			if !slices.Equal(got, []string{"before"}) {
+				t.Errorf("%s closed with %q, want it closed after the final write", name, got)
+			}
+		default:
+			t.Errorf("%s not closed", name)
+		}
+	}
+
+	// A second reload closes the writer the first one opened.
+	if err := s.Reload(&asynclog.Config{Writer: "reloadnew:"}); err != nil {
+		t.Fatal(err)
+	}
+	if n := closed.Load(); n != 1 {
+		t.Fatalf("writer replaced by the second reload closed %d times, want once", n)
+	}
+	if err := s.Shutdown(testContext(t)); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReloadErrors(t *testing.T) {
+	var closed atomic.Int32
+	asynclog.RegisterSink("reloadclose", func(*url.URL) (io.Writer, error) {
+		return closeCounter{Writer: io.Discard, closed: &closed}, nil
+	})
+	asynclog.RegisterSink("reloadfail", func(*url.URL) (io.Writer, error) {
+		return nil, errors.New("unreachable")
+	})
+
+	s, sink, _ := start(t)
+	for _, c := range []*asynclog.Config{
+		{Writer: "reloadclose:", Filter: "level >>> ("},
+		{Writer: "reloadclose:", Level: "loud"},
+		{Writer: "nosuchscheme:"},
+		{Writer: "reloadclose:", Sinks: map[string]string{"broken": "reloadfail:"}},
+	} {
+		if err := s.Reload(c); err == nil {
+			t.Errorf("Reload(%+v) succeeded", c)
+		}
+	}
+	if n := closed.Load(); n != 1 {
+		t.Fatalf("writer opened for a failed reload closed %d times, want once", n)
+	}
+
+	printAll(t, s, "a")
+	if err := s.Shutdown(testContext(t)); err != nil {
+		t.Fatal(err)
+	}
+	if got := sink.Lines(); !slices.Equal(got, []string{"a"}) {
+		t.Fatalf("got %q, want the old writer kept after failed reloads", got)
+	}
+	if err := s.Reload(&asynclog.Config{Writer: "reloadclose:"}); !errors.Is(err, asynclog.ErrClosed) {
+		t.Fatalf("Reload after Shutdown: got %v, want ErrClosed", err)
+	}
+}
+
+func TestReconfigure(t *testing.T) {
+	s, sink, _ := start(t)
+	printAll(t, s, "a", "b", "c")
+	waitBuffered(t, s)
+
+	// The writer is left alone; the buffer already past the new batch size
+	// is flushed.
+	if err := s.Reconfigure(&asynclog.Config{Writer: "nosuchscheme:", BatchSize: 2, Level: "warn"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := sink.WaitLines(testContext(t), 3); err != nil {
+		t.Fatal(err)
+	}
+	if s.Level() != asynclog.LevelWarn {
+		t.Fatalf("got level %s, want WARN", s.Level())
+	}
+
+	if err := s.Reconfigure(&asynclog.Config{Filter: "level >>> ("}); err == nil {
+		t.Fatal("bad filter accepted")
+	}
+	for _, r := range []struct {
+		level asynclog.Level
+		msg   string
+	}{
+		{asynclog.LevelInfo, "filtered"},
+		{asynclog.LevelWarn, "w1"},
+		{asynclog.LevelWarn, "w2"},
+	} {
+		if err := printShort(s, "", r.level, r.msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := sink.WaitLines(testContext(t), 5); err != nil {
+		t.Fatal(err)
+	}
+	if got := sink.Lines(); !slices.Equal(got, []string{"a", "b", "c", "w1", "w2"}) {
+		t.Fatalf("got %q, want the settings kept after a failed Reconfigure", got)
+	}
+
+	if err := s.Shutdown(testContext(t)); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Reconfigure(&asynclog.Config{}); !errors.Is(err, asynclog.ErrClosed) {
+		t.Fatalf("Reconfigure after Shutdown: got %v, want ErrClosed", err)
+	}
+}
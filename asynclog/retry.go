@@ -0,0 +1,116 @@
+package asynclog
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/rand/v2"
+	"time"
+)
+
+// retryPolicy is how failed writes are retried, see WithRetry.
+type retryPolicy struct {
+	attempts int
+	base     time.Duration
+	max      time.Duration
+}
+
+// FailedBatch is a batch payload a writer failed to take on every attempt,
+// less what a partial write got out.
+type FailedBatch struct {
+	Writer   io.Writer
+	Payload  []byte
+	Attempts int
+	Err      error
+}
+
+// WithRetry makes up to attempts tries in all at writing a batch to a writer,
+// waiting base before the first retry and twice as long before each next
+// one, up to maxDelay (no limit when zero), with jitter so writers coming back
+// from an outage aren't hit by every retry at once. A retry only writes what
+// the failed attempt didn't; when several writers are combined it writes the
+// batch again to those that failed only. Retries stop once the
+// WithWriteTimeout deadline is up; batches still failing then go to the
+// handler of WithFailedBatchHandler.
+func WithRetry(attempts int, base, maxDelay time.Duration) Option {
+	if attempts < 1 || base < 0 || maxDelay < 0 {
+		panic(fmt.Sprintf("asynclog: WithRetry: invalid policy %d, %s, %s", attempts, base, maxDelay))
+	}
+
+	return func(s *Service) {
+		s.retry = retryPolicy{attempts: attempts, base: base, max: maxDelay}
+	}
+}
+
+// WithFailedBatchHandler calls fn with every batch payload that failed to be
+// written, after any WithRetry attempts, instead of the batch only being
+// counted as failed. fn is called from write goroutines and must not block for
+// long; shadow writers are left out.
+func WithFailedBatchHandler(fn func(FailedBatch)) Option {
+	return func(s *Service) {
+		s.failedBatch = fn
+	}
+}
+
+// writeRetrying writes t and flushes its writer, retrying under the retry
+// policy, and returns the attempts made, the part of the payload the last
+// one didn't write and its error.
+func (s *Service) writeRetrying(ctx context.Context, t target) (int, []byte, error) {
+	w, p := t.writer, t.payload
+	for attempt := 1; ; attempt++ {
+		n, err := writeTarget(ctx, target{writer: w, payload: p})
+		var merr MultiError
+		switch {
+		case err == nil:
+			p = nil
+			err = flushWriter(w)
+		case !errors.As(err, &merr):
+			p = p[min(max(n, 0), len(p)):]
+		}
+
+		if err == nil || attempt >= s.retry.attempts || !s.retryPanic(err) {
+			return attempt, p, err
+		}
+
+		if errors.As(err, &merr) {
+			ws := make([]io.Writer, len(merr))
+			for i, we := range merr {
+				ws[i] = we.Writer
+			}
+			w = NewMultiWriter(ws...)
+			if len(ws) == 1 {
+				w = ws[0]
+			}
+		}
+
+		d := s.retry.delay(attempt)
+		s.debugf("write failed (attempt %d of %d), retrying in %s: %v", attempt, s.retry.attempts, d, err)
+
+		select {
+		case <-s.clock.After(d):
+		case <-ctx.Done():
+			return attempt, p, err
+		}
+	}
+}
+
+// delay returns the jittered wait before retrying after the given attempt:
+// somewhere between half and all of the exponential delay, which saturates
+// rather than overflowing.
+func (p retryPolicy) delay(attempt int) time.Duration {
+	shift := min(max(attempt-1, 0), 30)
+	d := time.Duration(math.MaxInt64)
+	if p.base <= d>>shift {
+		d = p.base << shift
+	}
+	if p.max > 0 && d > p.max {
+		d = p.max
+	}
+	if d <= 0 {
+		return 0
+	}
+
+	return d/2 + rand.N(d/2+1)
+}
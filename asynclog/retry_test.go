@@ -0,0 +1,27 @@
+package asynclog
+
+import (
+	"testing"
+	"time"
+)
+
+func TestRetryDelay(t *testing.T) {
+	for _, tc := range []struct {
+		p        retryPolicy
+		attempt  int
+		min, max time.Duration
+	}{
+		{retryPolicy{base: time.Second}, 1, 500 * time.Millisecond, time.Second},
+		{retryPolicy{base: time.Second}, 3, 2 * time.Second, 4 * time.Second},
+		{retryPolicy{base: time.Second, max: 3 * time.Second}, 3, 1500 * time.Millisecond, 3 * time.Second},
+		{retryPolicy{base: 10 * time.Second}, 40, 1<<62 - 1, 1<<63 - 1},
+		{retryPolicy{base: 10 * time.Second, max: time.Minute}, 1000, 30 * time.Second, time.Minute},
+		{retryPolicy{}, 5, 0, 0},
+	} {
+		for range 100 {
+			if d := tc.p.delay(tc.attempt); d < tc.min || d > tc.max {
+				t.Fatalf("%+v attempt %d: got %s, want between %s and %s", tc.p, tc.attempt, d, tc.min, tc.max)
+			}
+		}
+	}
+}
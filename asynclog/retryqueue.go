@@ -0,0 +1,257 @@
+package asynclog
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"reflect"
+	"sync"
+)
+
+// retryQueueMagic starts a retry queue file.
+const retryQueueMagic = "ALRQ1\n"
+
+// WithRetryQueue keeps up to max batches the main writer failed to take,
+// after any WithRetry attempts, and writes them again, oldest first, every
+// flush interval until it takes them, so a collector being down for a while
+// loses nothing. Once full the oldest batches are dropped. The queue is
+// saved to path at shutdown and loaded back when Run starts, so batches
+// pending redelivery survive restarts. Redelivered batches may come after
+// newer ones. Failures of sinks aren't queued.
+func WithRetryQueue(path string, max int) Option {
+	if max < 1 {
+		panic(fmt.Sprintf("asynclog: WithRetryQueue: non-positive size %d", max))
+	}
+
+	return func(s *Service) {
+		s.retryQ = &retryQueue{path: path, max: max}
+	}
+}
+
+// retryQueue holds batch payloads pending redelivery to the main writer.
+type retryQueue struct {
+	path string
+	max  int
+
+	mu      sync.Mutex
+	batches [][]byte
+	busy    bool // a redelivery has taken the batches
+	lost    int64
+}
+
+// RetryQueued returns the number of batches waiting to be written again
+// under WithRetryQueue, and how many were dropped because it was full.
+func (s *Service) RetryQueued() (pending int, lost int64) {
+	if s.retryQ == nil {
+		return 0, 0
+	}
+
+	s.retryQ.mu.Lock()
+	defer s.retryQ.mu.Unlock()
+
+	return len(s.retryQ.batches), s.retryQ.lost
+}
+
+func (q *retryQueue) add(p []byte) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.batches = append(q.batches, p)
+	q.trim()
+}
+
+func (q *retryQueue) trim() {
+	if n := len(q.batches) - q.max; n > 0 {
+		clear(q.batches[:n])
+		q.batches = q.batches[n:]
+		q.lost += int64(n)
+	}
+}
+
+// take returns the queued batches for a redelivery, or nil if there are none
+// or another redelivery has them.
+func (q *retryQueue) take() [][]byte {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.busy || len(q.batches) == 0 {
+		return nil
+	}
+
+	q.busy = true
+	batches := q.batches
+	q.batches = nil
+
+	return batches
+}
+
+// putBack ends a redelivery, queueing the batches it didn't write ahead of
+// those added meanwhile.
+func (q *retryQueue) putBack(batches [][]byte) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.busy = false
+	q.batches = append(batches, q.batches...)
+	q.trim()
+}
+
+// queueFailed queues the payload of t if writing it failed for the main
+// writer rather than only for sinks combined with it.
+func (s *Service) queueFailed(t target, err error) {
+	var merr MultiError
+	if errors.As(err, &merr) {
+		failed := false
+		for _, we := range merr {
+			failed = failed || sameWriter(we.Writer, t.retryTo)
+		}
+		if !failed {
+			return
+		}
+	}
+
+	s.debugf("queueing a failed batch of %d bytes for redelivery", len(t.payload))
+	s.retryQ.add(t.payload)
+}
+
+func sameWriter(a, b io.Writer) bool {
+	ta := reflect.TypeOf(a)
+	if ta == nil || ta != reflect.TypeOf(b) || !ta.Comparable() {
+		return false
+	}
+
+	return a == b
+}
+
+// redeliver hands the queued batches to a write goroutine writing them to
+// the main writer until one fails. It runs in Run.
+func (s *Service) redeliver() {
+	if s.retryQ == nil {
+		return
+	}
+
+	batches := s.retryQ.take()
+	if batches == nil {
+		return
+	}
+
+	s.writerMx.RLock()
+	w := s.writer
+	s.writerMx.RUnlock()
+
+	s.debugf("redelivering %d batches", len(batches))
+	s.spawn(func() {
+		ctx, cancel := s.flushContext()
+		defer cancel()
+
+		for i, p := range batches {
+			_, err := writeTarget(ctx, target{writer: w, payload: p})
+			if err == nil {
+				err = flushWriter(w)
+			}
+			if err != nil {
+				s.debugf("redelivery: %d batches left: %v", len(batches)-i, err)
+				s.retryQ.putBack(batches[i:])
+				return
+			}
+		}
+
+		s.retryQ.putBack(nil)
+	})
+}
+
+// save writes the queued batches to the queue file, removing it if there
+// are none.
+func (q *retryQueue) save() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if len(q.batches) == 0 {
+		if err := os.Remove(q.path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+
+	tmp := q.path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+
+	bw := bufio.NewWriter(f)
+	bw.WriteString(retryQueueMagic)
+	for _, p := range q.batches {
+		bw.Write(binary.AppendUvarint(nil, uint64(len(p))))
+		bw.Write(p)
+	}
+	err = bw.Flush()
+	if err == nil {
+		err = f.Sync()
+	}
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	return os.Rename(tmp, q.path)
+}
+
+// load queues the batches saved in the queue file, if any. A batch length
+// past the end of the file fails it as corrupt instead of being allocated.
+func (q *retryQueue) load() error {
+	f, err := os.Open(q.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	left := uint64(fi.Size()) - uint64(len(retryQueueMagic))
+
+	br := bufio.NewReader(f)
+	magic := make([]byte, len(retryQueueMagic))
+	if _, err := io.ReadFull(br, magic); err != nil || string(magic) != retryQueueMagic {
+		return fmt.Errorf("asynclog: %s is not a retry queue", q.path)
+	}
+
+	var batches [][]byte
+	for {
+		n, err := binary.ReadUvarint(br)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("asynclog: retry queue %s: %w", q.path, err)
+		}
+
+		left -= uint64(len(binary.AppendUvarint(nil, n)))
+		if n > left {
+			return fmt.Errorf("asynclog: retry queue %s is corrupt: batch of %d bytes with %d left", q.path, n, left)
+		}
+		left -= n
+
+		p := make([]byte, n)
+		if _, err := io.ReadFull(br, p); err != nil {
+			return fmt.Errorf("asynclog: retry queue %s: %w", q.path, err)
+		}
+		batches = append(batches, p)
+	}
+
+	q.putBack(batches)
+
+	return nil
+}
@@ -0,0 +1,66 @@
+package asynclog_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"test-task-log/asynclog"
+	"test-task-log/asynclog/testutil"
+)
+
+func TestRetryQueueSurvivesRestart(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "retry.q")
+
+	down := testutil.NewSink()
+	down.Fail(errors.New("collector down"))
+	s := asynclog.NewService(down, asynclog.WithRetryQueue(path, 10))
+	go s.Run(context.Background())
+	printAll(t, s, "a", "b")
+	s.Shutdown(testContext(t))
+
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("queue not saved: %v", err)
+	}
+
+	up := testutil.NewSink()
+	s = asynclog.NewService(up, asynclog.WithRetryQueue(path, 10))
+	go s.Run(context.Background())
+	if err := s.Shutdown(testContext(t)); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := up.Lines(), []string{"a", "b"}; !slices.Equal(got, want) {
+		t.Fatalf("got lines %q, want %q redelivered", got, want)
+	}
+	if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("queue file left after redelivery: %v", err)
+	}
+}
+
+func TestRetryQueueCorruptLength(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "retry.q")
+	// A length of about 1<<62 followed by a few bytes.
+	corrupt := append([]byte("ALRQ1\n"), 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x3f, 'x')
+	if err := os.WriteFile(path, corrupt, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	s, _, _ := start(t, asynclog.WithRetryQueue(path, 10))
+
+	ctx := testContext(t)
+	for s.Health().LastError == nil {
+		select {
+		case <-ctx.Done():
+			t.Fatal("corrupt queue file loaded without an error")
+		case <-time.After(time.Millisecond):
+		}
+	}
+	if pending, _ := s.RetryQueued(); pending != 0 {
+		t.Fatalf("got %d pending batches from a corrupt file, want 0", pending)
+	}
+}
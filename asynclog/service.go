@@ -0,0 +1,1538 @@
+// Package asynclog batches log records and writes them to an io.Writer
+// asynchronously, so producers never wait on a slow writer.
+package asynclog
+
+import (
+	"bytes"
+	"context"
+	"crypto/cipher"
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type Service struct {
+	writer         io.Writer
+	writerMx       sync.RWMutex
+	sinks          []namedSink
+	retired        []namedSink
+	opened         []io.Writer // opened from a Config, closed once a reload replaces them
+	swapCh         chan swapRequest
+	reloadCh       chan reloadRequest
+	barrierCh      chan chan []chan struct{}
+	flushCh        chan chan flushReply
+	writes         []chan struct{}
+	done           chan struct{}
+	stop           chan struct{}
+	stopOnce       sync.Once
+	queue          *queue
+	queueSize      int
+	burstLimit     int
+	fair           bool
+	compactKeep    Level
+	compact        bool
+	compacted      atomic.Int64
+	priority       int
+	overflow       OverflowPolicy
+	overflowWait   time.Duration
+	dropped        atomic.Int64
+	buffer         *batch
+	levelBuffers   map[Level]*batch
+	bufferMx       sync.Mutex
+	bufferWg       sync.WaitGroup
+	bufferNotifyCh chan struct{}
+	clock          Clock
+	writeEvery     time.Duration
+	writeLimit     int
+	flushTrigger   <-chan struct{}
+	levelEvery     map[Level]time.Duration
+	highWatermark  int
+	lowWatermark   int
+	draining       bool
+	drained        atomic.Bool
+	inflight       atomic.Int64
+	limiter        *tokenBucket
+	levelLimiters  map[Level]*tokenBucket
+	quotas         *quotas
+	audit          *auditChain
+	signKey        ed25519.PrivateKey
+	ulids          *ulidGen
+	raw            bool
+	framed         bool
+	syncEach       bool
+	health         healthState
+	probeEvery     time.Duration
+	probeWg        sync.WaitGroup
+	filter         atomic.Pointer[Expr]
+	level          atomic.Int32
+	maxAge         time.Duration
+	maxBatchBytes  int
+	coalesce       time.Duration
+	coalesceC      <-chan time.Time
+	expired        atomic.Int64
+	trackSeq       atomic.Uint64
+	reports        chan DeliveryReport
+	errorSink      io.Writer
+	errorLevel     Level
+	waitMx         sync.Mutex
+	waiting        map[uint64]chan error // nil once PrintSync stopped waiting
+	roundRobin     bool
+	rrNext         int
+	ingestRate     rateCounter
+	deliverRate    rateCounter
+	sizes          sizeCounter
+	slo            *sloMonitor
+	stuckAfter     time.Duration
+	stuckPolicy    StuckPolicy
+	writeTimeout   time.Duration
+	writeCtxMx     sync.Mutex
+	writeCtx       context.Context
+	cancelWrites   context.CancelFunc
+	retry          retryPolicy
+	retryQ         *retryQueue
+	budget         time.Duration
+	behindMx       sync.Mutex
+	behind         map[io.Writer]int
+	stragglers     sync.WaitGroup
+	failedBatch    func(FailedBatch)
+	shadow         io.Writer
+	shadowStats    *shadowCounters
+	canary         io.Writer
+	canaryBase     io.Writer
+	canaryPercent  float64
+	canaryAcc      float64
+	canaryStats    *shadowCounters
+	verifyReads    bool
+	encodeAhead    bool
+	encodeCh       chan pipelined
+	batchSlots     chan struct{}
+	workers        int
+	jobs           chan func()
+	notice         *failureNotice
+	header         *batchHeader
+	sinkQueue      int
+	encoder        Encoder
+	tenants        map[string]cipher.AEAD
+	shortPolicy    ShortWritePolicy
+	shortWrites    atomic.Int64
+	panicPolicy    PanicPolicy
+	onPanic        func(WriterPanic)
+	writerPanics   atomic.Int64
+	breakAfter     int
+	coolDown       time.Duration
+	diag           *log.Logger
+	events         func(Event)
+	stats          counters
+	statsHook      StatsHook
+	correlation    []CorrelationFormat
+	filters        []Filter
+	hooks          []Hook
+	tracer         Tracer
+	shutdownReport *shutdownReport
+	spill          *spill
+	sampler        *adaptiveSampler
+}
+
+type swapRequest struct {
+	writer io.Writer
+	err    chan error
+}
+
+type record struct {
+	level  Level
+	source string
+	id     string
+	time   time.Time
+	msg    string
+	fields []Field
+	// tracked is the PrintTracked ID, zero for untracked records.
+	tracked uint64
+	// size is the encoded size under WithMaxBatchBytes, zero until measured.
+	size int
+}
+
+// Field is a key/value pair attached to a record.
+type Field struct {
+	Key   string
+	Value any
+}
+
+// line is rec as written to the writer: the message, preceded by the ID if
+// any and followed by the fields as key=value pairs.
+func (rec record) line() string {
+	if rec.id == "" && len(rec.fields) == 0 {
+		return rec.msg
+	}
+
+	var b bytes.Buffer
+	rec.writeLine(&b)
+
+	return b.String()
+}
+
+// writeLine appends rec's line to b.
+func (rec record) writeLine(b *bytes.Buffer) {
+	if rec.id != "" {
+		b.WriteString(rec.id)
+		b.WriteByte(' ')
+	}
+	b.WriteString(rec.msg)
+	for _, f := range rec.fields {
+		b.WriteByte(' ')
+		b.WriteString(f.Key)
+		b.WriteByte('=')
+		switch v := f.Value.(type) {
+		case string:
+			b.WriteString(v)
+		case int:
+			b.Write(strconv.AppendInt(b.AvailableBuffer(), int64(v), 10))
+		case int64:
+			b.Write(strconv.AppendInt(b.AvailableBuffer(), v, 10))
+		case bool:
+			b.Write(strconv.AppendBool(b.AvailableBuffer(), v))
+		default:
+			fmt.Fprint(b, v)
+		}
+	}
+}
+
+// batch is a buffer flushed on its own interval. ticks is the interval
+// expressed in Run ticks, n counts ticks since the last timed flush.
+type batch struct {
+	records []record
+	bytes   int       // encoded size under WithMaxBatchBytes
+	first   time.Time // when the first record went in, under WithCoalescing
+	ticks   int
+	n       int
+}
+
+// Option configures a Service in NewService.
+type Option func(*Service)
+
+// WithFlushInterval sets how often buffered records are written, 5 seconds by
+// default. It panics if d is not positive.
+func WithFlushInterval(d time.Duration) Option {
+	if d <= 0 {
+		panic("asynclog: non-positive flush interval")
+	}
+
+	return func(s *Service) {
+		s.writeEvery = d
+	}
+}
+
+// WithBatchSize sets how many buffered records trigger a write before the
+// interval is up, 10 by default. It panics if n is not positive.
+func WithBatchSize(n int) Option {
+	if n <= 0 {
+		panic("asynclog: non-positive batch size")
+	}
+
+	return func(s *Service) {
+		s.writeLimit = n
+	}
+}
+
+// WithChannelBuffer sets how many records the queue between Print and Run
+// holds before producers block, 1024 by default. It panics if n is not
+// positive.
+func WithChannelBuffer(n int) Option {
+	if n <= 0 {
+		panic("asynclog: non-positive channel buffer")
+	}
+
+	return func(s *Service) {
+		s.queueSize = n
+	}
+}
+
+// WithFlushTrigger makes Run flush the buffer every time a value is received
+// from ch, in addition to the interval and count triggers. Closing ch disables
+// the trigger.
+func WithFlushTrigger(ch <-chan struct{}) Option {
+	return func(s *Service) {
+		s.flushTrigger = ch
+	}
+}
+
+// WithLevelFlushInterval gives records of the given level their own buffer
+// flushed every d instead of the service-wide interval. All buffers are driven
+// by one ticker running at the shortest configured interval, so d is rounded
+// to a multiple of it.
+func WithLevelFlushInterval(level Level, d time.Duration) Option {
+	return func(s *Service) {
+		if s.levelEvery == nil {
+			s.levelEvery = make(map[Level]time.Duration)
+		}
+		s.levelEvery[level] = d
+	}
+}
+
+// WithBurstCapacity lets the queue between Print and Run grow up to limit
+// records while producers outpace Run, instead of blocking them. The queue
+// shrinks back to its normal size once Run catches up.
+func WithBurstCapacity(limit int) Option {
+	return func(s *Service) {
+		s.burstLimit = limit
+	}
+}
+
+// WithMaxInflightBatches bounds the number of batches handed to write
+// goroutines but not yet written to n. Past that flushes wait for a write to
+// finish, so a stalled writer backs the queue up to producers instead of
+// piling unwritten batches up in goroutines under WithWorkers(0).
+func WithMaxInflightBatches(n int) Option {
+	return func(s *Service) {
+		s.batchSlots = make(chan struct{}, max(n, 1))
+	}
+}
+
+// OverflowPolicy is what happens to records pushed into a full queue.
+type OverflowPolicy int
+
+const (
+	// OverflowBlock makes producers wait for space, the default.
+	OverflowBlock OverflowPolicy = iota
+	// OverflowDropNewest drops the record being pushed.
+	OverflowDropNewest
+	// OverflowDropOldest drops the oldest queued record to make room.
+	OverflowDropOldest
+	// OverflowBlockWithTimeout waits for space for up to the timeout, then
+	// drops the records that didn't fit.
+	OverflowBlockWithTimeout
+)
+
+// WithOverflowPolicy sets what happens when the queue between Print and Run
+// is full; timeout is only used by OverflowBlockWithTimeout. Dropped records
+// are counted by Dropped. Compaction, if enabled, is tried first.
+func WithOverflowPolicy(p OverflowPolicy, timeout time.Duration) Option {
+	return func(s *Service) {
+		s.overflow = p
+		s.overflowWait = timeout
+	}
+}
+
+// WithCompaction makes a full queue evict records below keep to make room
+// instead of blocking producers: debug records go first, then info and so
+// on, oldest first, and a record is never evicted for a lower-level one.
+// Evicted records are counted by Compacted.
+func WithCompaction(keep Level) Option {
+	return func(s *Service) {
+		s.compact = true
+		s.compactKeep = keep
+	}
+}
+
+// WithPriorityQueue makes Run take queued records highest level first, in
+// order within a level, whenever at least threshold records are queued, so
+// during a flood errors are written in the first batches instead of waiting
+// behind the backlog. Below the threshold records are taken in order.
+// Records of different levels may then be written out of order.
+func WithPriorityQueue(threshold int) Option {
+	if threshold < 1 {
+		panic(fmt.Sprintf("asynclog: WithPriorityQueue: non-positive threshold %d", threshold))
+	}
+
+	return func(s *Service) {
+		s.priority = threshold
+	}
+}
+
+// WithWatermarks switches Run to flushing on every received record once more
+// than high records are pending (buffered or still being written), and back to
+// the interval and count triggers once fewer than low are. Keeping low well
+// below high avoids flapping between the two modes under sustained load.
+func WithWatermarks(high, low int) Option {
+	return func(s *Service) {
+		s.highWatermark = high
+		s.lowWatermark = min(low, high)
+	}
+}
+
+// WithRateLimit paces Print to perSecond records per second with bursts of up
+// to burst records. Producers over the rate are delayed rather than dropped.
+// It panics if perSecond isn't positive.
+func WithRateLimit(perSecond float64, burst int) Option {
+	checkRate("WithRateLimit", perSecond)
+
+	return func(s *Service) {
+		s.limiter = newTokenBucket(perSecond, burst)
+	}
+}
+
+// WithLevelRateLimit is WithRateLimit for records of one level, replacing the
+// service-wide limit for them.
+func WithLevelRateLimit(level Level, perSecond float64, burst int) Option {
+	checkRate("WithLevelRateLimit", perSecond)
+
+	return func(s *Service) {
+		if s.levelLimiters == nil {
+			s.levelLimiters = make(map[Level]*tokenBucket)
+		}
+		s.levelLimiters[level] = newTokenBucket(perSecond, burst)
+	}
+}
+
+// WithFairQueue gives every source passed to PrintFrom a queue of its own,
+// drained round-robin, so one chatty source can't take over the queue and
+// batches during overload.
+func WithFairQueue() Option {
+	return func(s *Service) {
+		s.fair = true
+	}
+}
+
+// WithSourceQuota limits every source to records records and bytes bytes of
+// messages per interval; zero disables either limit. Records over the quota
+// are dropped and summarised in a warning once the interval is over.
+func WithSourceQuota(records, bytes int, per time.Duration) Option {
+	return func(s *Service) {
+		s.quotas = newQuotas(records, bytes, per)
+	}
+}
+
+// WithAuditChain ends every batch with a trailer line chaining it to the
+// previous batch by hash, so output can be checked with VerifyAuditChain.
+// Batches are written one at a time in flush order, whatever WithWorkers
+// says, so they land in chain order however slow the writer. Lines of records
+// starting with # get another # prepended, so records can't pass for
+// trailers. Batches redelivered later by WithRetryQueue or replayed from
+// WithSpill land out of order and fail verification.
+func WithAuditChain() Option {
+	return func(s *Service) {
+		s.audit = &auditChain{}
+	}
+}
+
+// WithBatchSigning ends every batch with a trailer line holding its ed25519
+// signature by key, checked with VerifyBatchSignatures. Combined with
+// WithAuditChain the signature covers the audit trailer too. Record lines
+// starting with # are escaped as in audit mode.
+func WithBatchSigning(key ed25519.PrivateKey) Option {
+	return func(s *Service) {
+		s.signKey = key
+	}
+}
+
+// WithULID stamps every record with a ULID when it is enqueued, written in
+// front of the message. ULIDs sort by enqueue time, which makes them usable
+// for deduplication and correlation across systems.
+func WithULID() Option {
+	return func(s *Service) {
+		s.ulids = &ulidGen{}
+	}
+}
+
+// WithSyncEachBatch calls Sync on writers implementing it, such as *os.File,
+// after every batch instead of only at shutdown. A failed Sync fails the
+// batch.
+func WithSyncEachBatch() Option {
+	return func(s *Service) {
+		s.syncEach = true
+	}
+}
+
+// WithHealthProbe probes the writer every d, using its Probe method if it
+// implements Prober and a zero-byte write otherwise, so Health reflects the
+// writer status even when nothing is being logged.
+func WithHealthProbe(d time.Duration) Option {
+	return func(s *Service) {
+		s.probeEvery = d
+	}
+}
+
+// WithMinLevel drops records below level before they are queued, like
+// filtered ones.
+func WithMinLevel(level Level) Option {
+	return func(s *Service) {
+		s.level.Store(int32(level))
+	}
+}
+
+// WithFilter drops records not matching e before they are queued. The filter
+// can be changed later with SetFilter.
+func WithFilter(e *Expr) Option {
+	return func(s *Service) {
+		s.filter.Store(e)
+	}
+}
+
+// WithWriter replaces the writer passed to NewService, for constructors such
+// as NewSlog that don't take one.
+func WithWriter(w io.Writer) Option {
+	return func(s *Service) {
+		s.writer = w
+	}
+}
+
+// WithMaxRecordAge drops records that have waited longer than d since they
+// were enqueued by the time their batch is flushed, for consumers that have
+// no use for stale data. Dropped records are counted by Expired.
+func WithMaxRecordAge(d time.Duration) Option {
+	return func(s *Service) {
+		s.maxAge = d
+	}
+}
+
+// WithDeliveryReports sends a DeliveryReport to Reports for every record
+// enqueued with PrintTracked once it is written or dropped. buffer is the
+// capacity of the report channel.
+func WithDeliveryReports(buffer int) Option {
+	return func(s *Service) {
+		s.reports = make(chan DeliveryReport, buffer)
+	}
+}
+
+// WithRoundRobin sends each batch to one of the main writer and the unrouted
+// sinks in turn instead of to all of them, spreading the write load across
+// sinks that lead to the same place.
+func WithRoundRobin() Option {
+	return func(s *Service) {
+		s.roundRobin = true
+	}
+}
+
+// WithSLO calls alert when a threshold of slo is breached and again when it
+// recovers. alert is called synchronously from the flush path and must not
+// block.
+func WithSLO(slo SLO, alert func(SLOAlert)) Option {
+	return func(s *Service) {
+		s.slo = newSLOMonitor(slo, alert)
+	}
+}
+
+func NewService(writer io.Writer, opts ...Option) *Service {
+	s := &Service{
+		writer:         writer,
+		queueSize:      defaultQueueSize,
+		bufferNotifyCh: make(chan struct{}, 1),
+		swapCh:         make(chan swapRequest),
+		reloadCh:       make(chan reloadRequest),
+		barrierCh:      make(chan chan []chan struct{}),
+		flushCh:        make(chan chan flushReply),
+		done:           make(chan struct{}),
+		stop:           make(chan struct{}),
+		clock:          systemClock{},
+		writeEvery:     5 * time.Second, // сливаем логи в writer каждые 5 секунд или 10 записей
+		writeLimit:     10,
+		workers:        1,
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	if s.audit != nil {
+		s.workers = 1
+	}
+
+	s.writeCtx, s.cancelWrites = context.WithCancel(context.Background())
+	s.writer = s.wrap(s.writer)
+	s.addErrorSink()
+	s.shadowStats = &shadowCounters{}
+	s.canaryStats = &shadowCounters{}
+	if s.shadow != nil {
+		s.shadow = s.wrap(s.shadow)
+	}
+	if s.canary != nil {
+		s.canary = s.wrap(s.canary)
+	}
+	s.queue = newQueue(s.queueSize, s.burstLimit, s.fair)
+	s.queue.overflow = s.overflow
+	s.queue.timeout = s.overflowWait
+	s.queue.clock = s.clock
+	s.queue.dropped = s.overflowed
+	if s.spill != nil {
+		if s.queue.overflow == OverflowBlock {
+			s.queue.overflow = OverflowDropNewest
+		}
+		s.queue.dropped = s.spillOverflow
+	}
+	s.queue.priority = s.priority
+	if s.compact {
+		s.queue.compact = true
+		s.queue.keep = s.compactKeep
+		s.queue.evicted = s.evicted
+	}
+	s.buffer = &batch{}
+	for level, every := range s.levelEvery {
+		if every > 0 {
+			if s.levelBuffers == nil {
+				s.levelBuffers = make(map[Level]*batch)
+			}
+			s.levelBuffers[level] = &batch{}
+		}
+	}
+
+	return s
+}
+
+// tick returns the Run ticker period and sets up every buffer's interval in
+// ticks. Run calls it again on every tick to pick up sink batching changes.
+func (s *Service) tick() time.Duration {
+	s.writerMx.RLock()
+	defer s.writerMx.RUnlock()
+
+	tick := s.writeEvery
+	for level := range s.levelBuffers {
+		tick = min(tick, s.levelEvery[level])
+	}
+	for _, sk := range s.sinks {
+		if sk.staged != nil {
+			tick = min(tick, sk.staged.every)
+		}
+	}
+
+	s.buffer.ticks = max(1, int((s.writeEvery+tick/2)/tick))
+	for level, b := range s.levelBuffers {
+		b.ticks = max(1, int((s.levelEvery[level]+tick/2)/tick))
+	}
+	for _, sk := range s.sinks {
+		if sk.staged != nil {
+			sk.staged.ticks = max(1, int((sk.staged.every+tick/2)/tick))
+		}
+	}
+
+	return tick
+}
+
+func (s *Service) bufferFor(level Level) *batch {
+	if b, ok := s.levelBuffers[level]; ok {
+		return b
+	}
+
+	return s.buffer
+}
+
+// buffers returns all buffers, per-level ones in level order and the shared
+// one last.
+func (s *Service) buffers() []*batch {
+	bs := make([]*batch, 0, len(s.levelBuffers)+1)
+	for level := LevelDebug; level <= LevelError; level++ {
+		if b, ok := s.levelBuffers[level]; ok {
+			bs = append(bs, b)
+		}
+	}
+
+	return append(bs, s.buffer)
+}
+
+// Run
+// У нас есть некий сервис логов. Он принимает логи из разных источников через метод Print и пишет их в io.Writer.
+// Проблема в том что io.Writer может не успевать записывать логи так быстро как они пуступают в метод Print.
+// Необходимо реализовать сервис таким образом чтобы запись в Print была наиболее быстрой и не была связана с замедленной записью в io.Writer.
+// - писать в io.Writer необходимо или каждые 5 секунд или когда накопится 10 записей
+// - в io.Writer можно писать весь буфер который доступен в данный момент, но не писать по одной записи
+// - Run должен завершаться после закрытия контекста и после завершения всех го-рутин которые он создал
+// - после закрытия контекста, если буфер не пустой, его необходимо записать в io.Writer
+// - можно добавлять свои методы и поля в Service
+func (s *Service) Run(ctx context.Context) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	tick := s.tick()
+	t := s.clock.NewTicker(tick)
+	defer t.Stop()
+	defer close(s.done)
+
+	trigger := s.flushTrigger
+
+	if s.workers > 0 {
+		stop := s.startWorkers()
+		defer stop()
+	}
+
+	if s.encodeAhead {
+		stop := s.startPipeline()
+		defer stop()
+	}
+
+	if s.retryQ != nil {
+		if err := s.retryQ.load(); err != nil {
+			s.debugf("%v", err)
+			s.health.set(err)
+		}
+	}
+
+	if s.spill != nil {
+		if err := s.spill.load(); err != nil {
+			s.debugf("%v", err)
+			s.health.set(err)
+		}
+	}
+
+	if s.probeEvery > 0 {
+		s.probeWg.Add(1)
+		go func() {
+			s.probeLoop(ctx)
+			s.probeWg.Done()
+		}()
+	}
+
+	for {
+		// An open circuit leaves records in the queue, see
+		// WithCircuitBreaker.
+		ready := s.queue.ready
+		if s.holding() {
+			ready = nil
+		}
+
+		select {
+		case <-ctx.Done():
+			s.shutdown()
+			return
+
+		case <-s.stop:
+			cancel()
+			s.shutdown()
+			return
+
+		case <-ready:
+			s.addQueued()
+
+			if s.watermark() {
+				s.flush("watermark", s.buffers()...)
+			}
+
+		case <-s.bufferNotifyCh:
+			s.flush("trigger", s.buffers()...)
+
+		case <-s.coalesceC:
+			s.coalesced()
+
+		case now := <-t.C():
+			if d := s.tick(); d != tick {
+				tick = d
+				t.Reset(tick)
+			}
+
+			if s.slo != nil {
+				s.slo.observePending(s.pendingAge(now), now)
+			}
+
+			if s.quotas != nil {
+				for _, rec := range s.quotas.summaries(now) {
+					s.add(rec)
+				}
+			}
+
+			for _, b := range s.buffers() {
+				b.n++
+				if b.n >= b.ticks {
+					b.n = 0
+					s.flush("interval", b)
+				}
+			}
+			s.tickSinks()
+			s.redeliver()
+			s.replay()
+
+		case req := <-s.barrierCh:
+			req <- s.barrier()
+
+		case req := <-s.flushCh:
+			req <- s.flushNow()
+
+		case req := <-s.swapCh:
+			req.err <- s.swap(req.writer)
+
+		case req := <-s.reloadCh:
+			req.err <- s.reload(req)
+			if d := s.tick(); d != tick {
+				tick = d
+				t.Reset(tick)
+			}
+
+		case _, ok := <-trigger:
+			if !ok {
+				trigger = nil
+				continue
+			}
+
+			s.event(EventTrigger, "", nil)
+			s.notify()
+		}
+	}
+
+}
+
+// shutdown writes everything still queued or buffered once Run is done, and
+// waits for every write to complete.
+func (s *Service) shutdown() {
+	s.beginReport()
+	s.queue.close()
+	recs := s.collect()
+	s.buffer.records = append(s.buffer.records, recs...)
+	for _, rec := range recs {
+		s.stage(rec)
+	}
+
+	s.probeWg.Wait()
+	s.bufferWg.Wait()
+	if recs := s.take(s.buffers()...); len(recs) > 0 {
+		s.debugf("shutdown: writing %d records", len(recs))
+		s.event(EventFlush, "shutdown", recs)
+		n := int64(len(recs))
+		s.inflight.Add(n)
+		s.sendAll(recs)
+		s.inflight.Add(-n)
+	}
+	s.flushSinks()
+	s.bufferWg.Wait()
+	s.stragglers.Wait()
+	if s.retryQ != nil {
+		s.redeliver()
+		s.bufferWg.Wait()
+		if err := s.retryQ.save(); err != nil {
+			s.debugf("saving the retry queue: %v", err)
+		}
+	}
+	syncWriter(s.currentWriter())
+	s.closeSinks()
+	if s.spill != nil {
+		s.spill.close()
+	}
+	s.endReport()
+}
+
+// add buffers rec, flushing its buffer once it reaches the limit, and stages
+// it for the sinks batched on their own.
+func (s *Service) add(rec record) {
+	s.stage(rec)
+
+	b := s.bufferFor(rec.level)
+	if s.maxBatchBytes > 0 {
+		n := s.recordSize(&rec)
+		if len(b.records) > 0 && b.bytes+n > s.maxBatchBytes {
+			s.flush("bytes", b)
+		}
+		b.bytes += n
+	}
+	if s.coalesce > 0 && len(b.records) == 0 {
+		b.first = s.clock.Now()
+	}
+	if b.records == nil {
+		b.records = make([]record, 0, min(s.writeLimit, s.queueSize))
+	}
+	b.records = append(b.records, rec)
+
+	switch {
+	case len(b.records) >= s.writeLimit && !s.coalescing(b):
+		s.flush("count", b)
+	case s.maxBatchBytes > 0 && b.bytes >= s.maxBatchBytes:
+		s.flush("bytes", b)
+	}
+}
+
+// collect takes records still queued at shutdown, ignoring per-level buffers
+// since everything is written at once anyway.
+func (s *Service) collect() []record {
+	return s.queue.pop()
+}
+
+// watermark reports whether Run is in continuous flushing mode, entering or
+// leaving it as the number of pending records crosses the watermarks.
+func (s *Service) watermark() bool {
+	if s.highWatermark <= 0 {
+		return false
+	}
+
+	pending := int(s.inflight.Load())
+	for _, b := range s.buffers() {
+		pending += len(b.records)
+	}
+
+	switch {
+	case !s.draining && pending > s.highWatermark:
+		s.draining = true
+	case s.draining && pending < s.lowWatermark:
+		s.draining = false
+	}
+
+	return s.draining
+}
+
+// notify schedules a flush without blocking the Run loop. Triggers that fire
+// while a flush is already scheduled are coalesced into it.
+func (s *Service) notify() {
+	select {
+	case s.bufferNotifyCh <- struct{}{}:
+	default:
+	}
+}
+
+// take empties bs and returns their records, less those over the maximum
+// age.
+func (s *Service) take(bs ...*batch) []record {
+	// The first buffer's records are taken as they are, so flushing a
+	// single buffer copies nothing.
+	var recs []record
+	for _, b := range bs {
+		if recs == nil {
+			recs = b.records
+		} else {
+			recs = append(recs, b.records...)
+		}
+		b.records, b.bytes = nil, 0
+	}
+
+	if s.maxAge > 0 {
+		now := s.clock.Now()
+		var stale []record
+		fresh := recs[:0]
+		for _, rec := range recs {
+			if now.Sub(rec.time) <= s.maxAge {
+				fresh = append(fresh, rec)
+			} else {
+				stale = append(stale, rec)
+			}
+		}
+
+		if len(stale) > 0 {
+			s.debugf("dropping %d records over the maximum age", len(stale))
+			s.expired.Add(int64(len(stale)))
+			s.reportDropped(stale)
+			s.event(EventDrop, "expired", stale)
+		}
+		recs = fresh
+	}
+
+	return recs
+}
+
+// evicted counts records the queue evicted to make room. It runs in the
+// producer that caused the eviction.
+func (s *Service) evicted(recs []record) {
+	s.compacted.Add(int64(len(recs)))
+	s.report(trackedIDs(recs), Dropped, nil)
+	s.event(EventDrop, "compaction", recs)
+}
+
+// overflowed counts records the overflow policy dropped. It runs in the
+// producer that pushed into the full queue.
+func (s *Service) overflowed(recs []record) {
+	s.dropped.Add(int64(len(recs)))
+	s.report(trackedIDs(recs), Dropped, nil)
+	s.event(EventDrop, "overflow", recs)
+}
+
+// Dropped returns the number of records dropped by the overflow policy.
+func (s *Service) Dropped() int64 {
+	return s.dropped.Load()
+}
+
+// Compacted returns the number of records evicted from a full queue under
+// WithCompaction.
+func (s *Service) Compacted() int64 {
+	return s.compacted.Load()
+}
+
+// Expired returns the number of records dropped for being older than the
+// WithMaxRecordAge limit.
+func (s *Service) Expired() int64 {
+	return s.expired.Load()
+}
+
+// encode turns recs into the payload of one write, sealing the records of
+// tenants with a key. With escape set the lines of plain records starting
+// with # are escaped, leaving the tenant blocks as they are.
+func (s *Service) encode(recs []record, escape bool) ([]byte, error) {
+	if s.tenants == nil || s.framed || s.raw {
+		payload, err := s.encodeRecords(recs)
+		if err != nil || !escape {
+			return payload, err
+		}
+		return escapeTrailers(payload), nil
+	}
+
+	plain, tenants := s.splitTenants(recs)
+
+	var payload []byte
+	if len(plain) > 0 {
+		var err error
+		if payload, err = s.encodeRecords(plain); err != nil {
+			return nil, err
+		}
+		if escape {
+			payload = escapeTrailers(payload)
+		}
+	}
+
+	return s.sealTenants(payload, tenants)
+}
+
+func (s *Service) encodeRecords(recs []record) ([]byte, error) {
+	switch {
+	case s.framed:
+		return encodeFrame(recs), nil
+	case s.encoder != nil:
+		entries := getEntries(recs)
+		defer putEntries(entries)
+		return s.encoder.Encode(*entries)
+	}
+
+	b := getBuffer()
+	defer putBuffer(b)
+	for _, rec := range recs {
+		rec.writeLine(b)
+		if !s.raw {
+			b.WriteByte('\n')
+		}
+	}
+
+	return bytes.Clone(b.Bytes()), nil
+}
+
+// target is a payload and the writer it goes to.
+type target struct {
+	writer  io.Writer
+	payload []byte
+	// counts records the outcome of the write for shadow and canary
+	// comparisons. Errors from shadow targets don't fail the batch.
+	counts []outcomeCount
+	shadow bool
+	// retryTo is the main writer, set under WithRetryQueue on the target
+	// whose failed payloads are queued for it.
+	retryTo io.Writer
+}
+
+// outcomeCount is where to count a write outcome, and whether as the
+// candidate writer's or the current one's.
+type outcomeCount struct {
+	c         *shadowCounters
+	candidate bool
+}
+
+// targets encodes recs for the main writer, together with the sinks that have
+// no route, and for every routed sink matching some of them. The audit chain
+// and signatures only cover the main payload. It runs in Run, or with Run
+// waiting on it, or in the encode stage of WithEncodeAhead, Run only calling
+// it itself once the pipeline is empty. If any payload fails to encode the
+// batch has no targets.
+func (s *Service) targets(recs []record) ([]target, error) {
+	s.writerMx.RLock()
+	defer s.writerMx.RUnlock()
+
+	payload, err := s.encode(recs, (s.audit != nil || s.header != nil || s.signKey != nil || s.tenants != nil) && !s.framed)
+	if err != nil {
+		return nil, err
+	}
+	if s.header != nil && !s.framed && !s.raw {
+		payload = append(s.header.line(recs), payload...)
+	}
+	if s.audit != nil && !s.framed {
+		payload = s.audit.seal(payload)
+	}
+	if s.signKey != nil && !s.framed {
+		payload = signBatch(s.signKey, payload)
+	}
+
+	primary := target{writer: s.writer, payload: payload}
+	if s.canary != nil {
+		canary := s.pickCanary()
+		if canary {
+			primary.writer = s.canary
+		}
+		primary.counts = append(primary.counts, outcomeCount{c: s.canaryStats, candidate: canary})
+	}
+
+	ws := []io.Writer{primary.writer}
+	var ts []target
+	for _, sk := range s.sinks {
+		if sk.staged != nil {
+			continue
+		}
+		if sk.route == nil {
+			ws = append(ws, sk.writer)
+			continue
+		}
+
+		var matched []record
+		for i := range recs {
+			if sk.route.match(&recs[i]) {
+				matched = append(matched, recs[i])
+			}
+		}
+		if len(matched) > 0 {
+			p, err := s.encode(matched, false)
+			if err != nil {
+				return nil, err
+			}
+			ts = append(ts, target{writer: sk.writer, payload: p})
+		}
+	}
+
+	main := primary
+	switch {
+	case len(ws) > 1 && s.roundRobin:
+		main.writer = ws[s.rrNext%len(ws)]
+		s.rrNext++
+	case len(ws) > 1 && s.budget > 0:
+		for _, w := range ws[1:] {
+			ts = append(ts, target{writer: w, payload: payload})
+		}
+	case len(ws) > 1:
+		main.writer = NewMultiWriter(ws...)
+	}
+	if _, combined := main.writer.(*MultiWriter); s.retryQ != nil && sameWriter(primary.writer, s.writer) &&
+		(combined || sameWriter(main.writer, s.writer)) {
+		main.retryTo = s.writer
+	}
+
+	if s.shadow != nil {
+		main.counts = append(main.counts, outcomeCount{c: s.shadowStats})
+		ts = append(ts, target{
+			writer:  s.shadow,
+			payload: payload,
+			counts:  []outcomeCount{{c: s.shadowStats, candidate: true}},
+			shadow:  true,
+		})
+	}
+
+	return append([]target{main}, ts...), nil
+}
+
+// outgoing is a batch ready to be written, or the error encoding it.
+type outgoing struct {
+	targets []target
+	err     error
+	ids     []uint64
+	records int
+	// recs are the records, kept to be spilled if the write fails.
+	recs []record
+}
+
+// prepare encodes recs into an outgoing batch. Like targets it runs in Run.
+func (s *Service) prepare(recs []record) outgoing {
+	ts, err := s.targets(recs)
+
+	o := outgoing{targets: ts, err: err, ids: trackedIDs(recs), records: len(recs)}
+	if s.spill != nil && s.retryQ == nil {
+		o.recs = recs
+	}
+
+	return o
+}
+
+// send delivers o, reporting tracked records and counting what was written.
+func (s *Service) send(o outgoing) error {
+	ctx, cancel := s.flushContext()
+	defer cancel()
+
+	ctx, span := s.startSpan(ctx, "asynclog.flush",
+		Field{Key: "records", Value: o.records}, Field{Key: "targets", Value: len(o.targets)})
+	start := s.clock.Now()
+	err := o.err
+	if err == nil {
+		err = s.deliver(ctx, o.targets)
+	} else {
+		s.health.set(err)
+	}
+	span.End(err)
+	end := s.clock.Now()
+	latency := end.Sub(start)
+	s.slo.observeFlush(latency, end)
+	s.notice.observe(err, end)
+	s.reportWrite(o.ids, err)
+	if err != nil && o.recs != nil {
+		s.spillFailed(o.recs)
+	}
+
+	bytes := 0
+	if err == nil {
+		bytes = len(o.targets[0].payload)
+		s.deliverRate.add(s.clock.Now(), o.records, bytes)
+	}
+	s.written(o.records, bytes, latency, err)
+	s.debugf("batch of %d records to %d targets written in %s, err=%v",
+		o.records, len(o.targets), latency, err)
+
+	return err
+}
+
+// flush hands the records of bs to a write goroutine; reason is what
+// triggered it, for diagnostics.
+func (s *Service) flush(reason string, bs ...*batch) {
+	if reason != "barrier" && s.holding() {
+		return
+	}
+
+	recs := s.take(bs...)
+	if len(recs) == 0 {
+		return
+	}
+	s.debugf("flush (%s): %d records", reason, len(recs))
+	s.event(EventFlush, reason, recs)
+
+	for _, part := range s.split(recs) {
+		if s.encodeCh != nil {
+			s.pipeline(part, nil, false)
+			continue
+		}
+
+		o := s.prepare(part)
+		n := int64(o.records)
+
+		s.inflight.Add(n)
+		s.spawn(func() {
+			s.send(o)
+			s.inflight.Add(-n)
+		})
+	}
+}
+
+// spawn runs write on a worker, or in a goroutine of its own under
+// WithWorkers(0), tracked for barriers and shutdown, waiting for a slot under
+// WithMaxInflightBatches.
+func (s *Service) spawn(write func()) {
+	if s.batchSlots != nil {
+		s.batchSlots <- struct{}{}
+	}
+
+	done := make(chan struct{})
+	s.pruneWrites()
+	s.writes = append(s.writes, done)
+
+	s.bufferWg.Add(1)
+	job := func() {
+		write()
+		if s.batchSlots != nil {
+			<-s.batchSlots
+		}
+		close(done)
+		s.bufferWg.Done()
+	}
+
+	if s.jobs != nil {
+		s.jobs <- job
+		return
+	}
+	go job()
+}
+
+// deliver writes every target, carrying on past failing ones, and flushes
+// writers that buffer internally so data doesn't sit in a layer below the
+// service.
+func (s *Service) deliver(ctx context.Context, ts []target) error {
+	if s.budget > 0 {
+		return s.deliverWithin(ctx, ts)
+	}
+
+	var errs []error
+	for _, t := range ts {
+		if err := s.deliverTarget(ctx, t); err != nil && !t.shadow {
+			errs = append(errs, err)
+		}
+	}
+
+	err := errors.Join(errs...)
+	s.health.set(err)
+
+	return err
+}
+
+// deliverTarget writes t, counting the outcome and handing the payload to
+// the failed batch handler if it fails, less what a partial write got out.
+func (s *Service) deliverTarget(ctx context.Context, t target) error {
+	wctx, span := s.startSpan(ctx, "asynclog.write",
+		Field{Key: "bytes", Value: len(t.payload)}, Field{Key: "shadow", Value: t.shadow})
+	attempts, unwritten, err := s.writeRetrying(wctx, t)
+	if err == nil && s.syncEach {
+		err = syncWriter(t.writer)
+	}
+	if len(unwritten) == 0 {
+		// Only the flush or sync failed: the writer may not have kept
+		// anything.
+		unwritten = t.payload
+	}
+	span.End(err)
+	if !t.shadow {
+		s.shutdownReport.observe(t.writer, s.clock.Now(), err)
+	}
+	for _, oc := range t.counts {
+		oc.c.count(oc.candidate, err)
+	}
+	if err != nil && !t.shadow && s.failedBatch != nil {
+		s.failedBatch(FailedBatch{Writer: t.writer, Payload: unwritten, Attempts: attempts, Err: err})
+	}
+	if err != nil && t.retryTo != nil {
+		t.payload = unwritten
+		s.queueFailed(t, err)
+	}
+
+	return err
+}
+
+// currentWriter returns the writer batches go to: the main writer, or the
+// main writer and the added sinks combined.
+func (s *Service) currentWriter() io.Writer {
+	s.writerMx.RLock()
+	defer s.writerMx.RUnlock()
+
+	if len(s.sinks) == 0 {
+		return s.writer
+	}
+
+	ws := make([]io.Writer, 0, len(s.sinks)+1)
+	ws = append(ws, s.writer)
+	for _, sk := range s.sinks {
+		ws = append(ws, sk.writer)
+	}
+
+	return NewMultiWriter(ws...)
+}
+
+// SetWriter replaces the writer of a running service. Everything queued or
+// buffered so far is written to the old writer first, and SetWriter returns once that is
+// done, with the error of the final write to the old writer if any.
+func (s *Service) SetWriter(w io.Writer) error {
+	req := swapRequest{writer: s.wrap(w), err: make(chan error, 1)}
+
+	select {
+	case s.swapCh <- req:
+		return <-req.err
+	case <-s.done:
+		return ErrClosed
+	}
+}
+
+// swap runs in Run, between flushes.
+func (s *Service) swap(w io.Writer) error {
+	s.addQueued()
+	recs := s.take(s.buffers()...)
+	s.bufferWg.Wait()
+
+	var err error
+	s.debugf("swapping writer, %d records left for the old one", len(recs))
+	if len(recs) > 0 {
+		s.event(EventFlush, "swap", recs)
+		err = s.sendAll(recs)
+	}
+	if serr := syncWriter(s.currentWriter()); err == nil {
+		err = serr
+	}
+
+	s.writerMx.Lock()
+	s.writer = w
+	s.writerMx.Unlock()
+
+	return err
+}
+
+// Print enqueues log at LevelInfo. It waits while the queue is full, as
+// the overflow policy says, but no longer than ctx. It returns nil if log was
+// queued or filtered out, ErrClosed once the service is draining or shut
+// down, ErrTimeout wrapping ctx.Err() if ctx ended first, and ErrQueueFull if
+// the overflow policy dropped it, or spilled it under WithSpill.
+func (s *Service) Print(ctx context.Context, log string) error {
+	return s.enqueue(ctx, record{level: LevelInfo, msg: log})
+}
+
+// PrintLevel is Print with the given level, which selects the buffer and
+// flush interval log goes through.
+func (s *Service) PrintLevel(ctx context.Context, level Level, log string) error {
+	return s.PrintFrom(ctx, "", level, log)
+}
+
+// PrintFrom is PrintLevel for records coming from a named source, which only
+// matters with WithFairQueue.
+func (s *Service) PrintFrom(ctx context.Context, source string, level Level, log string) error {
+	return s.enqueue(ctx, record{level: level, source: source, msg: log})
+}
+
+// enqueue puts rec through filtering and rate limiting and queues it,
+// returning why it wasn't queued as Print documents.
+func (s *Service) enqueue(ctx context.Context, rec record) error {
+	if s.drained.Load() {
+		return ErrClosed
+	}
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("%w: %w", ErrTimeout, err)
+	}
+
+	if !s.accept(&rec) {
+		if rec.tracked != 0 {
+			s.report([]uint64{rec.tracked}, Dropped, nil)
+		}
+		return nil
+	}
+
+	if l := s.limiterFor(rec.level); l != nil && !l.wait(ctx, s.clock, 1) {
+		return fmt.Errorf("%w: %w", ErrTimeout, ctx.Err())
+	}
+
+	s.stamp(&rec)
+	s.correlate(ctx, &rec)
+	s.enrich(ctx, &rec)
+	if err := s.queue.push(ctx, rec); err != nil {
+		return timeoutErr(ctx, err)
+	}
+
+	s.event(EventEnqueue, "", []record{rec})
+
+	now := s.clock.Now()
+	s.ingestRate.add(now, 1, len(rec.msg))
+	s.sizes.add(now, len(rec.msg))
+
+	return nil
+}
+
+// PrintAll enqueues logs at LevelInfo with a single queue operation, for
+// callers that already produce records in batches. It returns nil if every
+// record was queued or filtered out, and otherwise what Print returns for
+// the ones that weren't.
+func (s *Service) PrintAll(ctx context.Context, logs []string) error {
+	recs := make([]record, len(logs))
+	for i, log := range logs {
+		recs[i] = record{level: LevelInfo, msg: log}
+	}
+
+	return s.enqueueAll(ctx, recs)
+}
+
+// enqueueAll is enqueue for many records at once. Rate limits are waited
+// for once per limiter rather than once per record.
+func (s *Service) enqueueAll(ctx context.Context, recs []record) error {
+	if s.drained.Load() {
+		return ErrClosed
+	}
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("%w: %w", ErrTimeout, err)
+	}
+
+	accepted := recs[:0]
+	tokens := make(map[*tokenBucket]int)
+	for _, rec := range recs {
+		if !s.accept(&rec) {
+			continue
+		}
+
+		if l := s.limiterFor(rec.level); l != nil {
+			tokens[l]++
+		}
+		accepted = append(accepted, rec)
+	}
+
+	if !waitAll(ctx, s.clock, tokens) {
+		return fmt.Errorf("%w: %w", ErrTimeout, ctx.Err())
+	}
+
+	for i := range accepted {
+		s.stamp(&accepted[i])
+		s.correlate(ctx, &accepted[i])
+		s.enrich(ctx, &accepted[i])
+	}
+
+	n := s.queue.pushAll(ctx, accepted)
+	if n > 0 {
+		queued := accepted[:n]
+		s.event(EventEnqueue, "", queued)
+
+		now := s.clock.Now()
+		bytes := 0
+		for _, rec := range queued {
+			bytes += len(rec.msg)
+			s.sizes.add(now, len(rec.msg))
+		}
+
+		s.ingestRate.add(now, len(queued), bytes)
+	}
+	if n < len(accepted) {
+		return timeoutErr(ctx, s.queue.pushErr(ctx))
+	}
+
+	return nil
+}
+
+// timeoutErr wraps err, a push error, in ErrTimeout if it is ctx's.
+func timeoutErr(ctx context.Context, err error) error {
+	if err != nil && err == ctx.Err() {
+		return fmt.Errorf("%w: %w", ErrTimeout, err)
+	}
+
+	return err
+}
+
+// accept applies the level threshold, the filter, the filter chain,
+// sampling and the source quotas to rec, counting the records turned away.
+func (s *Service) accept(rec *record) bool {
+	if rec.level < s.Level() {
+		s.stats.filtered.Add(1)
+		return false
+	}
+
+	if f := s.filter.Load(); f != nil {
+		// Matched on a copy, so rec stays on the caller's stack when there
+		// is no filter.
+		r := *rec
+		if !f.match(&r) {
+			s.stats.filtered.Add(1)
+			return false
+		}
+	}
+
+	if len(s.filters) > 0 {
+		e := rec.entry()
+		for _, f := range s.filters {
+			if !f.Keep(e) {
+				s.stats.filtered.Add(1)
+				return false
+			}
+		}
+	}
+
+	if s.sampler != nil && !s.sampler.keep(s.clock.Now(), rec.source, rec.level, s.pressure) {
+		s.stats.sampled.Add(1)
+		return false
+	}
+
+	// The quotas count their own drops.
+	if s.quotas != nil && !s.quotas.allow(s.clock.Now(), rec.source, len(rec.msg)) {
+		return false
+	}
+
+	return true
+}
+
+// stamp sets what is assigned to rec at enqueue time. Records that already
+// carry a time, such as ingested ones, keep it.
+func (s *Service) stamp(rec *record) {
+	now := s.clock.Now()
+	if rec.time.IsZero() {
+		rec.time = now
+	}
+
+	if s.ulids != nil {
+		rec.id = s.ulids.next(now)
+	}
+}
+
+// SetFilter replaces the filter set with WithFilter; nil removes it.
+func (s *Service) SetFilter(e *Expr) {
+	s.filter.Store(e)
+}
+
+// SetLevel drops records below level before they are queued, from now on.
+func (s *Service) SetLevel(level Level) {
+	s.level.Store(int32(level))
+}
+
+// Level returns the service's minimum level, LevelDebug unless set with
+// WithMinLevel or SetLevel.
+func (s *Service) Level() Level {
+	return Level(s.level.Load())
+}
+
+func (s *Service) limiterFor(level Level) *tokenBucket {
+	if l, ok := s.levelLimiters[level]; ok {
+		return l
+	}
+
+	return s.limiter
+}
@@ -0,0 +1,564 @@
+package asynclog_test
+
+import (
+	"context"
+	"errors"
+	"math"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"test-task-log/asynclog"
+	"test-task-log/asynclog/testutil"
+)
+
+// start runs a service writing to a recording sink on a fake clock, returning
+// once Run's ticker is up. The service is shut down at the end of the test.
+func start(t *testing.T, opts ...asynclog.Option) (*asynclog.Service, *testutil.Sink, *testutil.Clock) {
+	t.Helper()
+
+	clock := testutil.NewClock(time.Time{})
+	sink := testutil.NewSink()
+	s := asynclog.NewService(sink, append([]asynclog.Option{asynclog.WithClock(clock)}, opts...)...)
+
+	done := make(chan struct{})
+	go func() {
+		s.Run(context.Background())
+		close(done)
+	}()
+	t.Cleanup(func() {
+		s.Shutdown(context.Background())
+		<-done
+	})
+
+	if err := clock.WaitTickers(testContext(t), 1); err != nil {
+		t.Fatal(err)
+	}
+
+	return s, sink, clock
+}
+
+func testContext(t *testing.T) context.Context {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	t.Cleanup(cancel)
+
+	return ctx
+}
+
+func printAll(t *testing.T, s *asynclog.Service, msgs ...string) {
+	t.Helper()
+
+	for _, msg := range msgs {
+		if err := s.Print(context.Background(), msg); err != nil {
+			t.Fatalf("Print(%q): %v", msg, err)
+		}
+	}
+}
+
+// waitBuffered waits until Run has taken every queued record into its
+// buffers, so that advancing the clock afterwards flushes them.
+func waitBuffered(t *testing.T, s *asynclog.Service) {
+	t.Helper()
+
+	ctx := testContext(t)
+	for s.Stats().Queued > 0 {
+		select {
+		case <-ctx.Done():
+			t.Fatalf("%d records still queued", s.Stats().Queued)
+		case <-time.After(time.Millisecond):
+		}
+	}
+}
+
+func TestFlushOnInterval(t *testing.T) {
+	s, sink, clock := start(t, asynclog.WithFlushInterval(5*time.Second))
+
+	printAll(t, s, "a", "b", "c")
+	waitBuffered(t, s)
+
+	clock.Advance(4 * time.Second)
+	clock.Advance(time.Second)
+	if err := sink.WaitWrites(testContext(t), 1); err != nil {
+		t.Fatal(err)
+	}
+
+	if writes := sink.Writes(); len(writes) != 1 {
+		t.Fatalf("got %d writes, want 1", len(writes))
+	}
+	if got, want := sink.Lines(), []string{"a", "b", "c"}; !slices.Equal(got, want) {
+		t.Fatalf("got lines %q, want %q", got, want)
+	}
+}
+
+func TestFlushOnBatchSize(t *testing.T) {
+	s, sink, _ := start(t, asynclog.WithFlushInterval(time.Hour), asynclog.WithBatchSize(3))
+
+	printAll(t, s, "a", "b", "c")
+	if err := sink.WaitLines(testContext(t), 3); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := sink.Lines(), []string{"a", "b", "c"}; !slices.Equal(got, want) {
+		t.Fatalf("got lines %q, want %q", got, want)
+	}
+}
+
+func TestShutdownDrains(t *testing.T) {
+	s, sink, _ := start(t, asynclog.WithFlushInterval(time.Hour))
+
+	printAll(t, s, "a", "b")
+	if err := s.Shutdown(testContext(t)); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := sink.Lines(), []string{"a", "b"}; !slices.Equal(got, want) {
+		t.Fatalf("got lines %q, want %q", got, want)
+	}
+	if err := s.Print(context.Background(), "late"); err != asynclog.ErrClosed {
+		t.Fatalf("Print after Shutdown: got %v, want ErrClosed", err)
+	}
+}
+
+func TestSourceQuotaSummary(t *testing.T) {
+	s, sink, clock := start(t, asynclog.WithFlushInterval(5*time.Second), asynclog.WithSourceQuota(1, 0, time.Minute))
+
+	for _, msg := range []string{"a", "b", "c"} {
+		if err := s.PrintFrom(context.Background(), "app", asynclog.LevelInfo, msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	waitBuffered(t, s)
+
+	clock.Advance(time.Minute)
+	if err := sink.WaitLines(testContext(t), 2); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := sink.Lines()
+	if len(lines) != 2 || lines[0] != "a" || !strings.Contains(lines[1], `source "app" over quota: dropped 2 records`) {
+		t.Fatalf("got lines %q, want a and the quota summary", lines)
+	}
+}
+
+func TestStatsCountsRejected(t *testing.T) {
+	s, _, _ := start(t, asynclog.WithMinLevel(asynclog.LevelInfo), asynclog.WithSourceQuota(1, 0, time.Minute))
+
+	ctx := context.Background()
+	for _, level := range []asynclog.Level{asynclog.LevelDebug, asynclog.LevelInfo, asynclog.LevelInfo} {
+		if err := s.PrintLevel(ctx, level, "m"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	st := s.Stats()
+	if st.Filtered != 1 || st.OverQuota != 1 || st.Received != 1 {
+		t.Fatalf("got %d filtered, %d over quota, %d received, want 1 each", st.Filtered, st.OverQuota, st.Received)
+	}
+	if n := st.RecordSizes.Counts[0]; n != 1 {
+		t.Fatalf("got %d records in the smallest size bucket, want 1", n)
+	}
+}
+
+func TestFlushTrigger(t *testing.T) {
+	trigger := make(chan struct{})
+	s, sink, clock := start(t, asynclog.WithFlushInterval(time.Hour), asynclog.WithFlushTrigger(trigger))
+
+	printAll(t, s, "a", "b")
+	waitBuffered(t, s)
+	trigger <- struct{}{}
+	if err := sink.WaitLines(testContext(t), 2); err != nil {
+		t.Fatal(err)
+	}
+
+	// A closed trigger is ignored, leaving the interval.
+	close(trigger)
+	printAll(t, s, "c")
+	waitBuffered(t, s)
+	if got := len(sink.Lines()); got != 2 {
+		t.Fatalf("got %d lines before the interval, want 2", got)
+	}
+	clock.Advance(time.Hour)
+	if err := sink.WaitLines(testContext(t), 3); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLevelFlushInterval(t *testing.T) {
+	s, sink, clock := start(t, asynclog.WithFlushInterval(time.Second), asynclog.WithLevelFlushInterval(asynclog.LevelDebug, 2*time.Second))
+
+	ctx := context.Background()
+	if err := s.PrintLevel(ctx, asynclog.LevelDebug, "d"); err != nil {
+		t.Fatal(err)
+	}
+	printAll(t, s, "i")
+	waitBuffered(t, s)
+
+	clock.Advance(time.Second)
+	if err := sink.WaitLines(testContext(t), 1); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := sink.Lines(), []string{"i"}; !slices.Equal(got, want) {
+		t.Fatalf("got lines %q after a second, want %q", got, want)
+	}
+
+	clock.Advance(time.Second)
+	if err := sink.WaitLines(testContext(t), 2); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := sink.Lines(), []string{"i", "d"}; !slices.Equal(got, want) {
+		t.Fatalf("got lines %q, want %q", got, want)
+	}
+}
+
+func TestWatermarks(t *testing.T) {
+	rec := testutil.NewRecorder()
+	s, sink, _ := start(t, asynclog.WithFlushInterval(time.Hour), asynclog.WithBatchSize(100), asynclog.WithWatermarks(2, 1), rec.Option())
+
+	// At the high watermark records stay buffered.
+	printAll(t, s, "a", "b")
+	waitBuffered(t, s)
+	if flushes := rec.Filter(asynclog.EventFlush); len(flushes) != 0 {
+		t.Fatalf("got flushes %v at the high watermark, want none", flushes)
+	}
+
+	printAll(t, s, "c")
+	if err := sink.WaitLines(testContext(t), 3); err != nil {
+		t.Fatal(err)
+	}
+	if flushes := rec.Filter(asynclog.EventFlush); len(flushes) != 1 || flushes[0].Reason != "watermark" {
+		t.Fatalf("got flushes %v, want one past the high watermark", flushes)
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	s, sink, clock := start(t, asynclog.WithRateLimit(1, 2), asynclog.WithLevelRateLimit(asynclog.LevelError, 1, 5))
+
+	printAll(t, s, "a", "b")
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := s.Print(ctx, "over"); !errors.Is(err, asynclog.ErrTimeout) {
+		t.Fatalf("Print over the rate: got %v, want ErrTimeout", err)
+	}
+
+	// Errors have a bucket of their own.
+	for range 5 {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		err := s.PrintLevel(ctx, asynclog.LevelError, "e")
+		cancel()
+		if err != nil {
+			t.Fatalf("PrintLevel(ERROR) within its own burst: %v", err)
+		}
+	}
+
+	// A producer over the rate waits for the clock rather than failing.
+	done := make(chan error, 1)
+	go func() { done <- s.Print(context.Background(), "c") }()
+	wctx := testContext(t)
+	for waiting := true; waiting; {
+		select {
+		case err := <-done:
+			if err != nil {
+				t.Fatal(err)
+			}
+			waiting = false
+		case <-wctx.Done():
+			t.Fatal("rate limited Print never returned")
+		case <-time.After(time.Millisecond):
+			clock.Advance(time.Second)
+		}
+	}
+
+	if err := s.Shutdown(testContext(t)); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := sink.Lines(), []string{"a", "b", "e", "e", "e", "e", "e", "c"}; !slices.Equal(got, want) {
+		t.Fatalf("got lines %q, want %q", got, want)
+	}
+}
+
+func TestRateLimitRefund(t *testing.T) {
+	s, sink, clock := start(t, asynclog.WithRateLimit(1, 1), asynclog.WithLevelRateLimit(asynclog.LevelError, 0.001, 1))
+
+	// A producer giving up on its token hands it back, so it is there for
+	// the next one once the first token is due again.
+	printAll(t, s, "a")
+	if err := printShort(s, "", asynclog.LevelInfo, "gave up"); !errors.Is(err, asynclog.ErrTimeout) {
+		t.Fatalf("Print over the rate: got %v, want ErrTimeout", err)
+	}
+	clock.Advance(time.Second)
+	if err := printShort(s, "", asynclog.LevelInfo, "b"); err != nil {
+		t.Fatal(err)
+	}
+
+	// A batch giving up on one bucket hands back what it took from the
+	// others, whichever order they were waited for in.
+	if err := printShort(s, "", asynclog.LevelError, "e"); err != nil {
+		t.Fatal(err)
+	}
+	for range 10 {
+		clock.Advance(time.Second)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		err := s.LogAll(ctx, []asynclog.Entry{{Level: asynclog.LevelInfo, Message: "x"}, {Level: asynclog.LevelError, Message: "y"}})
+		cancel()
+		if !errors.Is(err, asynclog.ErrTimeout) {
+			t.Fatalf("LogAll over the error rate: got %v, want ErrTimeout", err)
+		}
+		if err := printShort(s, "", asynclog.LevelInfo, "i"); err != nil {
+			t.Fatalf("Print after an abandoned batch: %v", err)
+		}
+	}
+
+	if err := s.Shutdown(testContext(t)); err != nil {
+		t.Fatal(err)
+	}
+	if got := sink.Lines(); len(got) != 13 || got[0] != "a" || got[1] != "b" || got[2] != "e" {
+		t.Fatalf("got lines %q, want a, b, e and ten i", got)
+	}
+}
+
+func TestRateLimitInvalid(t *testing.T) {
+	for _, rate := range []float64{0, -1, math.NaN(), math.Inf(1)} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("WithRateLimit(%v) didn't panic", rate)
+				}
+			}()
+			asynclog.WithRateLimit(rate, 1)
+		}()
+	}
+}
+
+func TestSourceQuotaBytes(t *testing.T) {
+	s, sink, clock := start(t, asynclog.WithSourceQuota(0, 5, time.Minute))
+
+	ctx := context.Background()
+	for _, r := range []struct{ source, msg string }{{"app", "abc"}, {"app", "def"}, {"other", "xyz"}} {
+		if err := s.PrintFrom(ctx, r.source, asynclog.LevelInfo, r.msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := s.Stats().OverQuota; n != 1 {
+		t.Fatalf("got %d records over quota, want def's", n)
+	}
+
+	// The quota starts over with the next interval.
+	clock.Advance(time.Minute)
+	if err := s.PrintFrom(ctx, "app", asynclog.LevelInfo, "ghi"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Shutdown(testContext(t)); err != nil {
+		t.Fatal(err)
+	}
+
+	lines := sink.Lines()
+	if !slices.Contains(lines, "ghi") || slices.Contains(lines, "def") || !slices.Contains(lines, "xyz") {
+		t.Fatalf("got lines %q, want all but def", lines)
+	}
+}
+
+func TestSetWriter(t *testing.T) {
+	s, old, _ := start(t, asynclog.WithFlushInterval(time.Hour))
+
+	printAll(t, s, "a")
+	next := testutil.NewSink()
+	if err := s.SetWriter(next); err != nil {
+		t.Fatal(err)
+	}
+	printAll(t, s, "b")
+	if err := s.Shutdown(testContext(t)); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := old.Lines(), []string{"a"}; !slices.Equal(got, want) {
+		t.Fatalf("old writer got %q, want %q", got, want)
+	}
+	if got, want := next.Lines(), []string{"b"}; !slices.Equal(got, want) {
+		t.Fatalf("new writer got %q, want %q", got, want)
+	}
+	if err := s.SetWriter(old); err != asynclog.ErrClosed {
+		t.Fatalf("SetWriter after Shutdown: got %v, want ErrClosed", err)
+	}
+}
+
+// enteredWriter signals entered on every write, then writes to the sink
+// once release is closed.
+type enteredWriter struct {
+	*testutil.Sink
+	entered chan struct{}
+	release chan struct{}
+}
+
+func (w enteredWriter) Write(p []byte) (int, error) {
+	w.entered <- struct{}{}
+	<-w.release
+	return w.Sink.Write(p)
+}
+
+func TestSetWriterQueued(t *testing.T) {
+	for range 20 {
+		old := enteredWriter{Sink: testutil.NewSink(), entered: make(chan struct{}, 3), release: make(chan struct{})}
+		s := asynclog.NewService(old, asynclog.WithBatchSize(1))
+		go s.Run(context.Background())
+
+		// With a stuck in the writer, Run waits for the worker to hand it b,
+		// and c is still in the queue when SetWriter is called.
+		printAll(t, s, "a")
+		<-old.entered
+		printAll(t, s, "b")
+		waitBuffered(t, s)
+		printAll(t, s, "c")
+		t.Log(s.Stats().Queued)
+
+		next := testutil.NewSink()
+		swapping, swapped := make(chan struct{}), make(chan error, 1)
+		go func() {
+			close(swapping)
+			swapped <- s.SetWriter(next)
+		}()
+		<-swapping
+		close(old.release)
+		if err := <-swapped; err != nil {
+			t.Fatal(err)
+		}
+		if err := s.Shutdown(testContext(t)); err != nil {
+			t.Fatal(err)
+		}
+
+		if got, want := old.Lines(), []string{"a", "b", "c"}; !slices.Equal(got, want) {
+			t.Fatalf("old writer got %q, want %q", got, want)
+		}
+		if got := next.Lines(); len(got) != 0 {
+			t.Fatalf("new writer got %q, want the records queued before SetWriter kept from it", got)
+		}
+	}
+}
+
+func TestSetWriterOldFails(t *testing.T) {
+	s, old, _ := start(t, asynclog.WithFlushInterval(time.Hour))
+
+	printAll(t, s, "a")
+	waitBuffered(t, s)
+	old.Fail(errors.New("down"))
+	if err := s.SetWriter(testutil.NewSink()); err == nil {
+		t.Fatal("SetWriter hid the failed final write to the old writer")
+	}
+}
+
+func TestMaxRecordAge(t *testing.T) {
+	s, sink, clock := start(t, asynclog.WithMaxRecordAge(time.Second), asynclog.WithDeliveryReports(10))
+
+	stale, err := s.PrintTracked(context.Background(), "stale")
+	if err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(2 * time.Second)
+	fresh, err := s.PrintTracked(context.Background(), "fresh")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Shutdown(testContext(t)); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := sink.Lines(), []string{"fresh"}; !slices.Equal(got, want) {
+		t.Fatalf("got lines %q, want %q", got, want)
+	}
+	if got := s.Expired(); got != 1 {
+		t.Fatalf("got %d expired, want 1", got)
+	}
+
+	want := map[uint64]asynclog.DeliveryStatus{stale: asynclog.Dropped, fresh: asynclog.Delivered}
+	for range want {
+		r := <-s.Reports()
+		if r.Status != want[r.ID] {
+			t.Fatalf("record %d: got %v, want %v", r.ID, r.Status, want[r.ID])
+		}
+	}
+}
+
+func TestMaxRecordAgePrintSync(t *testing.T) {
+	s, _, clock := start(t, asynclog.WithMaxRecordAge(time.Second))
+
+	done := make(chan error, 1)
+	go func() { done <- s.PrintSync(testContext(t), "stale") }()
+
+	ctx := testContext(t)
+	for s.Stats().Received == 0 {
+		select {
+		case <-ctx.Done():
+			t.Fatal("PrintSync never enqueued")
+		case <-time.After(time.Millisecond):
+		}
+	}
+	waitBuffered(t, s)
+	clock.Advance(5 * time.Second)
+
+	if err := <-done; !errors.Is(err, asynclog.ErrDropped) {
+		t.Fatalf("got %v, want ErrDropped", err)
+	}
+}
+
+func TestRetryBackoffClock(t *testing.T) {
+	s, sink, clock := start(t, asynclog.WithBatchSize(1), asynclog.WithRetry(2, time.Hour, 0))
+
+	// The retry waits on the service's clock, not on real time.
+	sink.Fail(errors.New("down"))
+	printAll(t, s, "a")
+	if err := clock.WaitTimers(testContext(t), 1); err != nil {
+		t.Fatal(err)
+	}
+	sink.Fail(nil)
+	clock.Advance(time.Hour)
+	if err := sink.WaitLines(testContext(t), 1); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestPrintBlockedOnFullQueue(t *testing.T) {
+	w := enteredWriter{Sink: testutil.NewSink(), entered: make(chan struct{}, 3), release: make(chan struct{})}
+	s := asynclog.NewService(w, asynclog.WithBatchSize(1), asynclog.WithChannelBuffer(1))
+	go s.Run(context.Background())
+
+	// With a stuck in the writer and Run waiting to hand b to the worker,
+	// c fills the queue.
+	printAll(t, s, "a")
+	<-w.entered
+	printAll(t, s, "b")
+	waitBuffered(t, s)
+	printAll(t, s, "c")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	printed := make(chan error, 1)
+	go func() { printed <- s.Print(ctx, "d") }()
+	cancel()
+	if err := <-printed; !errors.Is(err, asynclog.ErrTimeout) || !errors.Is(err, context.Canceled) {
+		t.Fatalf("got %v once the producer's context was done, want ErrTimeout", err)
+	}
+
+	// Shutdown releases a blocked producer even while the writer is stuck.
+	go func() { printed <- s.Print(context.Background(), "e") }()
+	shortCtx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	if err := s.Shutdown(shortCtx); err == nil {
+		t.Fatal("Shutdown returned with the writer stuck")
+	}
+	select {
+	case err := <-printed:
+		if !errors.Is(err, asynclog.ErrClosed) {
+			t.Fatalf("got %v after Shutdown, want ErrClosed", err)
+		}
+	case <-testContext(t).Done():
+		t.Fatal("producer still blocked after Shutdown")
+	}
+
+	close(w.release)
+	if err := s.Shutdown(testContext(t)); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := w.Lines(), []string{"a", "b", "c"}; !slices.Equal(got, want) {
+		t.Fatalf("got lines %q, want %q", got, want)
+	}
+}
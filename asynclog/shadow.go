@@ -0,0 +1,68 @@
+package asynclog
+
+import (
+	"io"
+	"sync/atomic"
+)
+
+// ShadowStats compares how batch writes fared on the main writer and on the
+// shadow writer since it was set.
+type ShadowStats struct {
+	PrimaryOK     uint64
+	PrimaryFailed uint64
+	ShadowOK      uint64
+	ShadowFailed  uint64
+}
+
+type shadowCounters struct {
+	primaryOK, primaryFailed, shadowOK, shadowFailed atomic.Uint64
+}
+
+// count records a write outcome, to the candidate writer or the current one.
+func (c *shadowCounters) count(candidate bool, err error) {
+	switch {
+	case candidate && err == nil:
+		c.shadowOK.Add(1)
+	case candidate:
+		c.shadowFailed.Add(1)
+	case err == nil:
+		c.primaryOK.Add(1)
+	default:
+		c.primaryFailed.Add(1)
+	}
+}
+
+// WithShadow is SetShadow at construction.
+func WithShadow(w io.Writer) Option {
+	return func(s *Service) {
+		s.shadow = w
+	}
+}
+
+// SetShadow makes every following batch for the main writer also go to w, a
+// candidate destination being migrated to. Errors from w don't count as
+// failed writes; ShadowStats compares both. nil stops shadowing.
+func (s *Service) SetShadow(w io.Writer) {
+	if w != nil {
+		w = s.wrap(w)
+	}
+
+	s.writerMx.Lock()
+	s.shadow = w
+	s.shadowStats = &shadowCounters{}
+	s.writerMx.Unlock()
+}
+
+// ShadowStats returns the write outcomes since the shadow writer was set.
+func (s *Service) ShadowStats() ShadowStats {
+	s.writerMx.RLock()
+	c := s.shadowStats
+	s.writerMx.RUnlock()
+
+	return ShadowStats{
+		PrimaryOK:     c.primaryOK.Load(),
+		PrimaryFailed: c.primaryFailed.Load(),
+		ShadowOK:      c.shadowOK.Load(),
+		ShadowFailed:  c.shadowFailed.Load(),
+	}
+}
@@ -0,0 +1,53 @@
+package asynclog_test
+
+import (
+	"errors"
+	"slices"
+	"testing"
+
+	"test-task-log/asynclog"
+	"test-task-log/asynclog/testutil"
+)
+
+func TestShadow(t *testing.T) {
+	shadow := testutil.NewSink()
+	s, main, _ := start(t, asynclog.WithBatchSize(1), asynclog.WithShadow(shadow))
+
+	if err := s.PrintSync(testContext(t), "a"); err != nil {
+		t.Fatal(err)
+	}
+
+	// The shadow failing doesn't fail the write.
+	shadow.Fail(errors.New("down"))
+	if err := s.PrintSync(testContext(t), "b"); err != nil {
+		t.Fatalf("got %v with only the shadow failing, want success", err)
+	}
+	main.Fail(errors.New("down"))
+	if err := s.PrintSync(testContext(t), "c"); err == nil {
+		t.Fatal("PrintSync succeeded with the main writer failing")
+	}
+	if err := s.Barrier(testContext(t)); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := main.Lines(), []string{"a", "b"}; !slices.Equal(got, want) {
+		t.Fatalf("main writer got %q, want %q", got, want)
+	}
+	if got, want := shadow.Lines(), []string{"a"}; !slices.Equal(got, want) {
+		t.Fatalf("shadow got %q, want %q", got, want)
+	}
+	want := asynclog.ShadowStats{PrimaryOK: 2, PrimaryFailed: 1, ShadowOK: 1, ShadowFailed: 2}
+	if got := s.ShadowStats(); got != want {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+
+	// Stopping shadowing leaves the main writer alone.
+	s.SetShadow(nil)
+	main.Fail(nil)
+	if err := s.PrintSync(testContext(t), "d"); err != nil {
+		t.Fatal(err)
+	}
+	if got := s.ShadowStats(); got != (asynclog.ShadowStats{}) {
+		t.Fatalf("got %+v after SetShadow(nil), want zero", got)
+	}
+}
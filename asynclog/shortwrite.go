@@ -0,0 +1,97 @@
+package asynclog
+
+import (
+	"context"
+	"io"
+	"sync/atomic"
+	"time"
+)
+
+// ShortWritePolicy is what the service does when a writer takes less than
+// the whole batch without returning an error.
+type ShortWritePolicy int
+
+const (
+	// ShortWriteFail fails the write with io.ErrShortWrite, the default.
+	ShortWriteFail ShortWritePolicy = iota
+	// ShortWriteRetry writes the remainder again for as long as every
+	// attempt takes some of it, and fails like ShortWriteFail otherwise.
+	ShortWriteRetry
+)
+
+// WithShortWritePolicy sets how short writes are handled. Either way they
+// are counted by ShortWrites, rather than silently losing the end of a
+// batch.
+func WithShortWritePolicy(p ShortWritePolicy) Option {
+	return func(s *Service) {
+		s.shortPolicy = p
+	}
+}
+
+// ShortWrites returns the number of writes that took less than they were
+// given without an error.
+func (s *Service) ShortWrites() int64 {
+	return s.shortWrites.Load()
+}
+
+// shortWriter applies the short write policy to w.
+type shortWriter struct {
+	w      io.Writer
+	policy ShortWritePolicy
+	count  *atomic.Int64
+}
+
+func (sw *shortWriter) Write(p []byte) (int, error) {
+	return sw.WriteContext(context.Background(), p)
+}
+
+func (sw *shortWriter) WriteContext(ctx context.Context, p []byte) (int, error) {
+	written := 0
+	for {
+		n, err := writeContext(ctx, sw.w, p[written:])
+		written += n
+		if err != nil || written >= len(p) {
+			return written, err
+		}
+
+		sw.count.Add(1)
+		if sw.policy != ShortWriteRetry || n == 0 {
+			return written, io.ErrShortWrite
+		}
+	}
+}
+
+func (sw *shortWriter) Flush() error {
+	return flushWriter(sw.w)
+}
+
+func (sw *shortWriter) Sync() error {
+	return syncWriter(sw.w)
+}
+
+func (sw *shortWriter) SetWriteDeadline(t time.Time) error {
+	if d, ok := sw.w.(writeDeadliner); ok {
+		return d.SetWriteDeadline(t)
+	}
+
+	return nil
+}
+
+func (sw *shortWriter) Probe(ctx context.Context) error {
+	if p, ok := sw.w.(Prober); ok {
+		return p.Probe(ctx)
+	}
+
+	_, err := sw.w.Write(nil)
+
+	return err
+}
+
+// Close closes w if it is an io.Closer, so StuckClose still reaches it.
+func (sw *shortWriter) Close() error {
+	if c, ok := sw.w.(io.Closer); ok {
+		return c.Close()
+	}
+
+	return nil
+}
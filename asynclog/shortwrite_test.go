@@ -0,0 +1,76 @@
+package asynclog_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+
+	"test-task-log/asynclog"
+)
+
+// chunkWriter takes at most chunk bytes per write, without an error.
+type chunkWriter struct {
+	mu    sync.Mutex
+	chunk int
+	buf   bytes.Buffer
+}
+
+func (w *chunkWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	n := min(len(p), w.chunk)
+	w.buf.Write(p[:n])
+	return n, nil
+}
+
+func (w *chunkWriter) String() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.buf.String()
+}
+
+func TestShortWriteRetry(t *testing.T) {
+	w := &chunkWriter{chunk: 3}
+	s := asynclog.NewService(w, asynclog.WithBatchSize(1), asynclog.WithShortWritePolicy(asynclog.ShortWriteRetry))
+	go s.Run(context.Background())
+	t.Cleanup(func() { s.Shutdown(context.Background()) })
+
+	if err := s.PrintSync(testContext(t), "abcdefg"); err != nil {
+		t.Fatal(err)
+	}
+	if got := w.String(); got != "abcdefg\n" {
+		t.Fatalf("got %q, want the whole batch", got)
+	}
+	if got := s.ShortWrites(); got != 2 {
+		t.Fatalf("got %d short writes, want 2", got)
+	}
+}
+
+func TestShortWriteFail(t *testing.T) {
+	for name, tc := range map[string]struct {
+		chunk  int
+		policy asynclog.ShortWritePolicy
+	}{
+		"fail":             {3, asynclog.ShortWriteFail},
+		"retry no headway": {0, asynclog.ShortWriteRetry},
+	} {
+		t.Run(name, func(t *testing.T) {
+			w := &chunkWriter{chunk: tc.chunk}
+			s := asynclog.NewService(w, asynclog.WithBatchSize(1), asynclog.WithShortWritePolicy(tc.policy))
+			go s.Run(context.Background())
+			t.Cleanup(func() { s.Shutdown(context.Background()) })
+
+			if err := s.PrintSync(testContext(t), "abcdefg"); !errors.Is(err, io.ErrShortWrite) {
+				t.Fatalf("got %v, want io.ErrShortWrite", err)
+			}
+			if got := s.ShortWrites(); got != 1 {
+				t.Fatalf("got %d short writes, want 1", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,216 @@
+package asynclog
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ShutdownReport is what happened to the records in flight when the service
+// shut down, for a post-mortem.
+type ShutdownReport struct {
+	// Pending is the number of records queued, buffered or being written
+	// when shutdown began, and OldestPending the age of the oldest queued
+	// or buffered one.
+	Pending       int
+	OldestPending time.Duration
+	// Flushed is the number of records written and Dropped the number
+	// dropped during shutdown. FailedBatches is the number of batch writes
+	// that failed.
+	Flushed       int64
+	Dropped       int64
+	FailedBatches int64
+	// RetryPending is the number of batches left in the retry queue for the
+	// next run.
+	RetryPending int
+	// Duration is how long the shutdown took.
+	Duration time.Duration
+	// Sinks is the final status of the main writer and of every sink.
+	Sinks []SinkStatus
+}
+
+// SinkStatus is the outcome of the last write to a sink. Name is empty for
+// the main writer, and LastWrite zero if nothing was written to it.
+type SinkStatus struct {
+	Name      string
+	LastWrite time.Time
+	LastError error
+}
+
+// String formats the report on one line, e.g. for stderr.
+func (r ShutdownReport) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "asynclog: shutdown in %s: %d pending", r.Duration.Round(time.Millisecond), r.Pending)
+	if r.Pending > 0 {
+		fmt.Fprintf(&b, " (oldest %s)", r.OldestPending.Round(time.Millisecond))
+	}
+	fmt.Fprintf(&b, ", %d flushed, %d dropped, %d failed batches", r.Flushed, r.Dropped, r.FailedBatches)
+	if r.RetryPending > 0 {
+		fmt.Fprintf(&b, ", %d batches left for retry", r.RetryPending)
+	}
+
+	for _, st := range r.Sinks {
+		name := "writer"
+		if st.Name != "" {
+			name = "sink " + st.Name
+		}
+
+		switch {
+		case st.LastError != nil:
+			fmt.Fprintf(&b, "; %s: %v", name, st.LastError)
+		case st.LastWrite.IsZero():
+			fmt.Fprintf(&b, "; %s idle", name)
+		default:
+			fmt.Fprintf(&b, "; %s ok", name)
+		}
+	}
+
+	return b.String()
+}
+
+// WithShutdownReport writes a ShutdownReport line to w and passes the report
+// to fn once the service has shut down. Either may be nil.
+func WithShutdownReport(w io.Writer, fn func(ShutdownReport)) Option {
+	return func(s *Service) {
+		s.shutdownReport = &shutdownReport{w: w, fn: fn}
+	}
+}
+
+type shutdownReport struct {
+	w  io.Writer
+	fn func(ShutdownReport)
+
+	// Set when shutdown begins.
+	report  ShutdownReport
+	start   time.Time
+	written int64
+	dropped int64
+	failed  int64
+
+	mu   sync.Mutex
+	last []writerOutcome
+}
+
+type writerOutcome struct {
+	writer io.Writer
+	at     time.Time
+	err    error
+}
+
+// observe records the outcome of a write to w completed at now, and to each
+// writer of a MultiWriter separately.
+func (r *shutdownReport) observe(w io.Writer, now time.Time, err error) {
+	if r == nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	m, ok := w.(*MultiWriter)
+	if !ok {
+		r.set(w, now, err)
+		return
+	}
+
+	var merr MultiError
+	isMulti := errors.As(err, &merr)
+	for _, w := range m.writers {
+		werr := err
+		if isMulti {
+			werr = nil
+			for _, we := range merr {
+				if sameWriter(we.Writer, w) {
+					werr = we.Err
+				}
+			}
+		}
+		r.set(w, now, werr)
+	}
+}
+
+func (r *shutdownReport) set(w io.Writer, at time.Time, err error) {
+	for i := range r.last {
+		if sameWriter(r.last[i].writer, w) {
+			r.last[i].at, r.last[i].err = at, err
+			return
+		}
+	}
+
+	r.last = append(r.last, writerOutcome{writer: w, at: at, err: err})
+}
+
+func (r *shutdownReport) status(name string, w io.Writer) SinkStatus {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	st := SinkStatus{Name: name}
+	for _, o := range r.last {
+		if sameWriter(o.writer, w) {
+			st.LastWrite, st.LastError = o.at, o.err
+		}
+	}
+
+	return st
+}
+
+// beginReport takes the gauges for the shutdown report as shutdown begins.
+// It runs in Run.
+func (s *Service) beginReport() {
+	r := s.shutdownReport
+	if r == nil {
+		return
+	}
+	r.start = s.clock.Now()
+
+	oldest := s.queue.oldest()
+	pending := s.queue.size() + int(s.inflight.Load())
+	for _, b := range s.buffers() {
+		pending += len(b.records)
+		for _, rec := range b.records {
+			if oldest.IsZero() || rec.time.Before(oldest) {
+				oldest = rec.time
+			}
+		}
+	}
+	r.report.Pending = pending
+	if !oldest.IsZero() {
+		r.report.OldestPending = r.start.Sub(oldest)
+	}
+
+	st := s.Stats()
+	r.written, r.dropped, r.failed = s.stats.records.Load(), st.Dropped, st.WriteErrors
+}
+
+// endReport completes the shutdown report and emits it. It runs in Run once
+// everything has been written.
+func (s *Service) endReport() {
+	r := s.shutdownReport
+	if r == nil {
+		return
+	}
+
+	st := s.Stats()
+	r.report.Flushed = s.stats.records.Load() - r.written
+	r.report.Dropped = st.Dropped - r.dropped
+	r.report.FailedBatches = st.WriteErrors - r.failed
+	r.report.RetryPending, _ = s.RetryQueued()
+
+	s.writerMx.RLock()
+	r.report.Sinks = append(r.report.Sinks, r.status("", s.writer))
+	for _, sk := range s.sinks {
+		r.report.Sinks = append(r.report.Sinks, r.status(sk.name, sk.writer))
+	}
+	s.writerMx.RUnlock()
+	r.report.Duration = s.clock.Now().Sub(r.start)
+
+	if r.w != nil {
+		fmt.Fprintln(r.w, r.report)
+	}
+	if r.fn != nil {
+		r.fn(r.report)
+	}
+}
@@ -0,0 +1,61 @@
+package asynclog_test
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"test-task-log/asynclog"
+	"test-task-log/asynclog/testutil"
+)
+
+func TestShutdownReport(t *testing.T) {
+	var buf bytes.Buffer
+	var got asynclog.ShutdownReport
+	s, _, clock := start(t, asynclog.WithShutdownReport(&buf, func(r asynclog.ShutdownReport) { got = r }))
+
+	printAll(t, s, "a", "b")
+	waitBuffered(t, s)
+	clock.Advance(2 * time.Second)
+	if err := s.Shutdown(testContext(t)); err != nil {
+		t.Fatal(err)
+	}
+
+	if got.Pending != 2 || got.OldestPending != 2*time.Second || got.Flushed != 2 || got.FailedBatches != 0 {
+		t.Fatalf("got %+v, want 2 pending for 2s and 2 flushed", got)
+	}
+	if len(got.Sinks) != 1 || got.Sinks[0].LastError != nil || !got.Sinks[0].LastWrite.Equal(clock.Now()) {
+		t.Fatalf("got sink statuses %+v, want the writer written at shutdown", got.Sinks)
+	}
+	if want := "2 pending (oldest 2s), 2 flushed, 0 dropped, 0 failed batches; writer ok"; !strings.Contains(buf.String(), want) {
+		t.Fatalf("got report %q, want %q", buf.String(), want)
+	}
+}
+
+func TestShutdownReportFailedSink(t *testing.T) {
+	var got asynclog.ShutdownReport
+	s, _, _ := start(t, asynclog.WithShutdownReport(nil, func(r asynclog.ShutdownReport) { got = r }))
+	bad := testutil.NewSink()
+	bad.Fail(errors.New("disk full"))
+	if err := s.AddSink("bad", bad); err != nil {
+		t.Fatal(err)
+	}
+
+	printAll(t, s, "a")
+	if err := s.Shutdown(testContext(t)); err != nil {
+		t.Fatal(err)
+	}
+
+	// The batch failed as a whole, so its records aren't counted as flushed.
+	if got.Flushed != 0 || got.FailedBatches != 1 {
+		t.Fatalf("got %+v, want the batch failed", got)
+	}
+	if len(got.Sinks) != 2 || got.Sinks[0].LastError != nil || got.Sinks[1].Name != "bad" || got.Sinks[1].LastError == nil {
+		t.Fatalf("got sink statuses %+v, want the writer ok and the bad sink failed", got.Sinks)
+	}
+	if want := "writer ok; sink bad: disk full"; !strings.Contains(got.String(), want) {
+		t.Fatalf("got report %q, want %q", got.String(), want)
+	}
+}
@@ -0,0 +1,70 @@
+package asynclog
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ed25519"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+const signPrefix = "#sig "
+
+// signBatch appends a trailer line with the ed25519 signature of payload.
+func signBatch(key ed25519.PrivateKey, payload []byte) []byte {
+	sig := ed25519.Sign(key, payload)
+	trailer := signPrefix + "ed25519=" + base64.StdEncoding.EncodeToString(sig) + "\n"
+
+	return append(payload, trailer...)
+}
+
+// VerifyBatchSignatures reads output written with WithBatchSigning and checks
+// every batch against its signature trailer.
+func VerifyBatchSignatures(r io.Reader, pub ed25519.PublicKey) error {
+	var (
+		payload bytes.Buffer
+		n       int
+	)
+
+	sc := bufio.NewScanner(r)
+	sc.Buffer(nil, 1<<24)
+	for sc.Scan() {
+		line := sc.Text()
+		if !strings.HasPrefix(line, signPrefix) {
+			payload.WriteString(line)
+			payload.WriteByte('\n')
+			continue
+		}
+
+		n++
+
+		enc, ok := strings.CutPrefix(line[len(signPrefix):], "ed25519=")
+		if !ok {
+			return fmt.Errorf("batch %d: unsupported signature %q", n, line)
+		}
+
+		sig, err := base64.StdEncoding.DecodeString(enc)
+		if err != nil {
+			return fmt.Errorf("batch %d: malformed signature: %w", n, err)
+		}
+
+		if !ed25519.Verify(pub, payload.Bytes(), sig) {
+			return fmt.Errorf("batch %d: signature mismatch", n)
+		}
+
+		payload.Reset()
+	}
+
+	if err := sc.Err(); err != nil {
+		return err
+	}
+
+	if payload.Len() > 0 {
+		return errors.New("records after the last signed batch")
+	}
+
+	return nil
+}
@@ -0,0 +1,69 @@
+package asynclog_test
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"testing"
+
+	"test-task-log/asynclog"
+	"test-task-log/asynclog/testutil"
+)
+
+func TestBatchSigning(t *testing.T) {
+	pub, key, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sink := testutil.NewSink()
+	s := asynclog.NewService(sink, asynclog.WithBatchSigning(key), asynclog.WithBatchSize(2))
+	go s.Run(context.Background())
+	printAll(t, s, "a", "b", "c")
+	if err := s.Shutdown(testContext(t)); err != nil {
+		t.Fatal(err)
+	}
+	out := bytes.Join(sink.Writes(), nil)
+
+	if err := asynclog.VerifyBatchSignatures(bytes.NewReader(out), pub); err != nil {
+		t.Fatal(err)
+	}
+
+	tampered := bytes.Replace(out, []byte("b\n"), []byte("B\n"), 1)
+	if err := asynclog.VerifyBatchSignatures(bytes.NewReader(tampered), pub); err == nil {
+		t.Fatal("tampered output verified")
+	}
+
+	other, _, _ := ed25519.GenerateKey(nil)
+	if err := asynclog.VerifyBatchSignatures(bytes.NewReader(out), other); err == nil {
+		t.Fatal("output verified with another key")
+	}
+
+	unsigned := append(bytes.Clone(out), "d\n"...)
+	if err := asynclog.VerifyBatchSignatures(bytes.NewReader(unsigned), pub); err == nil {
+		t.Fatal("unsigned trailing records verified")
+	}
+}
+
+func TestBatchSigningForged(t *testing.T) {
+	pub, key, err := ed25519.GenerateKey(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sink := testutil.NewSink()
+	s := asynclog.NewService(sink, asynclog.WithBatchSigning(key))
+	go s.Run(context.Background())
+	printAll(t, s, "a", "#sig ed25519=AAAA", "b")
+	if err := s.Shutdown(testContext(t)); err != nil {
+		t.Fatal(err)
+	}
+	out := bytes.Join(sink.Writes(), nil)
+
+	if !bytes.Contains(out, []byte("\n##sig ed25519=AAAA\n")) {
+		t.Fatalf("got %q, want the record escaped", out)
+	}
+	if err := asynclog.VerifyBatchSignatures(bytes.NewReader(out), pub); err != nil {
+		t.Fatal(err)
+	}
+}
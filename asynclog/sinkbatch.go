@@ -0,0 +1,168 @@
+package asynclog
+
+import (
+	"fmt"
+	"slices"
+	"time"
+)
+
+// sinkBatch is the staging buffer of a sink batched on its own. every and
+// limit are guarded by writerMx, the rest belongs to Run.
+type sinkBatch struct {
+	batch
+	every time.Duration
+	limit int
+	// immediate is set for the WithErrorSink sink, flushed every time Run
+	// takes records off the queue.
+	immediate bool
+}
+
+// SetSinkBatching gives the named sink its own staging buffer, written every
+// every or once it holds limit records, instead of going out with
+// the main batches, e.g. to flush a console often and an archive rarely. Zero
+// uses the service's interval or limit. Records are staged from the next one
+// Run takes off the queue; the sink keeps its own batches until removed.
+func (s *Service) SetSinkBatching(name string, every time.Duration, limit int) error {
+	if every < 0 || limit < 0 {
+		return fmt.Errorf("asynclog: negative batching for sink %q", name)
+	}
+	s.writerMx.Lock()
+	defer s.writerMx.Unlock()
+
+	if every == 0 {
+		every = s.writeEvery
+	}
+	if limit == 0 {
+		limit = s.writeLimit
+	}
+
+	i := slices.IndexFunc(s.sinks, func(sk namedSink) bool { return sk.name == name })
+	if i < 0 {
+		return fmt.Errorf("asynclog: no sink %q", name)
+	}
+
+	if s.sinks[i].staged == nil {
+		s.sinks[i].staged = &sinkBatch{}
+	}
+	s.sinks[i].staged.every = every
+	s.sinks[i].staged.limit = limit
+
+	return nil
+}
+
+// stage copies rec into the staging buffer of every batched sink it is
+// routed to. It runs in Run.
+func (s *Service) stage(rec record) {
+	s.writerMx.RLock()
+	defer s.writerMx.RUnlock()
+
+	for _, sk := range s.sinks {
+		if sk.staged == nil {
+			continue
+		}
+		if r := rec; sk.route != nil && !sk.route.match(&r) {
+			continue
+		}
+
+		sk.staged.records = append(sk.staged.records, rec)
+		if len(sk.staged.records) >= sk.staged.limit {
+			s.flushSink(sk, false)
+		}
+	}
+}
+
+// tickSinks flushes the batched sinks whose interval is up, and whatever is
+// left staged for sinks removed since the last tick.
+func (s *Service) tickSinks() {
+	for _, sk := range s.takeRetired() {
+		s.flushSink(sk, true)
+	}
+
+	s.writerMx.RLock()
+	defer s.writerMx.RUnlock()
+
+	for _, sk := range s.sinks {
+		if sk.staged == nil {
+			continue
+		}
+
+		sk.staged.n++
+		if sk.staged.n >= sk.staged.ticks {
+			sk.staged.n = 0
+			s.flushSink(sk, false)
+		}
+	}
+}
+
+// flushSinks flushes every batched sink, for barriers and shutdown.
+func (s *Service) flushSinks() {
+	for _, sk := range s.takeRetired() {
+		s.flushSink(sk, true)
+	}
+
+	s.writerMx.RLock()
+	defer s.writerMx.RUnlock()
+
+	for _, sk := range s.sinks {
+		if sk.staged != nil {
+			s.flushSink(sk, false)
+		}
+	}
+}
+
+func (s *Service) takeRetired() []namedSink {
+	s.writerMx.Lock()
+	defer s.writerMx.Unlock()
+
+	retired := s.retired
+	s.retired = nil
+
+	return retired
+}
+
+// flushSink hands the records staged for sk to a write goroutine. Tracked
+// records are reported by the main batches, and expired ones dropped
+// without being counted again. last is set for removed sinks, whose queue is
+// closed once written to.
+func (s *Service) flushSink(sk namedSink, last bool) {
+	recs := sk.staged.records
+	sk.staged.records = nil
+
+	if s.maxAge > 0 {
+		now := s.clock.Now()
+		recs = slices.DeleteFunc(recs, func(rec record) bool { return now.Sub(rec.time) > s.maxAge })
+	}
+	if len(recs) == 0 {
+		if last {
+			closeQueued(sk.writer)
+		}
+		return
+	}
+	s.debugf("flush sink %q: %d records", sk.name, len(recs))
+
+	var ts []target
+	for _, part := range s.split(recs) {
+		payload, err := s.encode(part, false)
+		if err != nil {
+			s.debugf("sink %q: %v", sk.name, err)
+			s.health.set(err)
+			if last {
+				closeQueued(sk.writer)
+			}
+			return
+		}
+		ts = append(ts, target{writer: sk.writer, payload: payload})
+	}
+
+	s.spawn(func() {
+		ctx, cancel := s.flushContext()
+		defer cancel()
+
+		for _, t := range ts {
+			s.deliver(ctx, []target{t})
+		}
+		if last {
+			closeQueued(sk.writer)
+		}
+	})
+}
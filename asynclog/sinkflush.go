@@ -0,0 +1,30 @@
+package asynclog
+
+import "io"
+
+// flusher is implemented by writers that buffer internally, such as
+// *bufio.Writer and *gzip.Writer.
+type flusher interface {
+	Flush() error
+}
+
+// syncer is implemented by writers backed by storage, such as *os.File.
+type syncer interface {
+	Sync() error
+}
+
+func flushWriter(w io.Writer) error {
+	if f, ok := w.(flusher); ok {
+		return f.Flush()
+	}
+
+	return nil
+}
+
+func syncWriter(w io.Writer) error {
+	if s, ok := w.(syncer); ok {
+		return s.Sync()
+	}
+
+	return nil
+}
@@ -0,0 +1,119 @@
+package asynclog_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"test-task-log/asynclog"
+)
+
+// bufferedWriter holds writes until Flush, counting Flush and Sync calls.
+type bufferedWriter struct {
+	mu       sync.Mutex
+	pending  bytes.Buffer
+	out      bytes.Buffer
+	flushErr error
+	syncErr  error
+	flushes  int
+	syncs    int
+}
+
+func (w *bufferedWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.pending.Write(p)
+}
+
+func (w *bufferedWriter) Flush() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.flushes++
+	if w.flushErr != nil {
+		return w.flushErr
+	}
+	w.pending.WriteTo(&w.out)
+
+	return nil
+}
+
+func (w *bufferedWriter) Sync() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.syncs++
+
+	return w.syncErr
+}
+
+func (w *bufferedWriter) counts() (out string, flushes, syncs int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.out.String(), w.flushes, w.syncs
+}
+
+func TestFlushAndSyncWrappedWriter(t *testing.T) {
+	w := &bufferedWriter{}
+	s := asynclog.NewService(w, asynclog.WithBatchSize(1))
+	go s.Run(context.Background())
+
+	printAll(t, s, "a")
+	ctx := testContext(t)
+	for out, _, _ := w.counts(); out != "a\n"; out, _, _ = w.counts() {
+		select {
+		case <-ctx.Done():
+			t.Fatalf("got %q, want the batch flushed", out)
+		case <-time.After(time.Millisecond):
+		}
+	}
+	if _, _, syncs := w.counts(); syncs != 0 {
+		t.Fatalf("synced %d times before shutdown, want only at shutdown", syncs)
+	}
+
+	if err := s.Shutdown(testContext(t)); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, syncs := w.counts(); syncs == 0 {
+		t.Fatal("not synced at shutdown")
+	}
+}
+
+func TestFlushErrorFailsBatch(t *testing.T) {
+	w := &bufferedWriter{flushErr: errors.New("disk full")}
+	s, _, _ := start(t, asynclog.WithWriter(w), asynclog.WithBatchSize(1))
+
+	printAll(t, s, "a")
+	ctx := testContext(t)
+	for s.Stats().WriteErrors == 0 {
+		select {
+		case <-ctx.Done():
+			t.Fatal("a failed flush didn't fail the batch")
+		case <-time.After(time.Millisecond):
+		}
+	}
+}
+
+func TestSyncEachBatch(t *testing.T) {
+	w := &bufferedWriter{}
+	s, _, _ := start(t, asynclog.WithWriter(w), asynclog.WithBatchSize(1), asynclog.WithSyncEachBatch())
+
+	if err := s.PrintSync(testContext(t), "a"); err != nil {
+		t.Fatal(err)
+	}
+	if out, _, syncs := w.counts(); out != "a\n" || syncs != 1 {
+		t.Fatalf("got %q synced %d times, want the batch flushed and synced", out, syncs)
+	}
+
+	w.mu.Lock()
+	w.syncErr = errors.New("fsync failed")
+	w.mu.Unlock()
+	if err := s.PrintSync(testContext(t), "b"); err == nil {
+		t.Fatal("a failed sync didn't fail the batch")
+	}
+}
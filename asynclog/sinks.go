@@ -0,0 +1,105 @@
+package asynclog
+
+import (
+	"fmt"
+	"io"
+	"slices"
+)
+
+type namedSink struct {
+	name   string
+	writer io.Writer
+	route  *Expr
+	// staged is set for sinks batched on their own with SetSinkBatching.
+	staged *sinkBatch
+}
+
+// AddSink makes every following batch go to w as well as to the main writer,
+// until the sink is removed with RemoveSink. A failing sink doesn't keep the
+// others from being written to.
+func (s *Service) AddSink(name string, w io.Writer) error {
+	s.writerMx.Lock()
+	defer s.writerMx.Unlock()
+
+	if slices.ContainsFunc(s.sinks, func(sk namedSink) bool { return sk.name == name }) {
+		return fmt.Errorf("asynclog: sink %q already added", name)
+	}
+
+	s.sinks = append(s.sinks, namedSink{name: name, writer: s.sinkWriter(w)})
+	s.debugf("sink %q added", name)
+
+	return nil
+}
+
+// sinkWriter wraps w as configured for sinks.
+func (s *Service) sinkWriter(w io.Writer) io.Writer {
+	w = s.wrap(w)
+	if s.sinkQueue > 0 {
+		w = newQueuedWriter(w, s.sinkQueue)
+	}
+
+	return w
+}
+
+// RemoveSink stops sending batches to the named sink. Batches already being
+// written may still reach it, as do the records staged for a sink batched on
+// its own, on the next tick.
+func (s *Service) RemoveSink(name string) error {
+	s.writerMx.Lock()
+	defer s.writerMx.Unlock()
+
+	i := slices.IndexFunc(s.sinks, func(sk namedSink) bool { return sk.name == name })
+	if i < 0 {
+		return fmt.Errorf("asynclog: no sink %q", name)
+	}
+
+	if s.sinks[i].staged != nil {
+		s.retired = append(s.retired, s.sinks[i])
+	} else {
+		closeQueued(s.sinks[i].writer)
+	}
+	s.sinks = slices.Delete(s.sinks, i, i+1)
+	s.debugf("sink %q removed", name)
+
+	return nil
+}
+
+// RouteSink limits the named sink to records matching e, from the next flush
+// on; nil sends it everything again. Routed sinks get their own payload,
+// without audit or signature trailers.
+func (s *Service) RouteSink(name string, e *Expr) error {
+	s.writerMx.Lock()
+	defer s.writerMx.Unlock()
+
+	i := slices.IndexFunc(s.sinks, func(sk namedSink) bool { return sk.name == name })
+	if i < 0 {
+		return fmt.Errorf("asynclog: no sink %q", name)
+	}
+
+	s.sinks[i].route = e
+
+	return nil
+}
+
+// closeSinks stops the goroutines of queued sinks once Run is done with them.
+func (s *Service) closeSinks() {
+	s.writerMx.RLock()
+	defer s.writerMx.RUnlock()
+
+	for _, sk := range s.sinks {
+		closeQueued(sk.writer)
+	}
+}
+
+// Sinks returns the names of the added sinks in the order they were added.
+func (s *Service) Sinks() []string {
+	s.writerMx.RLock()
+	defer s.writerMx.RUnlock()
+
+	names := make([]string, len(s.sinks))
+	for i, sk := range s.sinks {
+		names[i] = sk.name
+	}
+
+	return names
+}
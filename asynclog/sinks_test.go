@@ -0,0 +1,123 @@
+package asynclog_test
+
+import (
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"test-task-log/asynclog"
+	"test-task-log/asynclog/testutil"
+)
+
+func TestAddRemoveSink(t *testing.T) {
+	s, main, _ := start(t, asynclog.WithBatchSize(1))
+
+	extra, failing := testutil.NewSink(), testutil.NewSink()
+	failing.Fail(errors.New("down"))
+	for name, w := range map[string]*testutil.Sink{"extra": extra, "failing": failing} {
+		if err := s.AddSink(name, w); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.AddSink("extra", testutil.NewSink()); err == nil {
+		t.Fatal("added a sink under a name in use")
+	}
+
+	printAll(t, s, "a")
+	if err := extra.WaitLines(testContext(t), 1); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.RemoveSink("extra"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.RemoveSink("extra"); err == nil {
+		t.Fatal("removed a sink twice")
+	}
+	if got, want := s.Sinks(), []string{"failing"}; !slices.Equal(got, want) {
+		t.Fatalf("got sinks %q, want %q", got, want)
+	}
+
+	printAll(t, s, "b")
+	if err := main.WaitLines(testContext(t), 2); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := extra.Lines(), []string{"a"}; !slices.Equal(got, want) {
+		t.Fatalf("removed sink got %q, want %q", got, want)
+	}
+}
+
+func TestRoundRobin(t *testing.T) {
+	s, main, _ := start(t, asynclog.WithBatchSize(1), asynclog.WithRoundRobin())
+	extra := testutil.NewSink()
+	if err := s.AddSink("extra", extra); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, msg := range []string{"a", "b", "c", "d"} {
+		if err := s.PrintSync(testContext(t), msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got, want := main.Lines(), []string{"a", "c"}; !slices.Equal(got, want) {
+		t.Fatalf("main writer got %q, want %q", got, want)
+	}
+	if got, want := extra.Lines(), []string{"b", "d"}; !slices.Equal(got, want) {
+		t.Fatalf("sink got %q, want %q", got, want)
+	}
+
+	// A batch whose turn falls on a failing sink fails, it isn't handed to
+	// the next one.
+	extra.Fail(errors.New("down"))
+	if err := s.PrintSync(testContext(t), "e"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.PrintSync(testContext(t), "f"); err == nil {
+		t.Fatal("PrintSync succeeded on the failing sink's turn")
+	}
+	if got, want := main.Lines(), []string{"a", "c", "e"}; !slices.Equal(got, want) {
+		t.Fatalf("main writer got %q, want %q", got, want)
+	}
+}
+
+func TestSinkBatching(t *testing.T) {
+	s, main, clock := start(t)
+	archive := testutil.NewSink()
+	if err := s.AddSink("archive", archive); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SetSinkBatching("archive", time.Hour, 3); err != nil {
+		t.Fatal(err)
+	}
+
+	// The main writer goes on flushing every interval; the archive waits
+	// for its own limit.
+	printAll(t, s, "a", "b")
+	waitBuffered(t, s)
+	clock.Advance(5 * time.Second)
+	if err := main.WaitLines(testContext(t), 2); err != nil {
+		t.Fatal(err)
+	}
+	if got := archive.Lines(); len(got) != 0 {
+		t.Fatalf("archive got %q before its limit, want nothing", got)
+	}
+
+	printAll(t, s, "c", "d")
+	if err := archive.WaitLines(testContext(t), 3); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Barrier(testContext(t)); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := archive.Lines(), []string{"a", "b", "c", "d"}; !slices.Equal(got, want) {
+		t.Fatalf("archive got %q, want %q", got, want)
+	}
+
+	if err := s.SetSinkBatching("nosuch", time.Second, 1); err == nil {
+		t.Fatal("set batching for an unknown sink")
+	}
+	if err := s.SetSinkBatching("archive", -time.Second, 1); err == nil {
+		t.Fatal("set a negative batching interval")
+	}
+}
@@ -0,0 +1,105 @@
+package asynclog
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// sizeWindows windows of sizeWindow each make up the histogram, so old
+	// sizes age out a window at a time.
+	sizeWindows = 6
+	sizeWindow  = 10 * time.Second
+)
+
+// sizeBounds are the upper bounds of the histogram buckets, in bytes. A last
+// bucket counts larger records.
+var sizeBounds = [...]int{64, 128, 256, 512, 1 << 10, 2 << 10, 4 << 10, 8 << 10, 16 << 10, 32 << 10, 64 << 10}
+
+// sizeCounter keeps a histogram of message sizes over the last minute.
+type sizeCounter struct {
+	mu      sync.Mutex
+	windows [sizeWindows]sizeWindowCounts
+}
+
+type sizeWindowCounts struct {
+	n      int64 // window number since the epoch
+	counts [len(sizeBounds) + 1]int64
+}
+
+func (c *sizeCounter) add(now time.Time, size int) {
+	n := now.UnixNano() / int64(sizeWindow)
+
+	i := len(sizeBounds)
+	for j, bound := range sizeBounds {
+		if size <= bound {
+			i = j
+			break
+		}
+	}
+
+	c.mu.Lock()
+	w := &c.windows[n%sizeWindows]
+	if w.n != n {
+		*w = sizeWindowCounts{n: n}
+	}
+	w.counts[i]++
+	c.mu.Unlock()
+}
+
+// SizeHistogram counts accepted records by message size over the last
+// minute. Counts[i] is the number of records of up to Bounds[i] bytes and over
+// the previous bound; the last count is for records over the last bound.
+type SizeHistogram struct {
+	Bounds []int
+	Counts []int64
+}
+
+// Quantile returns the bound that a fraction q of records are within, or -1
+// if that is over the last bound, which helps picking byte-based batch limits.
+// It returns 0 for an empty histogram.
+func (h SizeHistogram) Quantile(q float64) int {
+	var total int64
+	for _, c := range h.Counts {
+		total += c
+	}
+	if total == 0 {
+		return 0
+	}
+
+	var seen int64
+	for i, c := range h.Counts {
+		seen += c
+		if float64(seen) >= q*float64(total) {
+			if i < len(h.Bounds) {
+				return h.Bounds[i]
+			}
+			break
+		}
+	}
+
+	return -1
+}
+
+// RecordSizes returns the message size histogram of the records accepted in
+// the last minute.
+func (s *Service) RecordSizes() SizeHistogram {
+	n := s.clock.Now().UnixNano() / int64(sizeWindow)
+	h := SizeHistogram{
+		Bounds: append([]int(nil), sizeBounds[:]...),
+		Counts: make([]int64, len(sizeBounds)+1),
+	}
+
+	s.sizes.mu.Lock()
+	defer s.sizes.mu.Unlock()
+
+	for _, w := range s.sizes.windows {
+		if w.n > n-sizeWindows {
+			for i, c := range w.counts {
+				h.Counts[i] += c
+			}
+		}
+	}
+
+	return h
+}
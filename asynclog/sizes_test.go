@@ -0,0 +1,41 @@
+package asynclog_test
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"test-task-log/asynclog"
+)
+
+func TestRecordSizes(t *testing.T) {
+	s, _, clock := start(t, asynclog.WithFlushInterval(time.Hour))
+
+	if got := s.RecordSizes().Quantile(0.5); got != 0 {
+		t.Fatalf("got median %d with nothing logged, want 0", got)
+	}
+
+	printAll(t, s, "short", strings.Repeat("x", 100), strings.Repeat("x", 128), strings.Repeat("x", 100<<10))
+	h := s.RecordSizes()
+	want := make([]int64, len(h.Bounds)+1)
+	want[0], want[1], want[len(want)-1] = 1, 2, 1
+	if !slices.Equal(h.Counts, want) {
+		t.Fatalf("got counts %v for bounds %v, want %v", h.Counts, h.Bounds, want)
+	}
+	if got := h.Quantile(0.5); got != 128 {
+		t.Fatalf("got median %d, want 128", got)
+	}
+	if got := h.Quantile(1); got != -1 {
+		t.Fatalf("got max %d, want -1 past the last bound", got)
+	}
+	if got := s.Stats().RecordSizes; !slices.Equal(got.Counts, want) {
+		t.Fatalf("got stats counts %v, want %v", got.Counts, want)
+	}
+
+	// Sizes age out after a minute.
+	clock.Advance(time.Minute)
+	if got := s.RecordSizes().Quantile(0.5); got != 0 {
+		t.Fatalf("got median %d a minute later, want 0", got)
+	}
+}
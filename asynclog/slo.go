@@ -0,0 +1,111 @@
+package asynclog
+
+import (
+	"slices"
+	"sync"
+	"time"
+)
+
+// sloSamples is how many recent flush latencies the p99 is computed over.
+const sloSamples = 256
+
+// SLO sets thresholds on pipeline latency; zero disables a threshold.
+type SLO struct {
+	// FlushLatencyP99 bounds the 99th percentile of the time batch writes
+	// take, over the last 256 batches.
+	FlushLatencyP99 time.Duration
+	// MaxPendingAge bounds how long the oldest buffered record has been
+	// waiting, checked on every Run tick.
+	MaxPendingAge time.Duration
+}
+
+// SLOAlert reports a threshold being breached, or recovering when Breached is
+// false.
+type SLOAlert struct {
+	Metric    string // "flush_latency_p99" or "pending_age"
+	Value     time.Duration
+	Threshold time.Duration
+	Breached  bool
+	At        time.Time
+}
+
+type sloMonitor struct {
+	mu        sync.Mutex
+	slo       SLO
+	alert     func(SLOAlert)
+	latencies []time.Duration
+	next      int
+	breached  map[string]bool
+}
+
+func newSLOMonitor(slo SLO, alert func(SLOAlert)) *sloMonitor {
+	return &sloMonitor{
+		slo:       slo,
+		alert:     alert,
+		latencies: make([]time.Duration, 0, sloSamples),
+		breached:  make(map[string]bool),
+	}
+}
+
+func (m *sloMonitor) observeFlush(d time.Duration, now time.Time) {
+	if m == nil || m.slo.FlushLatencyP99 <= 0 {
+		return
+	}
+
+	m.mu.Lock()
+	if len(m.latencies) < sloSamples {
+		m.latencies = append(m.latencies, d)
+	} else {
+		m.latencies[m.next] = d
+		m.next = (m.next + 1) % sloSamples
+	}
+
+	sorted := slices.Clone(m.latencies)
+	m.mu.Unlock()
+
+	slices.Sort(sorted)
+	p99 := sorted[(len(sorted)*99+99)/100-1]
+
+	m.check("flush_latency_p99", p99, m.slo.FlushLatencyP99, now)
+}
+
+func (m *sloMonitor) observePending(age time.Duration, now time.Time) {
+	if m == nil || m.slo.MaxPendingAge <= 0 {
+		return
+	}
+
+	m.check("pending_age", age, m.slo.MaxPendingAge, now)
+}
+
+// check fires an alert when metric crosses its threshold either way, now
+// being the time of the measurement.
+func (m *sloMonitor) check(metric string, value, threshold time.Duration, now time.Time) {
+	breached := value > threshold
+
+	m.mu.Lock()
+	changed := m.breached[metric] != breached
+	m.breached[metric] = breached
+	m.mu.Unlock()
+
+	if changed {
+		m.alert(SLOAlert{Metric: metric, Value: value, Threshold: threshold, Breached: breached, At: now})
+	}
+}
+
+// pendingAge returns how long the oldest buffered record has been waiting.
+func (s *Service) pendingAge(now time.Time) time.Duration {
+	var oldest time.Time
+	for _, b := range s.buffers() {
+		for _, rec := range b.records {
+			if oldest.IsZero() || rec.time.Before(oldest) {
+				oldest = rec.time
+			}
+		}
+	}
+
+	if oldest.IsZero() {
+		return 0
+	}
+
+	return now.Sub(oldest)
+}
@@ -0,0 +1,80 @@
+package asynclog_test
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"test-task-log/asynclog"
+)
+
+// slowWriter takes delay to write while slow is set.
+type slowWriter struct {
+	slow  atomic.Bool
+	delay time.Duration
+}
+
+func (w *slowWriter) Write(p []byte) (int, error) {
+	if w.slow.Load() {
+		time.Sleep(w.delay)
+	}
+	return len(p), nil
+}
+
+func TestSLOPendingAge(t *testing.T) {
+	alerts := make(chan asynclog.SLOAlert, 10)
+	s, _, clock := start(t, asynclog.WithSLO(asynclog.SLO{MaxPendingAge: time.Second}, func(a asynclog.SLOAlert) {
+		alerts <- a
+	}))
+
+	// The tick sees the record buffered for the whole flush interval before
+	// flushing it, and nothing pending on the next one.
+	printAll(t, s, "a")
+	waitBuffered(t, s)
+	clock.Advance(5 * time.Second)
+	if a := <-alerts; a.Metric != "pending_age" || !a.Breached || a.Value != 5*time.Second || !a.At.Equal(clock.Now()) {
+		t.Fatalf("got %+v, want pending_age breached at 5s on the service clock", a)
+	}
+	clock.Advance(5 * time.Second)
+	if a := <-alerts; a.Metric != "pending_age" || a.Breached {
+		t.Fatalf("got %+v, want pending_age recovered", a)
+	}
+}
+
+func TestSLOFlushLatency(t *testing.T) {
+	w := &slowWriter{delay: 20 * time.Millisecond}
+	alerts := make(chan asynclog.SLOAlert, 10)
+	s := asynclog.NewService(w, asynclog.WithBatchSize(1), asynclog.WithSLO(asynclog.SLO{FlushLatencyP99: 10 * time.Millisecond}, func(a asynclog.SLOAlert) {
+		alerts <- a
+	}))
+	go s.Run(context.Background())
+	t.Cleanup(func() { s.Shutdown(context.Background()) })
+
+	w.slow.Store(true)
+	if err := s.PrintSync(testContext(t), "slow"); err != nil {
+		t.Fatal(err)
+	}
+	if a := <-alerts; a.Metric != "flush_latency_p99" || !a.Breached || a.Value < w.delay {
+		t.Fatalf("got %+v, want flush_latency_p99 breached", a)
+	}
+
+	// A single slow write stays the p99 until it is one of a hundred.
+	w.slow.Store(false)
+	for range 98 {
+		if err := s.PrintSync(testContext(t), "fast"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	select {
+	case a := <-alerts:
+		t.Fatalf("got %+v with the slow write still the p99", a)
+	default:
+	}
+	if err := s.PrintSync(testContext(t), "fast"); err != nil {
+		t.Fatal(err)
+	}
+	if a := <-alerts; a.Metric != "flush_latency_p99" || a.Breached {
+		t.Fatalf("got %+v, want flush_latency_p99 recovered", a)
+	}
+}
@@ -0,0 +1,145 @@
+package asynclog
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// NewSlog returns a slog.Logger logging through a running Service, built
+// with opts, to stdout or the writer set with WithWriter, and the service's
+// Shutdown, which writes out what is queued. Records are logged by a
+// SlogHandler with nil options and encoded as the service encodes entries.
+func NewSlog(opts ...Option) (*slog.Logger, func(ctx context.Context) error) {
+	s := NewService(os.Stdout, opts...)
+	go s.Run(context.Background())
+
+	return slog.New(NewSlogHandler(s, nil)), s.Shutdown
+}
+
+// SlogHandler is a slog.Handler logging records as entries of a Service, so
+// code using log/slog goes through the service's batching unchanged, e.g.
+// with slog.SetDefault(slog.New(NewSlogHandler(s, nil))).
+//
+// slog levels map to the nearest level at or below them, so LevelInfo gets
+// slog.LevelInfo up to slog.LevelWarn exclusive. Attributes become fields,
+// resolved, with the keys of grouped attributes prefixed by the groups
+// joined with dots, such as "req.method". With AddSource the caller's
+// file:line is added as the field "caller". Records with a zero time are
+// stamped when enqueued, like any Entry. Handle returns the error of
+// Service.Print.
+type SlogHandler struct {
+	s      *Service
+	opts   slog.HandlerOptions
+	fields []Field
+	groups []string
+}
+
+// NewSlogHandler returns a handler logging to s. A nil opts logs records at
+// slog.LevelInfo and above.
+func NewSlogHandler(s *Service, opts *slog.HandlerOptions) *SlogHandler {
+	h := &SlogHandler{s: s}
+	if opts != nil {
+		h.opts = *opts
+	}
+
+	return h
+}
+
+func (h *SlogHandler) Enabled(_ context.Context, level slog.Level) bool {
+	minLevel := slog.LevelInfo
+	if h.opts.Level != nil {
+		minLevel = h.opts.Level.Level()
+	}
+
+	return level >= minLevel
+}
+
+func (h *SlogHandler) Handle(ctx context.Context, r slog.Record) error {
+	fields := slices.Clip(h.fields)
+	if h.opts.AddSource && r.PC != 0 {
+		f, _ := runtime.CallersFrames([]uintptr{r.PC}).Next()
+		fields = append(fields, Field{Key: "caller", Value: f.File + ":" + strconv.Itoa(f.Line)})
+	}
+	r.Attrs(func(a slog.Attr) bool {
+		fields = h.appendAttr(fields, h.groups, a)
+		return true
+	})
+
+	e := Entry{Time: r.Time, Level: slogLevel(r.Level), Message: r.Message, Fields: fields}
+
+	return h.s.enqueue(ctx, e.record())
+}
+
+func (h *SlogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+
+	h2 := *h
+	h2.fields = slices.Clip(h.fields)
+	for _, a := range attrs {
+		h2.fields = h.appendAttr(h2.fields, h.groups, a)
+	}
+
+	return &h2
+}
+
+func (h *SlogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+
+	h2 := *h
+	h2.groups = append(slices.Clip(h.groups), name)
+
+	return &h2
+}
+
+// appendAttr appends a, within groups, to fields, flattening groups and
+// dropping empty attributes as slog handlers should.
+func (h *SlogHandler) appendAttr(fields []Field, groups []string, a slog.Attr) []Field {
+	a.Value = a.Value.Resolve()
+	if h.opts.ReplaceAttr != nil && a.Value.Kind() != slog.KindGroup {
+		a = h.opts.ReplaceAttr(groups, a)
+		a.Value = a.Value.Resolve()
+	}
+	if a.Equal(slog.Attr{}) {
+		return fields
+	}
+
+	if a.Value.Kind() == slog.KindGroup {
+		if a.Key != "" {
+			groups = append(slices.Clip(groups), a.Key)
+		}
+		for _, ga := range a.Value.Group() {
+			fields = h.appendAttr(fields, groups, ga)
+		}
+		return fields
+	}
+
+	key := a.Key
+	if len(groups) > 0 {
+		key = strings.Join(groups, ".") + "." + key
+	}
+
+	return append(fields, Field{Key: key, Value: a.Value.Any()})
+}
+
+// slogLevel maps a slog level to the nearest Level at or below it.
+func slogLevel(l slog.Level) Level {
+	switch {
+	case l >= slog.LevelError:
+		return LevelError
+	case l >= slog.LevelWarn:
+		return LevelWarn
+	case l >= slog.LevelInfo:
+		return LevelInfo
+	}
+
+	return LevelDebug
+}
@@ -0,0 +1,120 @@
+package asynclog_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"test-task-log/asynclog"
+	"test-task-log/asynclog/testutil"
+)
+
+func TestNewSlog(t *testing.T) {
+	sink := testutil.NewSink()
+	logger, shutdown := asynclog.NewSlog(asynclog.WithWriter(sink))
+
+	logger.Info("started", "port", 8080)
+	if err := shutdown(testContext(t)); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := sink.Lines(), []string{"started port=8080"}; !slices.Equal(got, want) {
+		t.Fatalf("got lines %q, want %q", got, want)
+	}
+}
+
+func TestNewSlogOptions(t *testing.T) {
+	enc := &captureEncoder{}
+	logger, shutdown := asynclog.NewSlog(asynclog.WithWriter(testutil.NewSink()), asynclog.WithEncoder(enc), asynclog.WithMinLevel(asynclog.LevelWarn))
+
+	logger.Info("dropped")
+	logger.Warn("kept", "n", 1)
+	if err := shutdown(testContext(t)); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(enc.entries) != 1 || enc.entries[0].Message != "kept" || enc.entries[0].Level != asynclog.LevelWarn {
+		t.Fatalf("got entries %+v, want the warning encoded by the service", enc.entries)
+	}
+}
+
+func TestNewSlogShutdownTimeout(t *testing.T) {
+	w := gateWriter{release: make(chan struct{})}
+	defer close(w.release)
+	logger, shutdown := asynclog.NewSlog(asynclog.WithWriter(w))
+
+	logger.Info("stuck")
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := shutdown(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("got %v, want the context's error", err)
+	}
+}
+
+func TestSlogHandler(t *testing.T) {
+	enc := &captureEncoder{}
+	s, _, _ := start(t, asynclog.WithEncoder(enc))
+	logger := slog.New(asynclog.NewSlogHandler(s, &slog.HandlerOptions{
+		Level:     slog.LevelDebug,
+		AddSource: true,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == "password" {
+				return slog.String(a.Key, "***")
+			}
+			return a
+		},
+	}))
+
+	logger.Debug("d")
+	logger.Log(context.Background(), slog.LevelWarn+2, "w", "password", "hunter2")
+	logger.With("svc", "api").WithGroup("req").Error("e", "method", "GET", slog.Group("user", "id", 7), slog.Group("empty"))
+	if err := s.Flush(testContext(t)); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(enc.entries) != 3 {
+		t.Fatalf("got %d entries, want 3", len(enc.entries))
+	}
+	var got []string
+	for _, e := range enc.entries {
+		fields := []string{e.Level.String(), e.Message}
+		for _, f := range e.Fields {
+			if f.Key == "caller" {
+				if !strings.Contains(f.Value.(string), "slog_test.go:") {
+					t.Fatalf("got caller %v, want this file", f.Value)
+				}
+				continue
+			}
+			fields = append(fields, fmt.Sprintf("%s=%v", f.Key, f.Value))
+		}
+		got = append(got, strings.Join(fields, " "))
+	}
+	want := []string{"DEBUG d", "WARN w password=***", "ERROR e svc=api req.method=GET req.user.id=7"}
+	if !slices.Equal(got, want) {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
+
+func TestSlogHandlerLevelAndClosed(t *testing.T) {
+	s, sink, _ := start(t)
+	h := asynclog.NewSlogHandler(s, nil)
+	if h.Enabled(context.Background(), slog.LevelDebug) || !h.Enabled(context.Background(), slog.LevelInfo) {
+		t.Fatal("a nil opts handler doesn't log from slog.LevelInfo up")
+	}
+
+	if err := s.Shutdown(testContext(t)); err != nil {
+		t.Fatal(err)
+	}
+	r := slog.NewRecord(time.Now(), slog.LevelInfo, "late", 0)
+	if err := h.Handle(context.Background(), r); !errors.Is(err, asynclog.ErrClosed) {
+		t.Fatalf("got %v, want ErrClosed", err)
+	}
+	if got := sink.Lines(); len(got) != 0 {
+		t.Fatalf("got lines %q after Shutdown", got)
+	}
+}
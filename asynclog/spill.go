@@ -0,0 +1,357 @@
+package asynclog
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	// spillExt is the extension of spill segment files.
+	spillExt = ".spill"
+	// spillSegment is the size past which spilled records go to a new
+	// segment.
+	spillSegment = 1 << 20
+)
+
+// errSpillFull is returned by spill.write once the directory is at its limit.
+var errSpillFull = errors.New("asynclog: spill directory full")
+
+// WithSpill spills records to segment files in dir when the queue is full,
+// instead of blocking producers, and spills the records of batches that
+// failed to write, after any WithRetry attempts. Spilled records are
+// replayed through the pipeline, oldest segment first and one at a time,
+// every flush interval while the writer is healthy, as last seen by a write
+// or a WithHealthProbe probe, and the queue is less than half full. A
+// segment is only removed once its records are written, so they survive
+// overload, outages and restarts. Replayed records may come after newer
+// ones, and records of a batch that only some sinks failed to take are
+// written to the others again.
+//
+// The segments take up at most maxBytes; records that don't fit are dropped
+// as the overflow policy says, OverflowDropNewest unless set otherwise.
+// Tracked records are never spilled. Failed batches aren't spilled under
+// WithRetryQueue, which retries them instead. It panics if maxBytes is not
+// positive.
+func WithSpill(dir string, maxBytes int64) Option {
+	if maxBytes <= 0 {
+		panic(fmt.Sprintf("asynclog: WithSpill: non-positive size %d", maxBytes))
+	}
+
+	return func(s *Service) {
+		s.spill = &spill{dir: dir, max: maxBytes}
+	}
+}
+
+// spill is a directory of segment files, each holding one JSON entry per
+// line, named by a sequence number so they replay in order. Records are
+// appended to the newest segment until it reaches spillSegment.
+type spill struct {
+	dir string
+	max int64
+
+	mu     sync.Mutex
+	loaded bool
+	next   uint64
+	used   int64
+	sizes  map[string]int64
+	busy   bool // a replay has taken the oldest segment
+	cur    *os.File
+}
+
+// load scans dir for segments left by an earlier run, creating it if needed.
+func (sp *spill) load() error {
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+
+	if sp.loaded {
+		return nil
+	}
+	sp.sizes = make(map[string]int64)
+
+	if err := os.MkdirAll(sp.dir, 0o755); err != nil {
+		return fmt.Errorf("asynclog: spill: %w", err)
+	}
+
+	des, err := os.ReadDir(sp.dir)
+	if err != nil {
+		return fmt.Errorf("asynclog: spill: %w", err)
+	}
+
+	for _, de := range des {
+		seq, ok := spillSeq(de.Name())
+		if !ok {
+			continue
+		}
+		info, err := de.Info()
+		if err != nil {
+			continue
+		}
+
+		sp.sizes[de.Name()] = info.Size()
+		sp.used += info.Size()
+		sp.next = max(sp.next, seq+1)
+	}
+	sp.loaded = true
+
+	return nil
+}
+
+func spillSeq(name string) (uint64, bool) {
+	num, ok := strings.CutSuffix(name, spillExt)
+	if !ok {
+		return 0, false
+	}
+
+	seq, err := strconv.ParseUint(num, 10, 64)
+
+	return seq, err == nil
+}
+
+// write appends recs to the newest segment, synced before it returns.
+func (sp *spill) write(recs []record) error {
+	var b []byte
+	for _, rec := range recs {
+		line, err := json.Marshal(rec.entry())
+		if err != nil {
+			return fmt.Errorf("asynclog: spill: %w", err)
+		}
+		b = append(append(b, line...), '\n')
+	}
+
+	if err := sp.load(); err != nil {
+		return err
+	}
+
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+
+	if sp.used+int64(len(b)) > sp.max {
+		return errSpillFull
+	}
+
+	if sp.cur != nil && sp.sizes[filepath.Base(sp.cur.Name())] >= spillSegment {
+		sp.seal()
+	}
+	if sp.cur == nil {
+		name := fmt.Sprintf("%020d%s", sp.next, spillExt)
+		f, err := os.OpenFile(filepath.Join(sp.dir, name), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+		if err != nil {
+			return fmt.Errorf("asynclog: spill: %w", err)
+		}
+		sp.next++
+		sp.cur = f
+		sp.sizes[name] = 0
+	}
+
+	n, err := sp.cur.Write(b)
+	if err == nil {
+		err = sp.cur.Sync()
+	}
+	sp.sizes[filepath.Base(sp.cur.Name())] += int64(n)
+	sp.used += int64(n)
+	if err != nil {
+		sp.seal()
+		return fmt.Errorf("asynclog: spill: %w", err)
+	}
+
+	return nil
+}
+
+// seal closes the newest segment, so the next write starts another. It is
+// called with mu held.
+func (sp *spill) seal() {
+	if sp.cur != nil {
+		sp.cur.Close()
+		sp.cur = nil
+	}
+}
+
+// close seals the newest segment at shutdown.
+func (sp *spill) close() {
+	sp.mu.Lock()
+	sp.seal()
+	sp.mu.Unlock()
+}
+
+// take reads the oldest segment, unless there is none or a replay is
+// already busy with it. done must be called with the outcome of the replay
+// if recs is not nil, even if err is set.
+func (sp *spill) take() (recs []record, done func(written bool), err error) {
+	sp.mu.Lock()
+	defer sp.mu.Unlock()
+
+	if sp.busy || len(sp.sizes) == 0 {
+		return nil, nil, nil
+	}
+
+	names := make([]string, 0, len(sp.sizes))
+	for name := range sp.sizes {
+		names = append(names, name)
+	}
+	name := slices.Min(names)
+	path := filepath.Join(sp.dir, name)
+	if sp.cur != nil && sp.cur.Name() == path {
+		sp.seal()
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		// Gone or unreadable, it would be retried forever.
+		sp.used -= sp.sizes[name]
+		delete(sp.sizes, name)
+		return nil, nil, fmt.Errorf("asynclog: spill: %w", err)
+	}
+	defer f.Close()
+
+	// A crash while writing a segment leaves its last line cut short, so
+	// lines that don't decode are skipped rather than failing the segment.
+	bad := 0
+	sc := bufio.NewScanner(f)
+	sc.Buffer(nil, 1<<24)
+	for sc.Scan() {
+		var e Entry
+		if json.Unmarshal(sc.Bytes(), &e) != nil {
+			bad++
+			continue
+		}
+		recs = append(recs, e.record())
+	}
+	if err := sc.Err(); err != nil {
+		return nil, nil, fmt.Errorf("asynclog: spill: %s: %w", name, err)
+	}
+	if bad > 0 {
+		err = fmt.Errorf("asynclog: spill: %s: skipped %d corrupt lines", name, bad)
+	}
+
+	sp.busy = true
+	done = func(written bool) {
+		sp.mu.Lock()
+		defer sp.mu.Unlock()
+
+		sp.busy = false
+		if written && os.Remove(path) == nil {
+			sp.used -= sp.sizes[name]
+			delete(sp.sizes, name)
+		}
+	}
+
+	return recs, done, err
+}
+
+// Spilled returns the number of spill segments waiting to be replayed under
+// WithSpill, and the bytes they take up.
+func (s *Service) Spilled() (segments int, bytes int64) {
+	if s.spill == nil {
+		return 0, 0
+	}
+	s.spill.load()
+
+	s.spill.mu.Lock()
+	defer s.spill.mu.Unlock()
+
+	return len(s.spill.sizes), s.spill.used
+}
+
+// spillOverflow spills the records the queue has no room for, dropping the
+// tracked ones and those the spill directory has no room for.
+func (s *Service) spillOverflow(recs []record) {
+	var tracked, untracked []record
+	for _, rec := range recs {
+		if rec.tracked != 0 {
+			tracked = append(tracked, rec)
+		} else {
+			untracked = append(untracked, rec)
+		}
+	}
+
+	if len(untracked) > 0 {
+		if err := s.spill.write(untracked); err != nil {
+			s.debugf("spilling %d records: %v", len(untracked), err)
+			tracked = append(tracked, untracked...)
+		}
+	}
+	if len(tracked) > 0 {
+		s.overflowed(tracked)
+	}
+}
+
+// spillFailed spills the records of a batch that failed to write.
+func (s *Service) spillFailed(recs []record) {
+	if err := s.spill.write(recs); err != nil {
+		s.debugf("spilling a failed batch of %d records: %v", len(recs), err)
+	}
+}
+
+// replay hands the oldest spill segment to a write goroutine, or to the
+// pipeline under WithEncodeAhead so it is written in turn with the other
+// batches, if the writer is healthy and the queue has room. It runs in Run.
+func (s *Service) replay() {
+	if s.spill == nil || s.health.get().LastError != nil || s.queue.size() > s.queueSize/2 {
+		return
+	}
+
+	recs, done, err := s.spill.take()
+	if err != nil {
+		s.debugf("%v", err)
+	}
+	if done == nil {
+		return
+	}
+	if len(recs) == 0 {
+		done(true)
+		return
+	}
+	s.debugf("replaying %d spilled records", len(recs))
+	s.event(EventFlush, "spill", recs)
+
+	if s.encodeCh != nil {
+		parts := s.split(recs)
+		errc := make(chan error, len(parts))
+		for _, part := range parts {
+			s.pipeline(part, errc, true)
+		}
+
+		s.bufferWg.Add(1)
+		go func() {
+			defer s.bufferWg.Done()
+
+			written := true
+			for range parts {
+				if <-errc != nil {
+					written = false
+				}
+			}
+			done(written)
+		}()
+		return
+	}
+
+	var batches []outgoing
+	for _, part := range s.split(recs) {
+		o := s.prepare(part)
+		// Left in the segment if they fail again, rather than spilled anew.
+		o.recs = nil
+		batches = append(batches, o)
+	}
+
+	n := int64(len(recs))
+	s.inflight.Add(n)
+	s.spawn(func() {
+		written := true
+		for _, o := range batches {
+			if s.send(o) != nil {
+				written = false
+			}
+		}
+		done(written)
+		s.inflight.Add(-n)
+	})
+}
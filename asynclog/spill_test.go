@@ -0,0 +1,188 @@
+package asynclog_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"test-task-log/asynclog"
+	"test-task-log/asynclog/testutil"
+)
+
+func waitSpilled(t *testing.T, s *asynclog.Service, want int) {
+	t.Helper()
+
+	ctx := testContext(t)
+	for {
+		segments, _ := s.Spilled()
+		if segments == want {
+			return
+		}
+		select {
+		case <-ctx.Done():
+			t.Fatalf("%d spill segments, want %d", segments, want)
+		case <-time.After(time.Millisecond):
+		}
+	}
+}
+
+func TestSpillReplaysFailedBatch(t *testing.T) {
+	s, sink, clock := start(t, asynclog.WithBatchSize(1), asynclog.WithSpill(t.TempDir(), 1<<20))
+
+	sink.Fail(errors.New("down"))
+	printAll(t, s, "a")
+	waitSpilled(t, s, 1)
+
+	// A successful write marks the writer healthy, and the next tick
+	// replays the spilled record.
+	sink.Fail(nil)
+	printAll(t, s, "b")
+	if err := sink.WaitLines(testContext(t), 1); err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(5 * time.Second)
+	if err := sink.WaitLines(testContext(t), 2); err != nil {
+		t.Fatal(err)
+	}
+	waitSpilled(t, s, 0)
+
+	if got, want := sink.Lines(), []string{"b", "a"}; !slices.Equal(got, want) {
+		t.Fatalf("got lines %q, want %q", got, want)
+	}
+}
+
+func TestSpillFull(t *testing.T) {
+	s, sink, _ := start(t, asynclog.WithBatchSize(1), asynclog.WithSpill(t.TempDir(), 8))
+
+	sink.Fail(errors.New("down"))
+	printAll(t, s, "a record too long for the spill directory")
+
+	ctx := testContext(t)
+	for s.Stats().FailedRecords < 1 {
+		select {
+		case <-ctx.Done():
+			t.Fatal("the batch never failed")
+		case <-time.After(time.Millisecond):
+		}
+	}
+	if segments, used := s.Spilled(); segments != 0 || used != 0 {
+		t.Fatalf("got %d segments of %d bytes, want nothing spilled past the limit", segments, used)
+	}
+}
+
+func TestSpillCorruptSegment(t *testing.T) {
+	dir := t.TempDir()
+	segment := `{"Message":"kept"}` + "\n" + `{"Messa` + "\n"
+	if err := os.WriteFile(filepath.Join(dir, "00000000000000000000.spill"), []byte(segment), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// The cut short line a crash leaves behind is skipped.
+	s, sink, clock := start(t, asynclog.WithSpill(dir, 1<<20))
+	clock.Advance(5 * time.Second)
+	if err := sink.WaitLines(testContext(t), 1); err != nil {
+		t.Fatal(err)
+	}
+	waitSpilled(t, s, 0)
+	if got, want := sink.Lines(), []string{"kept"}; !slices.Equal(got, want) {
+		t.Fatalf("got lines %q, want %q", got, want)
+	}
+}
+
+// turnWriter fails writes while failing is set, and otherwise lets one
+// write through per token sent on turns, tracking how many run at once.
+type turnWriter struct {
+	turns   chan struct{}
+	failing atomic.Bool
+
+	mu     sync.Mutex
+	active int
+	peak   int
+	lines  []string
+}
+
+func (w *turnWriter) Write(p []byte) (int, error) {
+	if w.failing.Load() {
+		return 0, errors.New("down")
+	}
+
+	w.mu.Lock()
+	w.active++
+	w.peak = max(w.peak, w.active)
+	w.mu.Unlock()
+
+	<-w.turns
+
+	w.mu.Lock()
+	w.active--
+	w.lines = append(w.lines, strings.TrimSuffix(string(p), "\n"))
+	w.mu.Unlock()
+	return len(p), nil
+}
+
+func (w *turnWriter) writing() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return w.active
+}
+
+func TestSpillReplayEncodeAhead(t *testing.T) {
+	w := &turnWriter{turns: make(chan struct{})}
+	clock := testutil.NewClock(time.Time{})
+	s := asynclog.NewService(w, asynclog.WithClock(clock), asynclog.WithBatchSize(1), asynclog.WithEncodeAhead(), asynclog.WithSpill(t.TempDir(), 1<<20))
+	go s.Run(context.Background())
+	if err := clock.WaitTickers(testContext(t), 1); err != nil {
+		t.Fatal(err)
+	}
+
+	w.failing.Store(true)
+	printAll(t, s, "a")
+	waitSpilled(t, s, 1)
+
+	w.failing.Store(false)
+	printAll(t, s, "b")
+	w.turns <- struct{}{}
+
+	// With c being written, the replay waits its turn in the pipeline
+	// rather than writing alongside it.
+	printAll(t, s, "c")
+	ctx := testContext(t)
+	for w.writing() == 0 {
+		select {
+		case <-ctx.Done():
+			t.Fatal("c was never written")
+		case <-time.After(time.Millisecond):
+		}
+	}
+	clock.Advance(5 * time.Second)
+	for s.Stats().Inflight < 2 {
+		select {
+		case <-ctx.Done():
+			t.Fatal("the spill was never replayed")
+		case <-time.After(time.Millisecond):
+		}
+	}
+	w.turns <- struct{}{}
+	w.turns <- struct{}{}
+	waitSpilled(t, s, 0)
+	if err := s.Shutdown(testContext(t)); err != nil {
+		t.Fatal(err)
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.peak != 1 {
+		t.Fatalf("got %d writes at once, want one", w.peak)
+	}
+	if want := []string{"b", "c", "a"}; !slices.Equal(w.lines, want) {
+		t.Fatalf("got lines %q, want %q", w.lines, want)
+	}
+}
@@ -0,0 +1,50 @@
+package asynclog
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"slices"
+)
+
+// NewSeveritySplit returns a service writing records below WARN to stdout and
+// the rest to stderr, as is commonly expected of twelve-factor apps. It is
+// built on sink routing: the main writer discards everything and the sinks
+// "low" and "high" are routed by level, so they can be rerouted or
+// removed like any other sink. WithWriter among opts sets a writer getting
+// every record, in addition to the split. It panics if opts already add a
+// sink named "low" or "high".
+func NewSeveritySplit(opts ...Option) *Service {
+	s := NewService(io.Discard, opts...)
+	if err := s.SplitBySeverity(LevelWarn, os.Stdout, os.Stderr); err != nil {
+		panic(fmt.Sprintf("asynclog: NewSeveritySplit: %v", err))
+	}
+
+	return s
+}
+
+// SplitBySeverity adds the sinks "low" and "high", routed to get the records
+// below at and at or above it respectively. Both are added at once, or
+// neither if one of the names is taken.
+func (s *Service) SplitBySeverity(at Level, below, above io.Writer) error {
+	split := []namedSink{
+		{name: "low", writer: below, route: MustCompileExpr("level < " + at.String())},
+		{name: "high", writer: above, route: MustCompileExpr("level >= " + at.String())},
+	}
+
+	s.writerMx.Lock()
+	defer s.writerMx.Unlock()
+
+	for _, sk := range split {
+		if slices.ContainsFunc(s.sinks, func(added namedSink) bool { return added.name == sk.name }) {
+			return fmt.Errorf("asynclog: sink %q already added", sk.name)
+		}
+	}
+	for _, sk := range split {
+		sk.writer = s.sinkWriter(sk.writer)
+		s.sinks = append(s.sinks, sk)
+		s.debugf("sink %q added", sk.name)
+	}
+
+	return nil
+}
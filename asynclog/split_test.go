@@ -0,0 +1,62 @@
+package asynclog_test
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"test-task-log/asynclog"
+	"test-task-log/asynclog/testutil"
+)
+
+func TestSplitBySeverity(t *testing.T) {
+	s, main, _ := start(t)
+	low, high := testutil.NewSink(), testutil.NewSink()
+	if err := s.SplitBySeverity(asynclog.LevelWarn, low, high); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, r := range []struct {
+		level asynclog.Level
+		msg   string
+	}{
+		{asynclog.LevelDebug, "d"},
+		{asynclog.LevelInfo, "i"},
+		{asynclog.LevelWarn, "w"},
+		{asynclog.LevelError, "e"},
+	} {
+		if err := s.PrintFrom(context.Background(), "", r.level, r.msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Barrier(testContext(t)); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, tc := range map[string]struct {
+		sink *testutil.Sink
+		want []string
+	}{
+		"main": {main, []string{"d", "i", "w", "e"}},
+		"low":  {low, []string{"d", "i"}},
+		"high": {high, []string{"w", "e"}},
+	} {
+		if got := tc.sink.Lines(); !slices.Equal(got, tc.want) {
+			t.Errorf("%s got %q, want %q", name, got, tc.want)
+		}
+	}
+}
+
+func TestSplitBySeverityTaken(t *testing.T) {
+	s, _, _ := start(t)
+	if err := s.AddSink("high", testutil.NewSink()); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.SplitBySeverity(asynclog.LevelWarn, testutil.NewSink(), testutil.NewSink()); err == nil {
+		t.Fatal("split with the sink name high taken")
+	}
+	if got, want := s.Sinks(), []string{"high"}; !slices.Equal(got, want) {
+		t.Fatalf("got sinks %q after the failed split, want %q", got, want)
+	}
+}
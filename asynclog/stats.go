@@ -0,0 +1,124 @@
+package asynclog
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Stats is a snapshot of the service's counters since it was created.
+type Stats struct {
+	// Received is the number of records accepted into the queue.
+	Received int64
+	// Dropped is the number of records dropped by the overflow policy,
+	// evicted by compaction or expired before being written.
+	Dropped int64
+	// Filtered, Sampled and OverQuota are the records turned away before
+	// being queued: by the level threshold, WithFilter and WithFilters,
+	// Sample included, by WithAdaptiveSampling, and by WithSourceQuota.
+	Filtered  int64
+	Sampled   int64
+	OverQuota int64
+	// Batches is the number of batches written, and WriteErrors the number
+	// of those that failed.
+	Batches     int64
+	WriteErrors int64
+	// FailedRecords is the number of records in the batches that failed,
+	// lost unless spilled with WithSpill or queued with WithRetryQueue.
+	FailedRecords int64
+	// BytesWritten is the size of the batches written successfully.
+	BytesWritten int64
+	// FlushLatency is the time spent writing batches, successfully or not,
+	// and LastFlushLatency that of the last batch; FlushLatency/Batches is
+	// the mean.
+	FlushLatency     time.Duration
+	LastFlushLatency time.Duration
+	// Queued and Inflight are the records waiting in the queue and being
+	// written right now.
+	Queued   int
+	Inflight int64
+	// SampleRates are the current rates by source under
+	// WithAdaptiveSampling, nil without it.
+	SampleRates map[string]SampleRate
+	// Circuits are the circuit states under WithCircuitBreaker of the main
+	// writer, under "", and of every sink by name; nil without it.
+	Circuits map[string]CircuitState
+	// RecordSizes is the message size histogram of the records accepted in
+	// the last minute, as returned by Service.RecordSizes.
+	RecordSizes SizeHistogram
+}
+
+// StatsHook is told about what Stats counts as it happens, e.g. to update
+// Prometheus collectors. Its methods are called synchronously from producers,
+// Run and write goroutines, so they must be safe for concurrent use and must
+// not block.
+type StatsHook interface {
+	// RecordsReceived is called when n records are accepted into the queue.
+	RecordsReceived(n int)
+	// RecordsDropped is called when n records are dropped, with the reason
+	// "overflow", "compaction" or "expired".
+	RecordsDropped(n int, reason string)
+	// BatchWritten is called when a write of a batch of records, bytes long
+	// if written, completes.
+	BatchWritten(records, bytes int, latency time.Duration, err error)
+}
+
+// WithStatsHook calls h as Stats counters change.
+func WithStatsHook(h StatsHook) Option {
+	return func(s *Service) {
+		s.statsHook = h
+	}
+}
+
+// counters are the counters behind Stats not kept elsewhere.
+type counters struct {
+	received    atomic.Int64
+	records     atomic.Int64
+	batches     atomic.Int64
+	writeErrors atomic.Int64
+	failed      atomic.Int64
+	filtered    atomic.Int64
+	sampled     atomic.Int64
+	bytes       atomic.Int64
+	latency     atomic.Int64
+	last        atomic.Int64
+}
+
+func (c *counters) wrote(records, bytes int, latency time.Duration, err error) {
+	c.batches.Add(1)
+	if err != nil {
+		c.writeErrors.Add(1)
+		c.failed.Add(int64(records))
+	} else {
+		c.records.Add(int64(records))
+	}
+	c.bytes.Add(int64(bytes))
+	c.latency.Add(int64(latency))
+	c.last.Store(int64(latency))
+}
+
+// Stats returns the current counters.
+func (s *Service) Stats() Stats {
+	var rates map[string]SampleRate
+	if s.sampler != nil {
+		rates = s.sampler.snapshot()
+	}
+
+	return Stats{
+		Received:         s.stats.received.Load(),
+		Dropped:          s.dropped.Load() + s.compacted.Load() + s.expired.Load(),
+		Filtered:         s.stats.filtered.Load(),
+		Sampled:          s.stats.sampled.Load(),
+		OverQuota:        s.quotas.droppedCount(),
+		Batches:          s.stats.batches.Load(),
+		WriteErrors:      s.stats.writeErrors.Load(),
+		FailedRecords:    s.stats.failed.Load(),
+		BytesWritten:     s.stats.bytes.Load(),
+		FlushLatency:     time.Duration(s.stats.latency.Load()),
+		LastFlushLatency: time.Duration(s.stats.last.Load()),
+		Queued:           s.queue.size(),
+		Inflight:         s.inflight.Load(),
+		SampleRates:      rates,
+		Circuits:         s.circuits(),
+		RecordSizes:      s.RecordSizes(),
+	}
+}
@@ -0,0 +1,81 @@
+package asynclog_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"test-task-log/asynclog"
+	"test-task-log/asynclog/testutil"
+)
+
+// statsRecorder is a StatsHook keeping what it is told as strings.
+type statsRecorder struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (r *statsRecorder) add(format string, args ...any) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.events = append(r.events, fmt.Sprintf(format, args...))
+}
+
+func (r *statsRecorder) RecordsReceived(n int) { r.add("received %d", n) }
+
+func (r *statsRecorder) RecordsDropped(n int, reason string) { r.add("dropped %d %s", n, reason) }
+
+func (r *statsRecorder) BatchWritten(records, bytes int, _ time.Duration, err error) {
+	r.add("batch %d %d %v", records, bytes, err)
+}
+
+func (r *statsRecorder) Events() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return slices.Clone(r.events)
+}
+
+func TestStatsHook(t *testing.T) {
+	hook := &statsRecorder{}
+	sink := testutil.NewSink()
+	s := asynclog.NewService(sink, asynclog.WithChannelBuffer(2), asynclog.WithStatsHook(hook),
+		asynclog.WithOverflowPolicy(asynclog.OverflowDropNewest, 0))
+
+	printAll(t, s, "a", "b")
+	if err := s.Print(context.Background(), "c"); !errors.Is(err, asynclog.ErrQueueFull) {
+		t.Fatalf("got %v, want ErrQueueFull", err)
+	}
+	drain(t, s, sink)
+
+	want := []string{"received 1", "received 1", "dropped 1 overflow", "batch 2 4 <nil>"}
+	if got := hook.Events(); !slices.Equal(got, want) {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+	st := s.Stats()
+	if st.Received != 2 || st.Dropped != 1 || st.Batches != 1 || st.BytesWritten != 4 || st.WriteErrors != 0 {
+		t.Fatalf("got %+v, want 2 received, 1 dropped and one 4 byte batch", st)
+	}
+}
+
+func TestStatsHookWriteError(t *testing.T) {
+	hook := &statsRecorder{}
+	s, sink, _ := start(t, asynclog.WithStatsHook(hook), asynclog.WithBatchSize(1))
+
+	sink.Fail(errors.New("down"))
+	if err := s.PrintSync(testContext(t), "a"); err == nil {
+		t.Fatal("PrintSync succeeded on a failing writer")
+	}
+
+	if got := hook.Events(); len(got) != 2 || got[1] != "batch 1 0 down" {
+		t.Fatalf("got %q, want the failed batch reported", got)
+	}
+	if st := s.Stats(); st.WriteErrors != 1 || st.FailedRecords != 1 || st.BytesWritten != 0 {
+		t.Fatalf("got %+v, want the batch counted as failed", st)
+	}
+}
@@ -0,0 +1,258 @@
+package asynclog
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Syslog facilities used by default.
+const (
+	FacilityUser   = 1
+	FacilityLocal0 = 16
+)
+
+// syslogSeverity maps levels to RFC 5424 severities.
+func syslogSeverity(l Level) int {
+	switch l {
+	case LevelDebug:
+		return 7
+	case LevelWarn:
+		return 4
+	case LevelError:
+		return 3
+	default:
+		return 6
+	}
+}
+
+// SyslogEncoder writes one RFC 5424 message per line, such as
+//
+//	<14>1 2024-05-01T10:00:00Z host app - api - user logged in user=7
+//
+// with the entry's source as the MSGID and the text line as the message.
+// Empty Hostname and AppName default to the host name and the program name.
+type SyslogEncoder struct {
+	Facility int
+	Hostname string
+	AppName  string
+}
+
+func (enc SyslogEncoder) Encode(entries []Entry) ([]byte, error) {
+	host, app := syslogNames(enc.Hostname, enc.AppName)
+
+	var b bytes.Buffer
+	for _, e := range entries {
+		writeSyslogHeader(&b, enc.Facility*8+syslogSeverity(e.Level), e.Time, host, app, e.Source)
+		b.WriteString(e.record().line())
+		b.WriteByte('\n')
+	}
+
+	return b.Bytes(), nil
+}
+
+func syslogNames(host, app string) (string, string) {
+	if host == "" {
+		host, _ = os.Hostname()
+	}
+	if app == "" {
+		app = filepath.Base(os.Args[0])
+	}
+
+	return syslogNil(host), syslogNil(app)
+}
+
+// syslogNil returns s, or the syslog nil value "-" if s is empty.
+func syslogNil(s string) string {
+	if s == "" {
+		return "-"
+	}
+
+	return s
+}
+
+func writeSyslogHeader(b *bytes.Buffer, pri int, t time.Time, host, app, msgID string) {
+	if t.IsZero() {
+		t = time.Now()
+	}
+	fmt.Fprintf(b, "<%d>1 %s %s %s %d %s - ", pri, t.UTC().Format(time.RFC3339Nano), host, app, os.Getpid(), syslogNil(msgID))
+}
+
+// SyslogSink ships batches to a syslog collector over TCP, with octet
+// counting framing, or UDP, one datagram per message. Lines that already are
+// syslog messages, as written by SyslogEncoder, are sent as they are; other
+// lines are sent at the informational severity of the sink's facility. The
+// connection is dialled on the first write and again after a write fails.
+// Writes go through WriteContext, so WithWriteTimeout and WithDeliveryBudget
+// bound them on the connection.
+//
+// OpenSink builds one from URIs such as
+// syslog://collector:514?network=tcp&facility=16&app=api.
+type SyslogSink struct {
+	network string
+	addr    string
+	enc     SyslogEncoder
+
+	mu     sync.Mutex
+	conn   net.Conn
+	closed bool
+}
+
+// NewSyslogSink returns a sink writing to the collector at addr over network,
+// "tcp" or "udp", with enc's facility and names for lines that aren't syslog
+// messages yet.
+func NewSyslogSink(network, addr string, enc SyslogEncoder) (*SyslogSink, error) {
+	if network != "tcp" && network != "udp" {
+		return nil, fmt.Errorf("asynclog: syslog: unsupported network %q", network)
+	}
+
+	enc.Hostname, enc.AppName = syslogNames(enc.Hostname, enc.AppName)
+
+	return &SyslogSink{network: network, addr: addr, enc: enc}, nil
+}
+
+// Write sends every line of p as a message.
+func (ss *SyslogSink) Write(p []byte) (int, error) {
+	return ss.WriteContext(context.Background(), p)
+}
+
+// WriteContext is Write within ctx: the connection is dialled with it, its
+// deadline is the write deadline of the connection and cancelling it
+// unblocks a write in progress. Over UDP a failed datagram returns the bytes
+// of p sent before it, so a retry resumes from that line.
+func (ss *SyslogSink) WriteContext(ctx context.Context, p []byte) (int, error) {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	if ss.closed {
+		return 0, ErrClosed
+	}
+
+	if ss.conn == nil {
+		var d net.Dialer
+		conn, err := d.DialContext(ctx, ss.network, ss.addr)
+		if err != nil {
+			return 0, err
+		}
+		ss.conn = conn
+	}
+
+	// A zero deadline clears the one of the previous write.
+	deadline, _ := ctx.Deadline()
+	ss.conn.SetWriteDeadline(deadline)
+	conn := ss.conn
+	stop := context.AfterFunc(ctx, func() { conn.SetWriteDeadline(time.Unix(1, 0)) })
+	defer stop()
+
+	var out bytes.Buffer
+	var msg bytes.Buffer
+	sent := 0 // bytes of p whose lines have gone out as datagrams
+	for rest := p; len(rest) > 0; {
+		line, after, _ := bytes.Cut(rest, []byte("\n"))
+		end := len(p) - len(after)
+		rest = after
+
+		line = bytes.TrimSuffix(line, []byte("\r"))
+		if len(line) == 0 {
+			sent = end
+			continue
+		}
+
+		msg.Reset()
+		if !isSyslogMessage(line) {
+			writeSyslogHeader(&msg, ss.enc.Facility*8+syslogSeverity(LevelInfo), time.Now(), ss.enc.Hostname, ss.enc.AppName, "")
+		}
+		msg.Write(line)
+
+		if ss.network == "udp" {
+			if _, err := ss.conn.Write(msg.Bytes()); err != nil {
+				return sent, ss.fail(err)
+			}
+			sent = end
+			continue
+		}
+		out.WriteString(strconv.Itoa(msg.Len()))
+		out.WriteByte(' ')
+		out.Write(msg.Bytes())
+	}
+
+	if out.Len() > 0 {
+		if _, err := ss.conn.Write(out.Bytes()); err != nil {
+			return 0, ss.fail(err)
+		}
+	}
+
+	return len(p), nil
+}
+
+// fail drops the connection so the next write dials again.
+func (ss *SyslogSink) fail(err error) error {
+	ss.conn.Close()
+	ss.conn = nil
+
+	return err
+}
+
+// isSyslogMessage reports whether line starts with an RFC 5424 header.
+func isSyslogMessage(line []byte) bool {
+	end := bytes.IndexByte(line, '>')
+	if len(line) < 3 || line[0] != '<' || end < 2 || end > 4 {
+		return false
+	}
+	if _, err := strconv.Atoi(string(line[1:end])); err != nil {
+		return false
+	}
+
+	return bytes.HasPrefix(line[end+1:], []byte("1 "))
+}
+
+func (ss *SyslogSink) Close() error {
+	ss.mu.Lock()
+	defer ss.mu.Unlock()
+
+	if ss.closed {
+		return nil
+	}
+	ss.closed = true
+
+	if ss.conn == nil {
+		return nil
+	}
+
+	return ss.conn.Close()
+}
+
+func openSyslogSink(u *url.URL) (io.Writer, error) {
+	if err := checkParams(u, "network", "facility", "app"); err != nil {
+		return nil, err
+	}
+
+	if u.Host == "" {
+		return nil, fmt.Errorf("missing host")
+	}
+
+	q := u.Query()
+	enc := SyslogEncoder{Facility: FacilityUser, AppName: q.Get("app")}
+	if f := q.Get("facility"); f != "" {
+		n, err := strconv.Atoi(f)
+		if err != nil || n < 0 || n > 23 {
+			return nil, fmt.Errorf("invalid facility %q", f)
+		}
+		enc.Facility = n
+	}
+
+	network := q.Get("network")
+	if network == "" {
+		network = "udp"
+	}
+
+	return NewSyslogSink(network, u.Host, enc)
+}
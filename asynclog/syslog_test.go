@@ -0,0 +1,95 @@
+package asynclog_test
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"testing"
+
+	"test-task-log/asynclog"
+)
+
+func TestSyslogSinkTCP(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	got := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		var b strings.Builder
+		br := bufio.NewReader(conn)
+		for range 2 {
+			var n int
+			if _, err := fmt.Fscanf(br, "%d ", &n); err != nil {
+				break
+			}
+			msg := make([]byte, n)
+			if _, err := io.ReadFull(br, msg); err != nil {
+				break
+			}
+			b.Write(msg)
+			b.WriteByte('\n')
+		}
+		got <- b.String()
+	}()
+
+	ss, err := asynclog.NewSyslogSink("tcp", ln.Addr().String(), asynclog.SyslogEncoder{Facility: asynclog.FacilityUser, Hostname: "h", AppName: "app"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ss.Close()
+
+	if _, err := ss.Write([]byte("a\n<11>1 2024-01-01T00:00:00Z h app 1 - - b\n")); err != nil {
+		t.Fatal(err)
+	}
+
+	out := <-got
+	lines := strings.Split(strings.TrimSuffix(out, "\n"), "\n")
+	if len(lines) != 2 || !strings.HasPrefix(lines[0], "<14>1 ") || !strings.HasSuffix(lines[0], " a") || !strings.HasPrefix(lines[1], "<11>1 ") {
+		t.Fatalf("got messages %q, want a with an informational header and b as it was", lines)
+	}
+}
+
+func TestSyslogSinkUDPPartialWrite(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+
+	ss, err := asynclog.NewSyslogSink("udp", pc.LocalAddr().String(), asynclog.SyslogEncoder{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ss.Close()
+
+	// The second line is over the largest UDP datagram, so it fails after the
+	// first one went out.
+	p := []byte("a\n" + strings.Repeat("x", 70000) + "\nc\n")
+	n, err := ss.Write(p)
+	if err == nil {
+		t.Fatal("oversized datagram sent")
+	}
+	if n != 2 {
+		t.Fatalf("got %d bytes written, want 2 for the line sent", n)
+	}
+
+	buf := make([]byte, 1024)
+	m, _, err := pc.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.HasSuffix(buf[:m], []byte(" a")) {
+		t.Fatalf("got datagram %q, want a", buf[:m])
+	}
+}
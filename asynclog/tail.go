@@ -0,0 +1,125 @@
+package asynclog
+
+import (
+	"context"
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+	"strings"
+	"time"
+)
+
+// tailPoll is how often Tail checks the file for new data, truncation and
+// rotation.
+const tailPoll = 250 * time.Millisecond
+
+// Tail follows the file at path like tail -F and enqueues its lines at
+// LevelInfo with the path as the source, until ctx is done or the service
+// closes, returning ErrClosed. It starts at the end of the file unless
+// fromStart is set. A line without its newline yet is held back until it
+// comes, or logged as it is past 64KiB, as by Writer. A truncated file is read again from the start; when the
+// file is rotated, the rest of the old one is read before switching to the
+// new one, which is waited for if it doesn't exist yet.
+func (s *Service) Tail(ctx context.Context, path string, fromStart bool) error {
+	return s.tail(ctx, path, fromStart, func(line string) (record, bool) {
+		return record{level: LevelInfo, source: path, msg: line}, true
+	})
+}
+
+// tail is Tail with lines turned into records by parse, which can reject a
+// line by returning false.
+func (s *Service) tail(ctx context.Context, path string, fromStart bool, parse func(string) (record, bool)) error {
+	var (
+		f       *os.File
+		offset  int64
+		partial string
+		buf     = make([]byte, 32*1024)
+	)
+	defer func() {
+		if f != nil {
+			f.Close()
+		}
+	}()
+
+	t := time.NewTicker(tailPoll)
+	defer t.Stop()
+
+	for {
+		if f == nil {
+			var err error
+			f, err = os.Open(path)
+			switch {
+			case errors.Is(err, fs.ErrNotExist):
+				f = nil
+			case err != nil:
+				return err
+			case !fromStart:
+				if offset, err = f.Seek(0, io.SeekEnd); err != nil {
+					return err
+				}
+			default:
+				offset = 0
+			}
+
+			// Files showing up later, after rotation, are new and read
+			// whole.
+			fromStart = true
+		}
+
+		if f != nil {
+			for {
+				n, err := f.Read(buf)
+				offset += int64(n)
+
+				lines := strings.Split(partial+string(buf[:n]), "\n")
+				partial = lines[len(lines)-1]
+				lines = lines[:len(lines)-1]
+				if len(partial) > maxWriterLine {
+					lines, partial = append(lines, partial), ""
+				}
+
+				var recs []record
+				for _, line := range lines {
+					if rec, ok := parse(strings.TrimSuffix(line, "\r")); ok {
+						recs = append(recs, rec)
+					}
+				}
+				if err := s.enqueueAll(ctx, recs); errors.Is(err, ErrClosed) {
+					return err
+				}
+
+				if err != nil && !errors.Is(err, io.EOF) {
+					return err
+				}
+				if err != nil || n == 0 {
+					break
+				}
+			}
+
+			cur, err := f.Stat()
+			if err != nil {
+				return err
+			}
+
+			switch fi, err := os.Stat(path); {
+			case err != nil || !os.SameFile(fi, cur):
+				// Rotated or removed: the old file has been read to the
+				// end, move on to whatever is at path now.
+				f.Close()
+				f, partial = nil, ""
+			case fi.Size() < offset:
+				if offset, err = f.Seek(0, io.SeekStart); err != nil {
+					return err
+				}
+				partial = ""
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-t.C:
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package asynclog_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"test-task-log/asynclog"
+)
+
+func appendFile(t *testing.T, path, data string) {
+	t.Helper()
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	if _, err := f.WriteString(data); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTail(t *testing.T) {
+	s, sink, _ := start(t, asynclog.WithBatchSize(1))
+	path := filepath.Join(t.TempDir(), "app.log")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- s.Tail(ctx, path, true) }()
+
+	// The file doesn't exist yet; Tail waits for it. The half line waits
+	// for its newline.
+	appendFile(t, path, "a\nb")
+	appendFile(t, path, "\n")
+	if err := sink.WaitLines(testContext(t), 2); err != nil {
+		t.Fatal(err)
+	}
+
+	// A truncated file is read again from the start.
+	if err := os.WriteFile(path, []byte("c\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := sink.WaitLines(testContext(t), 3); err != nil {
+		t.Fatal(err)
+	}
+
+	// After a rotation the new file is read whole.
+	if err := os.Rename(path, path+".1"); err != nil {
+		t.Fatal(err)
+	}
+	appendFile(t, path, "d\n")
+	if err := sink.WaitLines(testContext(t), 4); err != nil {
+		t.Fatal(err)
+	}
+
+	cancel()
+	if err := <-done; !errors.Is(err, context.Canceled) {
+		t.Fatalf("got %v, want context.Canceled", err)
+	}
+	if got, want := sink.Lines(), []string{"a", "b", "c", "d"}; !slices.Equal(got, want) {
+		t.Fatalf("got lines %q, want %q", got, want)
+	}
+}
+
+func TestTailClosed(t *testing.T) {
+	s, _, _ := start(t)
+	path := filepath.Join(t.TempDir(), "app.log")
+	if err := s.Shutdown(testContext(t)); err != nil {
+		t.Fatal(err)
+	}
+
+	appendFile(t, path, "late\n")
+	if err := s.Tail(testContext(t), path, true); !errors.Is(err, asynclog.ErrClosed) {
+		t.Fatalf("got %v, want ErrClosed", err)
+	}
+}
+
+func TestTailReadError(t *testing.T) {
+	s, _, _ := start(t)
+
+	// A directory opens fine but can't be read.
+	if err := s.Tail(testContext(t), t.TempDir(), true); err == nil || errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("got %v, want the read error", err)
+	}
+}
+
+func TestTailLongLine(t *testing.T) {
+	s, sink, _ := start(t, asynclog.WithBatchSize(1))
+	path := filepath.Join(t.TempDir(), "app.log")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go s.Tail(ctx, path, true)
+
+	// A line that never ends isn't held back for good, but logged in
+	// pieces past 64KiB.
+	long := strings.Repeat("x", 100<<10)
+	appendFile(t, path, long)
+	if err := sink.WaitLines(testContext(t), 1); err != nil {
+		t.Fatal(err)
+	}
+	appendFile(t, path, "\nnext\n")
+
+	wctx := testContext(t)
+	for got := sink.Lines(); got[len(got)-1] != "next"; got = sink.Lines() {
+		select {
+		case <-wctx.Done():
+			t.Fatalf("got %d lines, want next after the long one", len(got))
+		case <-time.After(time.Millisecond):
+		}
+	}
+
+	got := sink.Lines()
+	if len(got[0]) <= 64<<10 || strings.Join(got[:len(got)-1], "") != long {
+		t.Fatalf("got the long line in pieces of %d bytes, want it whole over pieces past 64KiB", lineLens(got[:len(got)-1]))
+	}
+}
+
+func lineLens(lines []string) []int {
+	lens := make([]int, len(lines))
+	for i, line := range lines {
+		lens[i] = len(line)
+	}
+
+	return lens
+}
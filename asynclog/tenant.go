@@ -0,0 +1,143 @@
+package asynclog
+
+import (
+	"bufio"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"slices"
+	"strings"
+)
+
+const tenantPrefix = "#tenant "
+
+// WithTenantKeys encrypts the records of every source with a key in keys,
+// treating sources as tenants: within each batch a tenant's records are
+// encoded together and sealed with AES-GCM under its 16, 24 or 32 byte key,
+// and written as one line
+//
+//	#tenant name=acme <base64 nonce and ciphertext>
+//
+// in place of the records. The seal also authenticates the tenant name, so a
+// block can't be passed off as another tenant's. Records of other sources
+// are written as usual, their lines starting with # escaped as in audit mode
+// so they can't pass for a block. DecryptTenantBatches turns the output back
+// into plain text for the tenants whose keys it is given. It panics on an
+// invalid key, and doesn't apply to raw writers or binary frames.
+func WithTenantKeys(keys map[string][]byte) Option {
+	aeads := make(map[string]cipher.AEAD, len(keys))
+	for tenant, key := range keys {
+		aeads[tenant] = newTenantAEAD(tenant, key)
+	}
+
+	return func(s *Service) {
+		s.tenants = aeads
+	}
+}
+
+func newTenantAEAD(tenant string, key []byte) cipher.AEAD {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		panic(fmt.Sprintf("asynclog: key for tenant %q: %v", tenant, err))
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		panic(fmt.Sprintf("asynclog: key for tenant %q: %v", tenant, err))
+	}
+
+	return aead
+}
+
+// splitTenants separates the records of tenants with a key from the rest,
+// keeping their order.
+func (s *Service) splitTenants(recs []record) (plain []record, tenants map[string][]record) {
+	for _, rec := range recs {
+		if _, ok := s.tenants[rec.source]; !ok {
+			plain = append(plain, rec)
+			continue
+		}
+
+		if tenants == nil {
+			tenants = make(map[string][]record)
+		}
+		tenants[rec.source] = append(tenants[rec.source], rec)
+	}
+
+	return plain, tenants
+}
+
+// sealTenants appends a sealed block with the records of each tenant to
+// payload.
+func (s *Service) sealTenants(payload []byte, tenants map[string][]record) ([]byte, error) {
+	names := make([]string, 0, len(tenants))
+	for name := range tenants {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	for _, name := range names {
+		p, err := s.encodeRecords(tenants[name])
+		if err != nil {
+			return nil, err
+		}
+
+		aead := s.tenants[name]
+		nonce := make([]byte, aead.NonceSize())
+		if _, err := rand.Read(nonce); err != nil {
+			return nil, err
+		}
+		sealed := aead.Seal(nonce, nonce, p, []byte(name))
+
+		payload = fmt.Appendf(payload, "%sname=%s %s\n", tenantPrefix, name, base64.StdEncoding.EncodeToString(sealed))
+	}
+
+	return payload, nil
+}
+
+// DecryptTenantBatches copies output written with WithTenantKeys from r to
+// w, replacing the blocks of tenants in keys with their records. Blocks of
+// other tenants are copied as they are.
+func DecryptTenantBatches(w io.Writer, r io.Reader, keys map[string][]byte) error {
+	aeads := make(map[string]cipher.AEAD, len(keys))
+	for tenant, key := range keys {
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return fmt.Errorf("asynclog: key for tenant %q: %w", tenant, err)
+		}
+		if aeads[tenant], err = cipher.NewGCM(block); err != nil {
+			return fmt.Errorf("asynclog: key for tenant %q: %w", tenant, err)
+		}
+	}
+
+	sc := bufio.NewScanner(r)
+	sc.Buffer(nil, 1<<24)
+	for n := 1; sc.Scan(); n++ {
+		line := sc.Text()
+		out := []byte(line + "\n")
+
+		if rest, ok := strings.CutPrefix(line, tenantPrefix+"name="); ok {
+			name, enc, _ := strings.Cut(rest, " ")
+			if aead, ok := aeads[name]; ok {
+				sealed, err := base64.StdEncoding.DecodeString(enc)
+				if err != nil || len(sealed) < aead.NonceSize() {
+					return fmt.Errorf("line %d: malformed block for tenant %q", n, name)
+				}
+
+				nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+				if out, err = aead.Open(nil, nonce, ciphertext, []byte(name)); err != nil {
+					return fmt.Errorf("line %d: block for tenant %q: %w", n, name, err)
+				}
+			}
+		}
+
+		if _, err := w.Write(out); err != nil {
+			return err
+		}
+	}
+
+	return sc.Err()
+}
@@ -0,0 +1,92 @@
+package asynclog_test
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"test-task-log/asynclog"
+	"test-task-log/asynclog/testutil"
+)
+
+func tenantOutput(t *testing.T, keys map[string][]byte) []byte {
+	t.Helper()
+
+	sink := testutil.NewSink()
+	s := asynclog.NewService(sink, asynclog.WithTenantKeys(keys))
+	go s.Run(context.Background())
+
+	ctx := context.Background()
+	for _, r := range []struct{ source, msg string }{{"acme", "acme secret"}, {"", "plain"}, {"globex", "globex secret"}} {
+		if err := s.PrintFrom(ctx, r.source, asynclog.LevelInfo, r.msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.Shutdown(testContext(t)); err != nil {
+		t.Fatal(err)
+	}
+
+	return bytes.Join(sink.Writes(), nil)
+}
+
+func TestTenantKeys(t *testing.T) {
+	acme, globex := bytes.Repeat([]byte{1}, 16), bytes.Repeat([]byte{2}, 32)
+	out := tenantOutput(t, map[string][]byte{"acme": acme, "globex": globex})
+
+	if bytes.Contains(out, []byte("secret")) {
+		t.Fatalf("tenant records in the clear: %q", out)
+	}
+
+	// Only the tenants whose keys are given are decrypted.
+	var plain bytes.Buffer
+	if err := asynclog.DecryptTenantBatches(&plain, bytes.NewReader(out), map[string][]byte{"acme": acme}); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(plain.String(), "\n"), "\n")
+	if len(lines) != 3 || lines[0] != "plain" || lines[1] != "acme secret" || !strings.HasPrefix(lines[2], "#tenant name=globex ") {
+		t.Fatalf("got %q, want plain, acme's record and globex's block", lines)
+	}
+}
+
+func TestTenantKeysWrongKey(t *testing.T) {
+	out := tenantOutput(t, map[string][]byte{"acme": bytes.Repeat([]byte{1}, 16)})
+
+	var plain bytes.Buffer
+	if err := asynclog.DecryptTenantBatches(&plain, bytes.NewReader(out), map[string][]byte{"acme": bytes.Repeat([]byte{9}, 16)}); err == nil {
+		t.Fatal("decrypted with the wrong key")
+	}
+	if err := asynclog.DecryptTenantBatches(&plain, bytes.NewReader(out), map[string][]byte{"acme": []byte("short")}); err == nil {
+		t.Fatal("accepted an invalid key")
+	}
+}
+
+func TestTenantKeysInvalidKey(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("WithTenantKeys accepted a 5 byte key")
+		}
+	}()
+
+	asynclog.WithTenantKeys(map[string][]byte{"acme": []byte("short")})
+}
+
+func TestTenantKeysForged(t *testing.T) {
+	acme := bytes.Repeat([]byte{1}, 16)
+
+	sink := testutil.NewSink()
+	s := asynclog.NewService(sink, asynclog.WithTenantKeys(map[string][]byte{"acme": acme}))
+	go s.Run(context.Background())
+	printAll(t, s, "#tenant name=acme <junk>")
+	if err := s.Shutdown(testContext(t)); err != nil {
+		t.Fatal(err)
+	}
+
+	var plain bytes.Buffer
+	if err := asynclog.DecryptTenantBatches(&plain, bytes.NewReader(bytes.Join(sink.Writes(), nil)), map[string][]byte{"acme": acme}); err != nil {
+		t.Fatal(err)
+	}
+	if got := plain.String(); got != "##tenant name=acme <junk>\n" {
+		t.Fatalf("got %q, want the record escaped", got)
+	}
+}
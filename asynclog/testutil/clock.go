@@ -0,0 +1,198 @@
+package testutil
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"test-task-log/asynclog"
+)
+
+// Clock is an asynclog.Clock whose time only moves with Advance, so flushes
+// on the interval happen exactly when a test says:
+//
+//	clock := testutil.NewClock(time.Time{})
+//	sink := testutil.NewSink()
+//	s := asynclog.NewService(sink, asynclog.WithClock(clock))
+//	go s.Run(ctx)
+//	clock.WaitTickers(ctx, 1)
+//	s.Print(ctx, "hello")
+//	clock.Advance(5 * time.Second)
+//	sink.WaitLines(ctx, 1)
+//
+// Like a time.Ticker, a ticker whose tick hasn't been received yet drops the
+// following ones, so Advance fires every ticker at most once: advance one
+// interval at a time, waiting for its effect, to get one flush per interval.
+type Clock struct {
+	mu      sync.Mutex
+	now     time.Time
+	tickers []*fakeTicker
+	timers  []fakeTimer
+	changed chan struct{} // closed and replaced when a ticker or timer is made
+}
+
+type fakeTimer struct {
+	at time.Time
+	c  chan time.Time
+}
+
+// NewClock returns a clock set to start, or to an arbitrary fixed time if
+// start is zero.
+func NewClock(start time.Time) *Clock {
+	if start.IsZero() {
+		start = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	}
+
+	return &Clock{now: start, changed: make(chan struct{})}
+}
+
+// Now returns the clock's time.
+func (c *Clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.now
+}
+
+// NewTicker returns a ticker firing as Advance passes every d.
+func (c *Clock) NewTicker(d time.Duration) asynclog.Ticker {
+	if d <= 0 {
+		panic("testutil: non-positive ticker interval")
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	t := &fakeTicker{clock: c, c: make(chan time.Time, 1), every: d, next: c.now.Add(d)}
+	c.tickers = append(c.tickers, t)
+	close(c.changed)
+	c.changed = make(chan struct{})
+
+	return t
+}
+
+// After returns a channel receiving the time once Advance passes d.
+func (c *Clock) After(d time.Duration) <-chan time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	ch := make(chan time.Time, 1)
+	if d <= 0 {
+		ch <- c.now
+		return ch
+	}
+	c.timers = append(c.timers, fakeTimer{at: c.now.Add(d), c: ch})
+	close(c.changed)
+	c.changed = make(chan struct{})
+
+	return ch
+}
+
+// Advance moves the clock forward by d, firing the tickers and timers that
+// are due.
+func (c *Clock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.now = c.now.Add(d)
+
+	for _, t := range c.tickers {
+		if t.stopped || t.next.After(c.now) {
+			continue
+		}
+		for !t.next.After(c.now) {
+			t.next = t.next.Add(t.every)
+		}
+		select {
+		case t.c <- c.now:
+		default:
+		}
+	}
+
+	timers := c.timers[:0]
+	for _, t := range c.timers {
+		if t.at.After(c.now) {
+			timers = append(timers, t)
+			continue
+		}
+		t.c <- c.now
+	}
+	c.timers = timers
+}
+
+// WaitTickers waits until at least n tickers are running, e.g. for Run to
+// have started before advancing the clock, or ctx is done.
+func (c *Clock) WaitTickers(ctx context.Context, n int) error {
+	for {
+		c.mu.Lock()
+		running := 0
+		for _, t := range c.tickers {
+			if !t.stopped {
+				running++
+			}
+		}
+		changed := c.changed
+		c.mu.Unlock()
+
+		if running >= n {
+			return nil
+		}
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return fmt.Errorf("testutil: %d of %d tickers: %w", running, n, ctx.Err())
+		}
+	}
+}
+
+// WaitTimers waits until at least n timers made by After are pending, e.g.
+// for Run to have armed one before advancing the clock past it, or ctx is
+// done.
+func (c *Clock) WaitTimers(ctx context.Context, n int) error {
+	for {
+		c.mu.Lock()
+		pending := len(c.timers)
+		changed := c.changed
+		c.mu.Unlock()
+
+		if pending >= n {
+			return nil
+		}
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return fmt.Errorf("testutil: %d of %d timers: %w", pending, n, ctx.Err())
+		}
+	}
+}
+
+type fakeTicker struct {
+	clock   *Clock
+	c       chan time.Time
+	every   time.Duration
+	next    time.Time
+	stopped bool
+}
+
+func (t *fakeTicker) C() <-chan time.Time { return t.c }
+
+func (t *fakeTicker) Reset(d time.Duration) {
+	if d <= 0 {
+		panic("testutil: non-positive ticker interval")
+	}
+
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+
+	t.every, t.next, t.stopped = d, t.clock.now.Add(d), false
+}
+
+func (t *fakeTicker) Stop() {
+	t.clock.mu.Lock()
+	defer t.clock.mu.Unlock()
+
+	t.stopped = true
+}
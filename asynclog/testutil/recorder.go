@@ -0,0 +1,198 @@
+// Package testutil helps testing code built on asynclog.
+package testutil
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"test-task-log/asynclog"
+)
+
+// Recorded is an event as recorded by a Recorder: its position in the
+// sequence and the virtual time it was recorded at.
+type Recorded struct {
+	asynclog.Event
+	Seq int
+	At  time.Duration
+}
+
+func (r Recorded) String() string {
+	s := fmt.Sprintf("#%d +%s %s", r.Seq, r.At, r.Kind)
+	if r.Reason != "" {
+		s += " (" + r.Reason + ")"
+	}
+	if r.Records > 0 {
+		s += fmt.Sprintf(" %d records", r.Records)
+	}
+	if len(r.Messages) > 0 {
+		s += fmt.Sprintf(" %q", r.Messages)
+	}
+	if r.Err != nil {
+		s += " err=" + r.Err.Error()
+	}
+
+	return s
+}
+
+// Recorder records the events of a service, stamped with a virtual time only
+// Advance moves, so a test can tell events apart by the step that caused
+// them regardless of how long the steps took:
+//
+//	rec := testutil.NewRecorder()
+//	s := asynclog.NewService(w, rec.Option())
+//	...
+//	rec.Wait(ctx, asynclog.EventWrite, 1)
+//	rec.AssertOrder(t, asynclog.EventEnqueue, asynclog.EventFlush, asynclog.EventWrite)
+type Recorder struct {
+	mu      sync.Mutex
+	now     time.Duration
+	events  []Recorded
+	changed chan struct{} // closed and replaced on every event
+}
+
+func NewRecorder() *Recorder {
+	return &Recorder{changed: make(chan struct{})}
+}
+
+// Option hooks the recorder into a service.
+func (r *Recorder) Option() asynclog.Option {
+	return asynclog.WithEventHook(r.record)
+}
+
+func (r *Recorder) record(e asynclog.Event) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.events = append(r.events, Recorded{Event: e, Seq: len(r.events), At: r.now})
+	close(r.changed)
+	r.changed = make(chan struct{})
+}
+
+// Advance moves the virtual time events are stamped with forward by d.
+func (r *Recorder) Advance(d time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.now += d
+}
+
+// Events returns the events recorded so far.
+func (r *Recorder) Events() []Recorded {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return append([]Recorded(nil), r.events...)
+}
+
+// Filter returns the events recorded so far of the given kinds.
+func (r *Recorder) Filter(kinds ...asynclog.EventKind) []Recorded {
+	var events []Recorded
+	for _, e := range r.Events() {
+		for _, k := range kinds {
+			if e.Kind == k {
+				events = append(events, e)
+				break
+			}
+		}
+	}
+
+	return events
+}
+
+// Reset forgets the events recorded so far, keeping the virtual time.
+func (r *Recorder) Reset() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.events = nil
+}
+
+// Wait waits until at least n events of kind have been recorded, or ctx is
+// done.
+func (r *Recorder) Wait(ctx context.Context, kind asynclog.EventKind, n int) error {
+	for {
+		r.mu.Lock()
+		count := 0
+		for _, e := range r.events {
+			if e.Kind == kind {
+				count++
+			}
+		}
+		changed := r.changed
+		r.mu.Unlock()
+
+		if count >= n {
+			return nil
+		}
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return fmt.Errorf("testutil: %d of %d %s events: %w", count, n, kind, ctx.Err())
+		}
+	}
+}
+
+// AssertOrder fails t unless events of the given kinds were recorded in this
+// order, possibly with other events in between.
+func (r *Recorder) AssertOrder(t testing.TB, kinds ...asynclog.EventKind) {
+	t.Helper()
+
+	events := r.Events()
+	i := 0
+	for _, e := range events {
+		if i < len(kinds) && e.Kind == kinds[i] {
+			i++
+		}
+	}
+
+	if i < len(kinds) {
+		t.Fatalf("testutil: no %s after %v in:\n%s", kinds[i], kinds[:i], timeline(events))
+	}
+}
+
+// AssertWritten fails t unless the flushed batches carried msgs in this
+// order, and every flush was followed by a write.
+func (r *Recorder) AssertWritten(t testing.TB, msgs ...string) {
+	t.Helper()
+
+	events := r.Events()
+	var flushed []string
+	flushes, writes := 0, 0
+	for _, e := range events {
+		switch e.Kind {
+		case asynclog.EventFlush:
+			flushed = append(flushed, e.Messages...)
+			flushes++
+		case asynclog.EventWrite:
+			writes++
+		}
+	}
+
+	if !slices.Equal(flushed, msgs) {
+		t.Fatalf("testutil: flushed %q, want %q in:\n%s", flushed, msgs, timeline(events))
+	}
+	if writes < flushes {
+		t.Fatalf("testutil: %d flushes but %d writes in:\n%s", flushes, writes, timeline(events))
+	}
+}
+
+// String returns the recorded events, one per line.
+func (r *Recorder) String() string {
+	return timeline(r.Events())
+}
+
+func timeline(events []Recorded) string {
+	var b strings.Builder
+	for _, e := range events {
+		b.WriteString(e.String())
+		b.WriteByte('\n')
+	}
+
+	return b.String()
+}
@@ -0,0 +1,118 @@
+package testutil
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// Sink is a writer recording every write it takes, for asserting on what a
+// service wrote and in how many batches. It is safe for concurrent use.
+type Sink struct {
+	mu      sync.Mutex
+	writes  [][]byte
+	err     error
+	changed chan struct{} // closed and replaced on every write
+}
+
+func NewSink() *Sink {
+	return &Sink{changed: make(chan struct{})}
+}
+
+// Write records a copy of p, or fails with the error set with Fail without
+// recording it.
+func (s *Sink) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.err != nil {
+		return 0, s.err
+	}
+
+	s.writes = append(s.writes, bytes.Clone(p))
+	close(s.changed)
+	s.changed = make(chan struct{})
+
+	return len(p), nil
+}
+
+// Fail makes the following writes fail with err, or succeed again if err is
+// nil.
+func (s *Sink) Fail(err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.err = err
+}
+
+// Writes returns the payloads written so far, one per write.
+func (s *Sink) Writes() [][]byte {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([][]byte(nil), s.writes...)
+}
+
+// Lines returns the lines written so far, across writes.
+func (s *Sink) Lines() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.lines()
+}
+
+func (s *Sink) lines() []string {
+	var lines []string
+	for _, w := range s.writes {
+		lines = append(lines, strings.SplitAfter(string(w), "\n")...)
+	}
+
+	kept := lines[:0]
+	for _, l := range lines {
+		if l = strings.TrimSuffix(l, "\n"); l != "" {
+			kept = append(kept, l)
+		}
+	}
+
+	return kept
+}
+
+// Reset forgets the writes recorded so far.
+func (s *Sink) Reset() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.writes = nil
+}
+
+// WaitWrites waits until at least n writes have been recorded, or ctx is
+// done.
+func (s *Sink) WaitWrites(ctx context.Context, n int) error {
+	return s.wait(ctx, "writes", n, func() int { return len(s.writes) })
+}
+
+// WaitLines waits until at least n lines have been recorded, or ctx is done.
+func (s *Sink) WaitLines(ctx context.Context, n int) error {
+	return s.wait(ctx, "lines", n, func() int { return len(s.lines()) })
+}
+
+func (s *Sink) wait(ctx context.Context, what string, n int, count func() int) error {
+	for {
+		s.mu.Lock()
+		got := count()
+		changed := s.changed
+		s.mu.Unlock()
+
+		if got >= n {
+			return nil
+		}
+
+		select {
+		case <-changed:
+		case <-ctx.Done():
+			return fmt.Errorf("testutil: %d of %d %s: %w", got, n, what, ctx.Err())
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package asynclog
+
+import (
+	"sync"
+	"time"
+)
+
+// rateWindow is the number of one-second buckets rates are averaged over.
+const rateWindow = 10
+
+// rateCounter keeps rolling per-second record and byte counts.
+type rateCounter struct {
+	mu      sync.Mutex
+	buckets [rateWindow]rateBucket
+}
+
+type rateBucket struct {
+	sec     int64
+	records int64
+	bytes   int64
+}
+
+func (c *rateCounter) add(now time.Time, records, bytes int) {
+	sec := now.Unix()
+
+	c.mu.Lock()
+	b := &c.buckets[sec%rateWindow]
+	if b.sec != sec {
+		*b = rateBucket{sec: sec}
+	}
+	b.records += int64(records)
+	b.bytes += int64(bytes)
+	c.mu.Unlock()
+}
+
+// rate returns records and bytes per second over the last full seconds of the
+// window.
+func (c *rateCounter) rate(now time.Time) (records, bytes float64) {
+	sec := now.Unix()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, b := range c.buckets {
+		if b.sec < sec && b.sec >= sec-rateWindow {
+			records += float64(b.records)
+			bytes += float64(b.bytes)
+		}
+	}
+
+	return records / rateWindow, bytes / rateWindow
+}
+
+// Throughput is the rolling rate of records coming into the service and of
+// records written out, averaged over the last ten seconds. A delivery rate
+// staying below the ingestion rate means the buffer is growing.
+type Throughput struct {
+	IngestRecordsPerSec  float64
+	IngestBytesPerSec    float64
+	DeliverRecordsPerSec float64
+	DeliverBytesPerSec   float64
+}
+
+// Throughput returns the current ingestion and delivery rates.
+func (s *Service) Throughput() Throughput {
+	now := s.clock.Now()
+
+	var t Throughput
+	t.IngestRecordsPerSec, t.IngestBytesPerSec = s.ingestRate.rate(now)
+	t.DeliverRecordsPerSec, t.DeliverBytesPerSec = s.deliverRate.rate(now)
+
+	return t
+}
@@ -0,0 +1,44 @@
+package asynclog_test
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"test-task-log/asynclog"
+)
+
+func TestThroughput(t *testing.T) {
+	s, sink, clock := start(t)
+
+	printAll(t, s, "abc", "def")
+	if err := s.Barrier(testContext(t)); err != nil {
+		t.Fatal(err)
+	}
+	// Failed batches count as coming in, not as going out.
+	sink.Fail(errors.New("down"))
+	printAll(t, s, "ghi")
+	if err := s.Barrier(testContext(t)); err != nil {
+		t.Fatal(err)
+	}
+
+	// Only full seconds count.
+	if got := s.Throughput(); got != (asynclog.Throughput{}) {
+		t.Fatalf("got %+v within the first second, want zero", got)
+	}
+	clock.Advance(time.Second)
+	want := asynclog.Throughput{
+		IngestRecordsPerSec:  0.3,
+		IngestBytesPerSec:    0.9,
+		DeliverRecordsPerSec: 0.2,
+		DeliverBytesPerSec:   0.8,
+	}
+	if got := s.Throughput(); got != want {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+
+	clock.Advance(10 * time.Second)
+	if got := s.Throughput(); got != (asynclog.Throughput{}) {
+		t.Fatalf("got %+v once the window passed, want zero", got)
+	}
+}
@@ -0,0 +1,38 @@
+package asynclog
+
+import "context"
+
+// Tracer starts spans around the service's writes, so slow log delivery shows
+// up in the host application's traces. It is small enough to adapt an
+// OpenTelemetry tracer to in a few lines, without the service depending on
+// it.
+type Tracer interface {
+	Start(ctx context.Context, name string, attrs ...Field) (context.Context, Span)
+}
+
+// Span is a span started by a Tracer.
+type Span interface {
+	// End ends the span, marking it failed if err is not nil.
+	End(err error)
+}
+
+// WithTracer starts an "asynclog.flush" span for every batch and an
+// "asynclog.write" span under it for every writer it goes to. Sinks
+// implementing ContextWriter get the write span in their context.
+func WithTracer(t Tracer) Option {
+	return func(s *Service) {
+		s.tracer = t
+	}
+}
+
+func (s *Service) startSpan(ctx context.Context, name string, attrs ...Field) (context.Context, Span) {
+	if s.tracer == nil {
+		return ctx, nopSpan{}
+	}
+
+	return s.tracer.Start(ctx, name, attrs...)
+}
+
+type nopSpan struct{}
+
+func (nopSpan) End(error) {}
@@ -0,0 +1,136 @@
+package asynclog_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"test-task-log/asynclog"
+)
+
+type spanKey struct{}
+
+// fakeTracer records the spans started through it, keeping the name of the
+// current span in the context.
+type fakeTracer struct {
+	mu    sync.Mutex
+	spans []*fakeSpan
+}
+
+type fakeSpan struct {
+	tracer *fakeTracer
+	name   string
+	parent string
+	attrs  map[string]any
+	ended  bool
+	err    error
+}
+
+func (tr *fakeTracer) Start(ctx context.Context, name string, attrs ...asynclog.Field) (context.Context, asynclog.Span) {
+	sp := &fakeSpan{tracer: tr, name: name, attrs: map[string]any{}}
+	sp.parent, _ = ctx.Value(spanKey{}).(string)
+	for _, a := range attrs {
+		sp.attrs[a.Key] = a.Value
+	}
+
+	tr.mu.Lock()
+	tr.spans = append(tr.spans, sp)
+	tr.mu.Unlock()
+
+	return context.WithValue(ctx, spanKey{}, name), sp
+}
+
+func (sp *fakeSpan) End(err error) {
+	sp.tracer.mu.Lock()
+	defer sp.tracer.mu.Unlock()
+
+	sp.ended, sp.err = true, err
+}
+
+// find returns the span named name, failing the test if there isn't exactly
+// one.
+func (tr *fakeTracer) find(t *testing.T, name string) fakeSpan {
+	t.Helper()
+
+	tr.mu.Lock()
+	defer tr.mu.Unlock()
+
+	var found []fakeSpan
+	for _, sp := range tr.spans {
+		if sp.name == name {
+			found = append(found, *sp)
+		}
+	}
+	if len(found) != 1 {
+		t.Fatalf("got %d %s spans, want 1", len(found), name)
+	}
+
+	return found[0]
+}
+
+// spanWriter keeps the span in the context of the last write.
+type spanWriter struct {
+	mu   sync.Mutex
+	span string
+	err  error
+}
+
+func (w *spanWriter) Write(p []byte) (int, error) {
+	return w.WriteContext(context.Background(), p)
+}
+
+func (w *spanWriter) WriteContext(ctx context.Context, p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.span, _ = ctx.Value(spanKey{}).(string)
+	if w.err != nil {
+		return 0, w.err
+	}
+	return len(p), nil
+}
+
+func TestTracer(t *testing.T) {
+	tr := &fakeTracer{}
+	w := &spanWriter{}
+	s := asynclog.NewService(w, asynclog.WithBatchSize(1), asynclog.WithTracer(tr))
+	go s.Run(context.Background())
+	t.Cleanup(func() { s.Shutdown(context.Background()) })
+
+	if err := s.PrintSync(testContext(t), "a"); err != nil {
+		t.Fatal(err)
+	}
+
+	flush := tr.find(t, "asynclog.flush")
+	if !flush.ended || flush.err != nil || flush.attrs["records"] != 1 || flush.attrs["targets"] != 1 {
+		t.Fatalf("got flush span %+v, want one record to one target, ended without error", flush)
+	}
+	write := tr.find(t, "asynclog.write")
+	if !write.ended || write.err != nil || write.parent != "asynclog.flush" || write.attrs["bytes"] != 2 {
+		t.Fatalf("got write span %+v, want 2 bytes under the flush span, ended without error", write)
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.span != "asynclog.write" {
+		t.Fatalf("writer got span %q in its context, want asynclog.write", w.span)
+	}
+}
+
+func TestTracerFailedWrite(t *testing.T) {
+	tr := &fakeTracer{}
+	down := errors.New("down")
+	w := &spanWriter{err: down}
+	s := asynclog.NewService(w, asynclog.WithBatchSize(1), asynclog.WithTracer(tr))
+	go s.Run(context.Background())
+	t.Cleanup(func() { s.Shutdown(context.Background()) })
+
+	if err := s.PrintSync(testContext(t), "a"); !errors.Is(err, down) {
+		t.Fatalf("got %v, want the write error", err)
+	}
+	for _, name := range []string{"asynclog.flush", "asynclog.write"} {
+		if sp := tr.find(t, name); !sp.ended || !errors.Is(sp.err, down) {
+			t.Fatalf("got %s span %+v, want it ended with the write error", name, sp)
+		}
+	}
+}
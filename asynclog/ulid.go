@@ -0,0 +1,59 @@
+package asynclog
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"sync"
+	"time"
+)
+
+const crockford = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// ulidGen generates ULIDs: a 48-bit millisecond timestamp followed by 80
+// random bits, in Crockford base32. IDs generated within the same millisecond
+// increment the random part so they stay sortable in generation order.
+type ulidGen struct {
+	mu     sync.Mutex
+	lastMs uint64
+	random [10]byte
+}
+
+func (g *ulidGen) next(now time.Time) string {
+	g.mu.Lock()
+	ms := uint64(now.UnixMilli())
+	if ms > g.lastMs {
+		g.lastMs = ms
+		rand.Read(g.random[:])
+	} else {
+		for i := len(g.random) - 1; i >= 0; i-- {
+			g.random[i]++
+			if g.random[i] != 0 {
+				break
+			}
+		}
+	}
+
+	var id [16]byte
+	binary.BigEndian.PutUint16(id[0:], uint16(g.lastMs>>32))
+	binary.BigEndian.PutUint32(id[2:], uint32(g.lastMs))
+	copy(id[6:], g.random[:])
+	g.mu.Unlock()
+
+	return encodeULID(id)
+}
+
+// encodeULID writes the 128 bits of id as 26 base32 characters, the first
+// holding the top 3 bits.
+func encodeULID(id [16]byte) string {
+	hi := binary.BigEndian.Uint64(id[:8])
+	lo := binary.BigEndian.Uint64(id[8:])
+
+	var out [26]byte
+	for i := 25; i >= 0; i-- {
+		out[i] = crockford[lo&0x1f]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+
+	return string(out[:])
+}
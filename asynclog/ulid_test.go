@@ -0,0 +1,41 @@
+package asynclog_test
+
+import (
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"test-task-log/asynclog"
+)
+
+func TestULID(t *testing.T) {
+	s, sink, clock := start(t, asynclog.WithULID())
+
+	// Within a millisecond the IDs increment, and across them they follow
+	// the clock.
+	printAll(t, s, "a", "b")
+	clock.Advance(time.Millisecond)
+	printAll(t, s, "c")
+	if err := s.Shutdown(testContext(t)); err != nil {
+		t.Fatal(err)
+	}
+
+	var ids []string
+	for i, line := range sink.Lines() {
+		id, msg, _ := strings.Cut(line, " ")
+		if len(id) != 26 || strings.Trim(id, "0123456789ABCDEFGHJKMNPQRSTVWXYZ") != "" {
+			t.Fatalf("line %q: %q is not a ULID", line, id)
+		}
+		if want := string(rune('a' + i)); msg != want {
+			t.Fatalf("line %q: got message %q, want %q", line, msg, want)
+		}
+		ids = append(ids, id)
+	}
+	if len(ids) != 3 || !slices.IsSorted(ids) || ids[0] == ids[1] {
+		t.Fatalf("got IDs %q, want 3 distinct ones in order", ids)
+	}
+	if ids[0][:10] != ids[1][:10] || ids[1][:10] == ids[2][:10] {
+		t.Fatalf("got IDs %q, want the first two sharing their timestamp", ids)
+	}
+}
@@ -0,0 +1,56 @@
+package asynclog_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"test-task-log/asynclog"
+)
+
+func TestReadBackVerification(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	s := asynclog.NewService(f, asynclog.WithBatchSize(1), asynclog.WithReadBackVerification())
+	go s.Run(context.Background())
+	t.Cleanup(func() { s.Shutdown(context.Background()) })
+
+	for _, msg := range []string{"a", "b"} {
+		if err := s.PrintSync(testContext(t), msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got, err := os.ReadFile(path); err != nil || string(got) != "a\nb\n" {
+		t.Fatalf("got %q, %v, want %q", got, err, "a\nb\n")
+	}
+}
+
+func TestReadBackVerificationMismatch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	if err := os.WriteFile(path, []byte("existing\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Without O_APPEND the batch overwrites the start of the file instead of
+	// landing at its end, where it is read back from.
+	f, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	s := asynclog.NewService(f, asynclog.WithBatchSize(1), asynclog.WithReadBackVerification())
+	go s.Run(context.Background())
+	t.Cleanup(func() { s.Shutdown(context.Background()) })
+
+	if err := s.PrintSync(testContext(t), "a"); !errors.Is(err, asynclog.ErrVerifyFailed) {
+		t.Fatalf("got %v, want ErrVerifyFailed", err)
+	}
+}
@@ -0,0 +1,141 @@
+package asynclog
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// ErrSinkStuck is returned for writes to a writer that didn't return from a
+// previous write within the WithWriteWatchdog deadline.
+var ErrSinkStuck = errors.New("asynclog: sink stuck")
+
+// StuckPolicy is what the watchdog does with a write past its deadline.
+type StuckPolicy int
+
+const (
+	// StuckAbandon stops waiting for the write and leaves it running.
+	StuckAbandon StuckPolicy = iota
+	// StuckClose also closes the writer if it implements io.Closer, which
+	// unblocks writes to connections and pipes.
+	StuckClose
+)
+
+// WithWriteWatchdog fails writes that take longer than deadline with
+// ErrSinkStuck instead of waiting for them, e.g. on a hung NFS mount or a
+// dead peer. Until the abandoned write returns, further writes to the same
+// writer fail right away rather than piling up behind it.
+func WithWriteWatchdog(deadline time.Duration, policy StuckPolicy) Option {
+	return func(s *Service) {
+		s.stuckAfter = deadline
+		s.stuckPolicy = policy
+	}
+}
+
+// wrap adds the configured read-back verification, panic recovery, short
+// write handling, watchdog and circuit breaker to w.
+func (s *Service) wrap(w io.Writer) io.Writer {
+	if f, ok := w.(*os.File); ok && s.verifyReads {
+		w = newVerifiedFile(f)
+	}
+	w = &safeWriter{w: w, s: s}
+	w = &shortWriter{w: w, policy: s.shortPolicy, count: &s.shortWrites}
+
+	if s.stuckAfter > 0 {
+		w = &watchdogWriter{w: w, deadline: s.stuckAfter, policy: s.stuckPolicy, clock: s.clock, debugf: s.debugf}
+	}
+	if s.breakAfter > 0 {
+		w = &breakerWriter{w: w, s: s}
+	}
+
+	return w
+}
+
+type watchdogWriter struct {
+	w        io.Writer
+	deadline time.Duration
+	policy   StuckPolicy
+	clock    Clock
+	debugf   func(format string, args ...any)
+
+	mu    sync.Mutex
+	stuck chan struct{} // closed when the abandoned write returns
+}
+
+type writeResult struct {
+	n   int
+	err error
+}
+
+func (ww *watchdogWriter) Write(p []byte) (int, error) {
+	return ww.WriteContext(context.Background(), p)
+}
+
+func (ww *watchdogWriter) WriteContext(ctx context.Context, p []byte) (int, error) {
+	ww.mu.Lock()
+	if ww.stuck != nil {
+		select {
+		case <-ww.stuck:
+			ww.stuck = nil
+		default:
+			ww.mu.Unlock()
+			return 0, ErrSinkStuck
+		}
+	}
+	ww.mu.Unlock()
+
+	res := make(chan writeResult, 1)
+	done := make(chan struct{})
+	go func() {
+		n, err := writeContext(ctx, ww.w, p)
+		res <- writeResult{n: n, err: err}
+		close(done)
+	}()
+
+	select {
+	case r := <-res:
+		return r.n, r.err
+	case <-ww.clock.After(ww.deadline):
+	}
+
+	ww.mu.Lock()
+	ww.stuck = done
+	ww.mu.Unlock()
+	ww.debugf("write stuck for over %s, abandoning it", ww.deadline)
+
+	if c, ok := ww.w.(io.Closer); ok && ww.policy == StuckClose {
+		c.Close()
+	}
+
+	return 0, fmt.Errorf("%w: write blocked for over %s", ErrSinkStuck, ww.deadline)
+}
+
+func (ww *watchdogWriter) Flush() error {
+	return flushWriter(ww.w)
+}
+
+func (ww *watchdogWriter) Sync() error {
+	return syncWriter(ww.w)
+}
+
+func (ww *watchdogWriter) SetWriteDeadline(t time.Time) error {
+	if d, ok := ww.w.(writeDeadliner); ok {
+		return d.SetWriteDeadline(t)
+	}
+
+	return nil
+}
+
+func (ww *watchdogWriter) Probe(ctx context.Context) error {
+	if p, ok := ww.w.(Prober); ok {
+		return p.Probe(ctx)
+	}
+
+	_, err := ww.Write(nil)
+
+	return err
+}
@@ -0,0 +1,100 @@
+package asynclog_test
+
+import (
+	"context"
+	"errors"
+	"io"
+	"testing"
+	"time"
+
+	"test-task-log/asynclog"
+	"test-task-log/asynclog/testutil"
+)
+
+func TestWriteWatchdog(t *testing.T) {
+	w := gateWriter{release: make(chan struct{})}
+	const deadline = 50 * time.Millisecond
+	s := asynclog.NewService(w, asynclog.WithBatchSize(1), asynclog.WithWriteWatchdog(deadline, asynclog.StuckAbandon))
+	go s.Run(context.Background())
+	t.Cleanup(func() { s.Shutdown(context.Background()) })
+
+	if err := s.PrintSync(testContext(t), "a"); !errors.Is(err, asynclog.ErrSinkStuck) {
+		t.Fatalf("got %v, want ErrSinkStuck", err)
+	}
+
+	// Writes behind the abandoned one fail at once instead of piling up.
+	start := time.Now()
+	if err := s.PrintSync(testContext(t), "b"); !errors.Is(err, asynclog.ErrSinkStuck) {
+		t.Fatalf("got %v behind the stuck write, want ErrSinkStuck", err)
+	}
+	if d := time.Since(start); d >= deadline {
+		t.Fatalf("write behind the stuck one took %s, want it to fail right away", d)
+	}
+
+	close(w.release)
+	ctx := testContext(t)
+	for {
+		err := s.PrintSync(ctx, "c")
+		if err == nil {
+			break
+		}
+		if !errors.Is(err, asynclog.ErrSinkStuck) {
+			t.Fatalf("got %v once the write returned, want success", err)
+		}
+		select {
+		case <-ctx.Done():
+			t.Fatal("writer still stuck after the write returned")
+		case <-time.After(time.Millisecond):
+		}
+	}
+}
+
+func TestWriteWatchdogClose(t *testing.T) {
+	r, w := io.Pipe()
+	defer r.Close()
+	s := asynclog.NewService(w, asynclog.WithBatchSize(1), asynclog.WithWriteWatchdog(10*time.Millisecond, asynclog.StuckClose))
+	go s.Run(context.Background())
+	t.Cleanup(func() { s.Shutdown(context.Background()) })
+
+	if err := s.PrintSync(testContext(t), "a"); !errors.Is(err, asynclog.ErrSinkStuck) {
+		t.Fatalf("got %v, want ErrSinkStuck", err)
+	}
+
+	// Closing the pipe ends the stuck write, and the next ones fail on the
+	// closed pipe rather than hang.
+	ctx := testContext(t)
+	for {
+		err := s.PrintSync(ctx, "b")
+		if errors.Is(err, io.ErrClosedPipe) {
+			break
+		}
+		if !errors.Is(err, asynclog.ErrSinkStuck) {
+			t.Fatalf("got %v, want io.ErrClosedPipe", err)
+		}
+		select {
+		case <-ctx.Done():
+			t.Fatal("pipe never closed")
+		case <-time.After(time.Millisecond):
+		}
+	}
+}
+
+func TestWriteWatchdogClock(t *testing.T) {
+	w := gateWriter{release: make(chan struct{})}
+	defer close(w.release)
+	clock := testutil.NewClock(time.Time{})
+	s := asynclog.NewService(w, asynclog.WithClock(clock), asynclog.WithBatchSize(1), asynclog.WithWriteWatchdog(time.Hour, asynclog.StuckAbandon))
+	go s.Run(context.Background())
+	t.Cleanup(func() { s.Shutdown(context.Background()) })
+
+	// The deadline passes on the service's clock, not in real time.
+	written := make(chan error, 1)
+	go func() { written <- s.PrintSync(testContext(t), "a") }()
+	if err := clock.WaitTimers(testContext(t), 1); err != nil {
+		t.Fatal(err)
+	}
+	clock.Advance(time.Hour)
+	if err := <-written; !errors.Is(err, asynclog.ErrSinkStuck) {
+		t.Fatalf("got %v, want ErrSinkStuck", err)
+	}
+}
@@ -0,0 +1,55 @@
+package asynclog
+
+import (
+	"fmt"
+	"sync"
+)
+
+// WithWorkers sets how many goroutines write batches. By default there is
+// one, writing batches strictly in the order they are flushed, one at a
+// time: a batch's writes to the main writer and the sinks all complete
+// before the next batch's start, and while it is busy flushes wait, backing
+// the queue up to producers.
+//
+// With n above one batches start in flush order but up to n are written
+// concurrently and may complete in any order, including to the same writer,
+// which must then be safe for concurrent use; records that must stay ordered
+// belong in the same batch. With n zero every batch is written on a
+// goroutine of its own, as many at once as flushes start, unless
+// WithMaxInflightBatches bounds them. Encoding ahead with WithEncodeAhead
+// keeps its own single writer.
+func WithWorkers(n int) Option {
+	if n < 0 {
+		panic(fmt.Sprintf("asynclog: WithWorkers: negative pool size %d", n))
+	}
+
+	return func(s *Service) {
+		s.workers = n
+	}
+}
+
+// startWorkers starts the pool and returns a function stopping it once the
+// jobs handed to it are done.
+func (s *Service) startWorkers() (stop func()) {
+	jobs := make(chan func())
+
+	var wg sync.WaitGroup
+	for range s.workers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for job := range jobs {
+				job()
+			}
+		}()
+	}
+
+	s.jobs = jobs
+
+	return func() {
+		s.jobs = nil
+		close(jobs)
+		wg.Wait()
+	}
+}
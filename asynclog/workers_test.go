@@ -0,0 +1,65 @@
+package asynclog_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+
+	"test-task-log/asynclog"
+	"test-task-log/asynclog/testutil"
+)
+
+func TestWorkersOrder(t *testing.T) {
+	// The default single worker writes batches one at a time.
+	sink := testutil.NewSink()
+	s := asynclog.NewService(sink, asynclog.WithBatchSize(1))
+
+	want := []string{"a", "b", "c", "d", "e"}
+	printAll(t, s, want...)
+	if got := drain(t, s, sink); !slices.Equal(got, want) {
+		t.Fatalf("got %q, want batches written in flush order", got)
+	}
+}
+
+func TestWorkersBackpressure(t *testing.T) {
+	w := gateWriter{release: make(chan struct{})}
+	s := asynclog.NewService(w, asynclog.WithBatchSize(1), asynclog.WithChannelBuffer(1), asynclog.WithWorkers(1))
+	sink := testutil.NewSink()
+	if err := s.AddSink("all", sink); err != nil {
+		t.Fatal(err)
+	}
+	go s.Run(context.Background())
+
+	// With the only worker stuck, flushes wait for it and the queue fills up.
+	var accepted []string
+	for _, msg := range []string{"a", "b", "c", "d", "e", "f"} {
+		if err := printShort(s, "", asynclog.LevelInfo, msg); err != nil {
+			if !errors.Is(err, asynclog.ErrTimeout) {
+				t.Fatal(err)
+			}
+			break
+		}
+		accepted = append(accepted, msg)
+	}
+	if len(accepted) == 6 {
+		t.Fatal("a stuck worker didn't hold up producers")
+	}
+
+	close(w.release)
+	if err := s.Shutdown(testContext(t)); err != nil {
+		t.Fatal(err)
+	}
+	if got := sink.Lines(); !slices.Equal(got, accepted) {
+		t.Fatalf("got %q, want the accepted records %q in order", got, accepted)
+	}
+}
+
+func TestWorkersInvalid(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("WithWorkers(-1) didn't panic")
+		}
+	}()
+	asynclog.WithWorkers(-1)
+}
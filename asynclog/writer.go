@@ -0,0 +1,158 @@
+package asynclog
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"log"
+	"strings"
+	"sync"
+)
+
+// ErrClosed is returned for writes to a closed AsyncWriter, and for records
+// printed once the service is draining or shut down.
+var ErrClosed = errors.New("asynclog: closed")
+
+// ErrTimeout is returned for records not accepted before the producer's
+// context ended, wrapping the context's error.
+var ErrTimeout = errors.New("asynclog: timed out")
+
+// AsyncWriter makes any io.Writer asynchronous: writes are queued and passed
+// on to the wrapped writer in batches by a Service running in the background.
+// Batches are the queued writes concatenated as they are, with no separator.
+type AsyncWriter struct {
+	service *Service
+	ctx     context.Context
+	cancel  context.CancelFunc
+	done    chan struct{}
+}
+
+// NewAsyncWriter starts batching writes to w. Options are the same as for
+// NewService. Close flushes what is still queued and stops the background
+// goroutine.
+func NewAsyncWriter(w io.Writer, opts ...Option) io.WriteCloser {
+	s := NewService(w, opts...)
+	s.raw = true
+
+	ctx, cancel := context.WithCancel(context.Background())
+	aw := &AsyncWriter{
+		service: s,
+		ctx:     ctx,
+		cancel:  cancel,
+		done:    make(chan struct{}),
+	}
+
+	go func() {
+		s.Run(ctx)
+		close(aw.done)
+	}()
+
+	return aw
+}
+
+// Write queues a copy of p. It only blocks while the queue is full, and
+// fails with ErrClosed once the writer is closed and with the other errors
+// of Print, such as ErrQueueFull, if p wasn't queued.
+func (aw *AsyncWriter) Write(p []byte) (int, error) {
+	if aw.ctx.Err() != nil {
+		return 0, ErrClosed
+	}
+
+	if err := aw.service.Print(aw.ctx, string(p)); err != nil {
+		// The context only ends when the writer is closed.
+		if aw.ctx.Err() != nil {
+			return 0, ErrClosed
+		}
+		return 0, err
+	}
+
+	return len(p), nil
+}
+
+// Close writes everything queued to the wrapped writer and returns once it is
+// done.
+func (aw *AsyncWriter) Close() error {
+	aw.cancel()
+	<-aw.done
+
+	return nil
+}
+
+// Wrap makes l write through an AsyncWriter around its current output, so
+// existing *log.Logger users get batched asynchronous writes with one call at
+// init. Closing the returned io.Closer flushes what is queued and puts the
+// original output back.
+func Wrap(l *log.Logger, opts ...Option) io.Closer {
+	out := l.Writer()
+	aw := NewAsyncWriter(out, opts...)
+	l.SetOutput(aw)
+
+	return closerFunc(func() error {
+		l.SetOutput(out)
+		return aw.Close()
+	})
+}
+
+type closerFunc func() error
+
+func (f closerFunc) Close() error {
+	return f()
+}
+
+// maxWriterLine is the longest line a Service.Writer holds back waiting for
+// its newline.
+const maxWriterLine = 64 << 10
+
+// Writer returns a writer logging every line written to it at LevelInfo, for
+// log.SetOutput, http.Server.ErrorLog and other APIs that only take an
+// io.Writer. Lines split across writes are joined; a line without its newline
+// yet is held back until it comes, or logged as it is past 64KiB. Empty lines
+// are skipped. Writes wait while the queue is full, as Print does, and fail
+// with what Print returns if their lines weren't queued, ErrClosed once the
+// service is draining or shut down.
+func (s *Service) Writer() io.Writer {
+	return &lineWriter{s: s}
+}
+
+type lineWriter struct {
+	s *Service
+
+	mu      sync.Mutex
+	partial []byte
+}
+
+func (lw *lineWriter) Write(p []byte) (int, error) {
+	if lw.s.drained.Load() {
+		return 0, ErrClosed
+	}
+
+	lw.mu.Lock()
+	defer lw.mu.Unlock()
+
+	var recs []record
+	buf := append(lw.partial, p...)
+	for {
+		i := bytes.IndexByte(buf, '\n')
+		if i < 0 && len(buf) <= maxWriterLine {
+			break
+		}
+		if i < 0 {
+			i = len(buf)
+		}
+
+		if line := strings.TrimRight(string(buf[:i]), "\r"); line != "" {
+			recs = append(recs, record{level: LevelInfo, msg: line})
+		}
+		buf = buf[min(i+1, len(buf)):]
+	}
+	lw.partial = append(lw.partial[:0], buf...)
+
+	if len(recs) > 0 {
+		if err := lw.s.enqueueAll(context.Background(), recs); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(p), nil
+}
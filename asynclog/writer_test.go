@@ -0,0 +1,94 @@
+package asynclog_test
+
+import (
+	"bytes"
+	"errors"
+	"log"
+	"slices"
+	"testing"
+	"time"
+
+	"test-task-log/asynclog"
+	"test-task-log/asynclog/testutil"
+)
+
+func TestAsyncWriter(t *testing.T) {
+	sink := testutil.NewSink()
+	w := asynclog.NewAsyncWriter(sink, asynclog.WithBatchSize(100))
+
+	for _, p := range []string{"a", "b\n", "c"} {
+		if n, err := w.Write([]byte(p)); err != nil || n != len(p) {
+			t.Fatalf("Write(%q) = %d, %v", p, n, err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// Writes are passed on as they are, without separators.
+	if got := bytes.Join(sink.Writes(), nil); string(got) != "ab\nc" {
+		t.Fatalf("got %q, want %q", got, "ab\nc")
+	}
+	if _, err := w.Write([]byte("late")); !errors.Is(err, asynclog.ErrClosed) {
+		t.Fatalf("Write after Close: got %v, want ErrClosed", err)
+	}
+}
+
+func TestWrapLogger(t *testing.T) {
+	sink := testutil.NewSink()
+	l := log.New(sink, "", 0)
+
+	closer := asynclog.Wrap(l, asynclog.WithFlushInterval(time.Hour))
+	l.Print("a")
+	l.Print("b")
+	if n := len(sink.Writes()); n != 0 {
+		t.Fatalf("got %d writes before Close, want the lines batched", n)
+	}
+	if err := closer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if got, want := sink.Lines(), []string{"a", "b"}; !slices.Equal(got, want) || len(sink.Writes()) != 1 {
+		t.Fatalf("got %q in %d writes, want %q in one", got, len(sink.Writes()), want)
+	}
+	if l.Writer() != sink {
+		t.Fatal("Close didn't put the original output back")
+	}
+}
+
+func TestServiceWriter(t *testing.T) {
+	s, sink, _ := start(t)
+	w := s.Writer()
+
+	log.New(w, "", 0).Print("from log")
+	for _, p := range []string{"par", "tial\r\n\n", "x\ny"} {
+		if n, err := w.Write([]byte(p)); n != len(p) || err != nil {
+			t.Fatalf("Write(%q) = %d, %v", p, n, err)
+		}
+	}
+	if err := s.Flush(testContext(t)); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := sink.Lines(), []string{"from log", "partial", "x"}; !slices.Equal(got, want) {
+		t.Fatalf("got %q, want %q with y held back", got, want)
+	}
+
+	// A line past the limit goes out without waiting for its newline.
+	long := bytes.Repeat([]byte("z"), 64<<10)
+	if _, err := w.Write(long); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Flush(testContext(t)); err != nil {
+		t.Fatal(err)
+	}
+	if got := sink.Lines(); len(got) != 4 || got[3] != "y"+string(long) {
+		t.Fatalf("got %d lines, want the long line logged", len(got))
+	}
+
+	if err := s.Shutdown(testContext(t)); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.Write([]byte("late\n")); !errors.Is(err, asynclog.ErrClosed) {
+		t.Fatalf("got %v, want ErrClosed", err)
+	}
+}
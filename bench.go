@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"test-task-log/asynclog"
+)
+
+// runBench runs the bench subcommands.
+func runBench(args []string) error {
+	if len(args) == 0 || args[0] != "compare" {
+		return fmt.Errorf("usage: bench compare [flags]")
+	}
+
+	fs := flag.NewFlagSet("bench compare", flag.ExitOnError)
+	sink := fs.String("sink", "", "sink URI to write to, discards output when empty")
+	records := fs.Int("n", 10000, "records to write")
+	producers := fs.Int("producers", 4, "concurrent producers")
+	delay := fs.Duration("write-delay", time.Millisecond, "extra time every write to the sink takes, to simulate a slow sink")
+	fs.Parse(args[1:])
+
+	w := io.Discard
+	if *sink != "" {
+		var err error
+		if w, err = asynclog.OpenSink(*sink); err != nil {
+			return err
+		}
+	}
+
+	cw := &countingWriter{w: w, delay: *delay}
+
+	fmt.Printf("%d records, %d producers, %s per write\n\n", *records, *producers, *delay)
+	fmt.Printf("%-6s %10s %10s %10s %10s %12s %8s\n", "mode", "p50", "p90", "p99", "max", "wall", "dropped")
+
+	var mu sync.Mutex
+	syncReport := benchWorkload(*records, *producers, cw, func(line string) {
+		mu.Lock()
+		cw.Write([]byte(line + "\n"))
+		mu.Unlock()
+	}, nil)
+	syncReport.print("sync")
+
+	cw.lines.Store(0)
+	service := asynclog.NewService(cw)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		service.Run(ctx)
+		close(done)
+	}()
+
+	// Records Print doesn't queue are counted as dropped.
+	asyncReport := benchWorkload(*records, *producers, cw, func(line string) {
+		service.Print(ctx, line)
+	}, func() {
+		cancel()
+		<-done
+	})
+	asyncReport.print("async")
+
+	return nil
+}
+
+type benchReport struct {
+	latencies []time.Duration
+	wall      time.Duration
+	dropped   int64
+}
+
+// benchWorkload sends records lines split across producers through print,
+// timing every call, and runs finish before stopping the wall clock.
+func benchWorkload(records, producers int, cw *countingWriter, print func(string), finish func()) benchReport {
+	latencies := make([][]time.Duration, producers)
+
+	var wg sync.WaitGroup
+	start := time.Now()
+	for p := range producers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for i := p; i < records; i += producers {
+				t := time.Now()
+				print(fmt.Sprintf("bench record %d", i))
+				latencies[p] = append(latencies[p], time.Since(t))
+			}
+		}()
+	}
+	wg.Wait()
+
+	if finish != nil {
+		finish()
+	}
+
+	r := benchReport{wall: time.Since(start)}
+	for _, l := range latencies {
+		r.latencies = append(r.latencies, l...)
+	}
+	slices.Sort(r.latencies)
+	r.dropped = int64(records) - cw.lines.Load()
+
+	return r
+}
+
+func (r benchReport) percentile(p int) time.Duration {
+	if len(r.latencies) == 0 {
+		return 0
+	}
+
+	return r.latencies[(len(r.latencies)*p+99)/100-1]
+}
+
+func (r benchReport) print(mode string) {
+	fmt.Printf("%-6s %10s %10s %10s %10s %12s %8d\n", mode,
+		r.percentile(50), r.percentile(90), r.percentile(99), r.percentile(100),
+		r.wall.Round(time.Millisecond), r.dropped)
+}
+
+// countingWriter counts the lines written through it and slows every write
+// down by delay.
+type countingWriter struct {
+	w     io.Writer
+	delay time.Duration
+	lines atomic.Int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	time.Sleep(c.delay)
+	c.lines.Add(int64(bytes.Count(p, []byte("\n"))))
+
+	return c.w.Write(p)
+}
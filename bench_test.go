@@ -0,0 +1,36 @@
+package main
+
+import (
+	"io"
+	"testing"
+)
+
+func TestBenchWorkload(t *testing.T) {
+	cw := &countingWriter{w: io.Discard}
+
+	r := benchWorkload(10, 3, cw, func(line string) {
+		cw.Write([]byte(line + "\n"))
+	}, nil)
+	if len(r.latencies) != 10 || r.dropped != 0 {
+		t.Fatalf("got %d latencies and %d dropped, want 10 and 0", len(r.latencies), r.dropped)
+	}
+	if r.percentile(50) > r.percentile(100) || r.percentile(100) != r.latencies[9] {
+		t.Fatalf("percentiles out of order: p50 %s, max %s", r.percentile(50), r.percentile(100))
+	}
+
+	// Records that never reach the writer count as dropped.
+	cw.lines.Store(0)
+	r = benchWorkload(10, 2, cw, func(string) {}, nil)
+	if r.dropped != 10 {
+		t.Fatalf("got %d dropped, want 10", r.dropped)
+	}
+}
+
+func TestRunBenchErrors(t *testing.T) {
+	if err := runBench(nil); err == nil {
+		t.Fatal("ran bench without a subcommand")
+	}
+	if err := runBench([]string{"compare", "-sink", "nosuch://x"}); err == nil {
+		t.Fatal("ran bench compare on an unknown sink")
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"time"
+
+	"test-task-log/asynclog"
+)
+
+// runCheck runs the check subcommand, returning an error if any step failed.
+func runCheck(args []string) error {
+	fs := flag.NewFlagSet("check", flag.ExitOnError)
+	config := fs.String("config", "", "JSON config file to check")
+	timeout := fs.Duration("timeout", 10*time.Second, "how long the whole check may take")
+	fs.Parse(args)
+
+	if *config == "" {
+		return fmt.Errorf("usage: check -config cfg.json")
+	}
+
+	c, err := asynclog.LoadConfig(*config)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	failed := 0
+	for _, r := range c.Check(ctx) {
+		status := "ok"
+		if r.Err != nil {
+			status = "FAIL"
+			failed++
+		}
+		fmt.Printf("%-4s %-8s %s", status, r.Step, r.Target)
+		if r.Err != nil {
+			fmt.Printf(": %v", r.Err)
+		}
+		fmt.Println()
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%s: %d checks failed", *config, failed)
+	}
+
+	return nil
+}
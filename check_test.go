@@ -0,0 +1,27 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRunCheck(t *testing.T) {
+	dir := t.TempDir()
+	config := filepath.Join(dir, "cfg.json")
+	os.WriteFile(config, []byte(`{"writer": "file://`+filepath.Join(dir, "app.log")+`"}`), 0o644)
+	if err := runCheck([]string{"-config", config}); err != nil {
+		t.Fatal(err)
+	}
+
+	os.WriteFile(config, []byte(`{"writer": "nosuchscheme:"}`), 0o644)
+	if err := runCheck([]string{"-config", config}); err == nil {
+		t.Fatal("check passed with an unopenable writer")
+	}
+	if err := runCheck(nil); err == nil {
+		t.Fatal("ran check without a config")
+	}
+	if err := runCheck([]string{"-config", filepath.Join(dir, "missing.json")}); err == nil {
+		t.Fatal("ran check on a missing config")
+	}
+}
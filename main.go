@@ -2,108 +2,119 @@ package main
 
 import (
 	"context"
+	"errors"
+	"flag"
 	"fmt"
-	"io"
+	"log"
+	"net/http"
 	"os"
+	"os/exec"
 	"os/signal"
-	"strings"
-	"sync"
 	"syscall"
 	"time"
-)
 
-type Service struct {
-	writer         io.Writer
-	logCh          chan string
-	buffer         []string
-	bufferMx       sync.Mutex
-	bufferWg       sync.WaitGroup
-	bufferNotifyCh chan struct{}
-	writeEvery     time.Duration
-	writeLimit     int
-}
+	"test-task-log/asynclog"
+)
 
-func NewService(writer io.Writer) *Service {
-	return &Service{
-		writer:         writer,
-		logCh:          make(chan string),
-		bufferNotifyCh: make(chan struct{}, 1),
-		writeEvery:     5 * time.Second, // сливаем логи в writer каждые 5 секунд или 10 записей
-		writeLimit:     10,
+func main() {
+	if len(os.Args) > 1 && os.Args[1] == "bench" {
+		if err := runBench(os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+	if len(os.Args) > 1 && os.Args[1] == "check" {
+		if err := runCheck(os.Args[2:]); err != nil {
+			log.Fatal(err)
+		}
+		return
 	}
-}
-
-// Run
-// У нас есть некий сервис логов. Он принимает логи из разных источников через метод Print и пишет их в io.Writer.
-// Проблема в том что io.Writer может не успевать записывать логи так быстро как они пуступают в метод Print.
-// Необходимо реализовать сервис таким образом чтобы запись в Print была наиболее быстрой и не была связана с замедленной записью в io.Writer.
-// - писать в io.Writer необходимо или каждые 5 секунд или когда накопится 10 записей
-// - в io.Writer можно писать весь буфер который доступен в данный момент, но не писать по одной записи
-// - Run должен завершаться после закрытия контекста и после завершения всех го-рутин которые он создал
-// - после закрытия контекста, если буфер не пустой, его необходимо записать в io.Writer
-// - можно добавлять свои методы и поля в Service
-func (s *Service) Run(ctx context.Context) {
-	t := time.NewTicker(s.writeEvery)
-
-	for {
-		select {
-		case <-ctx.Done():
-			s.bufferWg.Wait()
-			if len(s.buffer) > 0 {
-				buff := []byte(strings.Join(s.buffer, "\n") + "\n")
-				s.writer.Write(buff)
-			}
-
-			close(s.logCh)
-
-			return
-		case log := <-s.logCh:
-			s.buffer = append(s.buffer, log)
-
-			if len(s.buffer) > s.writeLimit {
-				s.bufferNotifyCh <- struct{}{}
-			}
 
-		case <-s.bufferNotifyCh:
-			if len(s.buffer) > 0 {
-				buff := []byte(strings.Join(s.buffer, "\n") + "\n")
-				s.buffer = nil
+	sink := flag.String("sink", "stdout:", "sink URI, e.g. file:///var/log/app.log or tcp://collector:601")
+	errorSink := flag.String("error-sink", "", "also write ERROR records to this sink URI as soon as they come in")
+	config := flag.String("config", "", "JSON config file, overrides -sink and is reloaded on SIGUSR1")
+	tail := flag.String("tail", "", "follow this file and ship its lines instead of sending demo messages")
+	container := flag.Bool("container", false, "parse the -tail file as a Docker json-file or CRI container log")
+	debug := flag.Bool("debug", false, "trace the service's own flush decisions to stderr")
+	interval := flag.Duration("flush-interval", 0, "how often to write buffered records (default 5s)")
+	batch := flag.Int("batch-size", 0, "how many buffered records trigger a write (default 10)")
+	batchBytes := flag.Int("batch-bytes", 0, "the most encoded bytes written at once (default no limit)")
+	format := flag.String("format", "", "encode records as text, json, logfmt or syslog (default text)")
+	listen := flag.String("listen", "", "also accept newline-delimited records POSTed to this HTTP address")
+	execute := flag.Bool("exec", false, "run the command given after the flags and ship its stdout and stderr, exiting with it")
+	flag.Parse()
 
-				s.bufferWg.Add(1)
-				go func() {
-					s.writer.Write(buff)
-					s.bufferWg.Done()
-				}()
-			}
+	ctx, _ := signal.NotifyContext(context.Background(), syscall.SIGHUP)
+	//ctx, _ := context.WithTimeout(context.Background(), 15*time.Second) // test context with timeout
 
-		case <-t.C:
-			s.bufferNotifyCh <- struct{}{}
+	opts := []asynclog.Option{asynclog.WithShutdownReport(os.Stderr, nil)}
+	if *debug {
+		opts = append(opts, asynclog.WithDiagnostics(os.Stderr))
+	}
+	if *format != "" {
+		enc, err := asynclog.ParseEncoder(*format)
+		if err != nil {
+			log.Fatal(err)
 		}
+		opts = append(opts, asynclog.WithEncoder(enc))
+	}
+	if *interval > 0 {
+		opts = append(opts, asynclog.WithFlushInterval(*interval))
+	}
+	if *batch > 0 {
+		opts = append(opts, asynclog.WithBatchSize(*batch))
+	}
+	if *batchBytes > 0 {
+		opts = append(opts, asynclog.WithMaxBatchBytes(*batchBytes))
+	}
+	if *errorSink != "" {
+		w, err := asynclog.OpenSink(*errorSink)
+		if err != nil {
+			log.Fatal(err)
+		}
+		opts = append(opts, asynclog.WithErrorSink(w, asynclog.LevelError))
 	}
 
-}
-
-func (s *Service) Print(log string, ctx context.Context) {
-	// етот метод не завершен
-	// тут проблема в том, что после закрытия контекста в Run етот канал не будут читать и запись заблокируется
-	// Необходимо чтобы после закрытия контекста етот метот не блокировался. Записать мы уже ничего не можем поетому просто возврат без записи
-	//
-	if ctx.Err() != nil {
-		return
+	service, err := newService(*sink, *config, opts...)
+	if err != nil {
+		log.Fatal(err)
 	}
 
-	s.logCh <- log
-}
+	if *config != "" {
+		go reloadOnSignal(service, *config)
+	}
 
-func main() {
-	ctx, _ := signal.NotifyContext(context.Background(), syscall.SIGHUP)
-	//ctx, _ := context.WithTimeout(context.Background(), 15*time.Second) // test context with timeout
+	if *execute {
+		os.Exit(runCommand(ctx, service, flag.Args()))
+	}
 
-	service := NewService(os.Stdout)
 	go func() {
 		service.Run(ctx)
 	}()
 
+	if *listen != "" {
+		go func() {
+			log.Fatal(http.ListenAndServe(*listen, service.HTTPHandler()))
+		}()
+	}
+
+	if *tail != "" {
+		follow := service.Tail
+		if *container {
+			follow = service.TailContainer
+		}
+
+		go func() {
+			if err := follow(ctx, *tail, false); err != nil && ctx.Err() == nil {
+				log.Fatal(err)
+			}
+		}()
+
+		<-ctx.Done()
+		waitShutdown(service)
+		return
+	}
+
 	// sends request each second
 	go func() {
 		t := time.NewTicker(1 * time.Second)
@@ -112,7 +123,9 @@ func main() {
 		for {
 			select {
 			case <-t.C:
-				service.Print(fmt.Sprintf("log message %d", i), ctx)
+				if err := service.Print(ctx, fmt.Sprintf("log message %d", i)); err != nil && ctx.Err() == nil {
+					log.Print(err)
+				}
 				i++
 			case <-ctx.Done():
 				return
@@ -121,5 +134,75 @@ func main() {
 	}()
 
 	<-ctx.Done()
+	waitShutdown(service)
+}
+
+// waitShutdown waits a while for Run to write out what is left once the
+// context is done.
+func waitShutdown(service *asynclog.Service) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := service.Shutdown(ctx); err != nil {
+		log.Print(err)
+	}
+}
+
+// reloadOnSignal reloads the config file on every SIGUSR1, keeping the
+// current config if the new one is invalid.
+func reloadOnSignal(service *asynclog.Service, path string) {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, syscall.SIGUSR1)
+
+	for range ch {
+		c, err := asynclog.LoadConfig(path)
+		if err == nil {
+			err = service.Reload(c)
+		}
+		if err != nil {
+			log.Printf("reload %s: %v", path, err)
+		}
+	}
+}
+
+// runCommand ships the output of the command in args until it exits, and
+// returns the exit code to exit with once everything is written.
+func runCommand(ctx context.Context, service *asynclog.Service, args []string) int {
+	if len(args) == 0 {
+		log.Fatal("-exec: no command given")
+	}
+
+	go service.Run(context.Background())
+
+	err := service.Exec(ctx, args[0], args[0], args[1:]...)
+	service.Shutdown(context.Background())
+
+	var exitErr *exec.ExitError
+	switch {
+	case errors.As(err, &exitErr):
+		return exitErr.ExitCode()
+	case err != nil:
+		log.Print(err)
+		return 1
+	}
+
+	return 0
+}
+
+func newService(sink, config string, opts ...asynclog.Option) (*asynclog.Service, error) {
+	if config != "" {
+		c, err := asynclog.LoadConfig(config)
+		if err != nil {
+			return nil, err
+		}
+
+		return c.NewService(opts...)
+	}
+
+	w, err := asynclog.OpenSink(sink)
+	if err != nil {
+		return nil, err
+	}
 
+	return asynclog.NewService(w, opts...), nil
 }